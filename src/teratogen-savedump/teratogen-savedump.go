@@ -0,0 +1,94 @@
+// teratogen-savedump.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Command teratogen-savedump converts Teratogen save files into
+// human-readable JSON and back, for inspecting and hand-editing saves when
+// reproducing bugs.
+//
+// Usage:
+//
+//	teratogen-savedump [-undump] [input [output]]
+//
+// Without -undump, a save file is read and its JSON dump is written. With
+// -undump, the JSON is read and a save file is written. Input and output
+// default to stdin and stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	// The packages register the types of the saved data with ser when they
+	// are imported. The world and entity types come with the world package.
+	_ "teratogen/mob"
+	"teratogen/world"
+)
+
+var undump = flag.Bool("undump", false, "convert JSON back into a save file")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-undump] [input [output]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	var output io.WriteCloser = os.Stdout
+	if flag.NArg() > 1 {
+		f, err := os.Create(flag.Arg(1))
+		if err != nil {
+			fail(err)
+		}
+		output = f
+	}
+
+	// Dump doesn't need the game version and Undump writes the one from the
+	// dump.
+	container := world.SaveContainer("")
+	var err error
+	if *undump {
+		err = container.Undump(input, output)
+	} else {
+		err = container.Dump(input, output)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if err = output.Close(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -22,8 +22,8 @@ package action
 import (
 	"image"
 	"math/rand"
-	"teratogen/display/fx"
 	"teratogen/entity"
+	"teratogen/event"
 	"teratogen/fov"
 	"teratogen/mapgen"
 	"teratogen/query"
@@ -36,20 +36,22 @@ type Action struct {
 	world  *world.World
 	mapgen *mapgen.Mapgen
 	query  *query.Query
-	fx     *fx.Fx
+	events *event.Bus
 }
 
-func New(w *world.World, m *mapgen.Mapgen, q *query.Query, f *fx.Fx) *Action {
-	return &Action{world: w, mapgen: m, query: q, fx: f}
+// New creates a new action system. Events about the actions are published
+// in the bus.
+func New(w *world.World, m *mapgen.Mapgen, q *query.Query, b *event.Bus) *Action {
+	return &Action{world: w, mapgen: m, query: q, events: b}
 }
 
-func (a *Action) AttackMove(obj entity.Entity, vec image.Point) {
+func (a *Action) AttackMove(obj entity.ID, vec image.Point) {
 	newLoc := a.world.Manifold.Offset(a.query.Loc(obj), vec)
 	footprint := a.query.Footprint(obj, newLoc)
 
 	for _, loc := range footprint {
 		for _, oe := range a.world.Spatial.At(loc) {
-			hit := oe.Entity
+			hit := oe.Entity.(entity.ID)
 			if hit == obj {
 				// Ignore self-intersect
 				continue
@@ -63,46 +65,72 @@ func (a *Action) AttackMove(obj entity.Entity, vec image.Point) {
 	a.Move(obj, vec)
 }
 
-func (a *Action) Attack(attacker, target entity.Entity) {
+func (a *Action) Attack(attacker, target entity.ID) {
+	a.events.Publish(event.Attacked{Attacker: attacker, Target: target})
 	a.Damage(target, 1)
 }
 
-func (a *Action) Damage(target entity.Entity, amount int) {
-	if mob, ok := target.(entity.Stats); ok {
-		mob.Damage(amount)
+func (a *Action) Damage(target entity.ID, amount int) {
+	if stats, ok := a.world.Entities.Stats(target); ok {
+		loc := a.query.Loc(target)
+		stats.Damage(amount)
 		if amount > 0 {
-			a.fx.Blast(a.query.Loc(target), fx.BloodSquib)
+			a.events.Publish(event.Damaged{Target: target, Loc: loc, Amount: amount})
 		}
-		if mob.Health() <= 0 {
-			// Target died.
-			// Extra logic hooks here.
+		if stats.Health() <= 0 {
 			a.world.Spatial.Remove(target)
+			a.events.Publish(event.Died{Entity: target, Loc: loc})
+			// The player stays around for the HUD on the game over screen.
+			if target != a.world.Player {
+				a.world.Entities.Destroy(target)
+			}
 		}
 	}
 }
 
-func (a *Action) Move(obj entity.Entity, vec image.Point) {
-	newLoc := a.world.Manifold.Offset(a.query.Loc(obj), vec)
+func (a *Action) Move(obj entity.ID, vec image.Point) {
+	oldLoc := a.query.Loc(obj)
+	newLoc := a.world.Manifold.Offset(oldLoc, vec)
 
 	if a.world.Fits(obj, newLoc) {
-		if f, ok := obj.(entity.Fov); ok {
-			f.MoveFovOrigin(vec)
+		if f, ok := a.world.Entities.Fov(obj); ok {
+			f.MoveFovOrigin(vec, newLoc.Zone)
 		}
 		a.Place(obj, newLoc)
+		a.events.Publish(event.Moved{Entity: obj, From: oldLoc, To: newLoc, Dir: vec})
+		if newLoc.Zone != oldLoc.Zone {
+			a.events.Publish(event.FloorChanged{
+				Entity: obj, From: oldLoc.Zone, To: newLoc.Zone})
+		}
 	}
 }
 
-func (a *Action) Shoot(obj entity.Entity, vec image.Point) {
+// PickUp moves an item from the world into the inventory of an entity.
+func (a *Action) PickUp(obj, item entity.ID) {
+	inv, ok := a.world.Entities.Inventory(obj)
+	if !ok {
+		return
+	}
+	if a.world.IsAlive(item) {
+		a.world.Spatial.Remove(item)
+	}
+	inv.Items = append(inv.Items, item)
+	a.events.Publish(event.ItemPicked{Entity: obj, Item: item})
+}
+
+func (a *Action) Shoot(obj entity.ID, vec image.Point) {
 	dist := 6
 	damageAmount := 2
 
-	loc := a.query.Loc(obj)
+	origin := a.query.Loc(obj)
+	loc := origin
+	hitWall := false
 	// Trace the firing line
 	for i := 0; i < dist; i++ {
 		loc = a.world.Manifold.Offset(loc, vec)
 		if a.world.Terrain(loc).BlocksShot() {
 			dist = i + 1
-			a.fx.Blast(loc, fx.Sparks)
+			hitWall = true
 			break
 		}
 
@@ -112,24 +140,23 @@ func (a *Action) Shoot(obj entity.Entity, vec image.Point) {
 		}
 	}
 
+	a.events.Publish(event.Shot{
+		Shooter: obj, From: origin, Dir: vec, Dist: dist, End: loc, HitWall: hitWall})
+
 	for _, oe := range a.world.Spatial.At(loc) {
-		a.Damage(oe.Entity, damageAmount)
+		a.Damage(oe.Entity.(entity.ID), damageAmount)
 	}
-
-	a.fx.Beam(a.query.Loc(obj), vec, dist, fx.GunBeam)
 }
 
 // Place puts an entity in a specific location and performs any necessary
 // further actions that should follow after the entity entering the location.
-func (a *Action) Place(obj entity.Entity, loc space.Location) {
+func (a *Action) Place(obj entity.ID, loc space.Location) {
 	a.world.Place(obj, loc)
 	if !a.world.IsAlive(obj) {
 		panic("Placed obj not shown alive")
 	}
 
-	if f, ok := obj.(entity.Fov); ok {
-		a.DoFov(f)
-	}
+	a.DoFov(obj)
 
 	for _, footLoc := range a.query.Footprint(obj, loc) {
 		if obj == a.world.Player && footLoc.Zone == a.world.FloorExit.Zone {
@@ -139,10 +166,10 @@ func (a *Action) Place(obj entity.Entity, loc space.Location) {
 	}
 }
 
-func (a *Action) DoFov(obj entity.Entity) {
+func (a *Action) DoFov(obj entity.ID) {
 	// TODO: Parametrisable radius
 	const radius = 12
-	if f, ok := obj.(entity.Fov); ok {
+	if f, ok := a.world.Entities.Fov(obj); ok {
 		fv := fov.New(
 			func(loc space.Location) bool { return a.world.Terrain(loc).BlocksSight() },
 			func(pt image.Point, loc space.Location) { f.MarkFov(pt, loc) },
@@ -152,9 +179,15 @@ func (a *Action) DoFov(obj entity.Entity) {
 }
 
 func (a *Action) RunAI() {
-	for actor := a.world.NextActor(); actor != nil; actor = a.world.NextActor() {
-		if actor == a.world.Player {
-			continue
+	a.world.Entities.EachAI(func(actor entity.ID, ai *entity.AI) {
+		if !a.world.IsAlive(actor) {
+			return
+		}
+
+		if a.query.Loc(actor).Zone != a.query.Loc(a.world.Player).Zone {
+			// Mobs on the floors the player isn't on are frozen until the
+			// player comes back.
+			return
 		}
 
 		moveDir := tile.HexDirs[rand.Intn(6)]
@@ -162,36 +195,26 @@ func (a *Action) RunAI() {
 			moveDir = tile.HexVecToDir(enemy.Offset)
 		}
 		a.AttackMove(actor, moveDir)
-	}
+	})
 }
 
 func (a *Action) EndTurn() {
 	a.RunAI()
-	a.world.EndTurn()
-}
-
-func (a *Action) CleanupPreviousLevel() {
-	// Reset player's FOV to get rid of the old level crap.
-	a.world.Player.ClearFov()
-	a.DoFov(a.world.Player)
-
-	filter := func(loc space.Location) bool { return loc.Zone < a.query.Loc(a.world.Player).Zone }
-
-	a.world.RemoveTerrain(filter)
-	a.world.Spatial.ForEach(func(obj interface{}) {
-		if filter(a.query.Loc(obj)) {
-			a.world.Spatial.Remove(obj)
-		}
-	})
 }
 
 // CreateNextFloor creates the next level. Should be called when the player
-// enters the level where the entrance to this level will be.
+// enters the level where the entrance to this level will be. The earlier
+// levels are kept around so that the player can go back to them.
 func (a *Action) CreateNextFloor() space.Location {
 	depth := int(a.world.FloorExit.Zone)
-	entrance, exit := a.mapgen.TestMap(space.Location{0, 0, uint16(depth + 1)}, depth)
-	a.world.Manifold.SetPortalTo(a.world.FloorExit, entrance)
+	entrance, exit := a.mapgen.Floor(space.Loc(0, 0, uint16(depth+1)), depth)
+	if a.world.FloorExit != (space.Location{}) {
+		a.world.Manifold.SetPortalTo(a.world.FloorExit, entrance)
+		// The wall behind the entry stairs leads back up to the cell in
+		// front of the exit of the previous floor.
+		a.world.Manifold.SetPortalTo(
+			entrance.Add(image.Pt(-1, 0)), a.world.FloorExit.Add(image.Pt(-1, 0)))
+	}
 	a.world.FloorExit = exit
-	a.CleanupPreviousLevel()
 	return entrance
 }
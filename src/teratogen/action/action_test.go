@@ -0,0 +1,114 @@
+// action_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package action
+
+import (
+	"image"
+	"reflect"
+	"teratogen/entity"
+	"teratogen/event"
+	"teratogen/mapgen"
+	"teratogen/mob"
+	"teratogen/query"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+// testAction sets up a small open room with the player in it. The events
+// the actions publish are recorded in the log.
+func testAction() (w *world.World, a *Action, log *event.Log) {
+	w = world.New()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			w.SetTerrain(space.Loc(int16(x), int16(y), 1), world.FloorTerrain)
+		}
+	}
+	w.Player = mob.NewPC(w, mob.Spec{Icon: "player", MaxHealth: 3})
+	w.Place(w.Player, space.Loc(2, 2, 1))
+
+	bus := event.NewBus()
+	log = new(event.Log)
+	bus.Subscribe(log.Record)
+	a = New(w, mapgen.New(w), query.New(w), bus)
+	return
+}
+
+func testMonster(w *world.World, loc space.Location) entity.ID {
+	id := mob.New(w, mob.Spec{Icon: "zombie", MaxHealth: 1})
+	w.Place(id, loc)
+	return id
+}
+
+func TestDeath(t *testing.T) {
+	w, a, _ := testAction()
+	zombie := testMonster(w, space.Loc(3, 2, 1))
+
+	a.AttackMove(w.Player, image.Pt(1, 0))
+	if w.IsAlive(zombie) {
+		t.Fatal("Attacked monster didn't die")
+	}
+	if w.Entities.Exists(zombie) {
+		t.Error("Dead monster left in the entity registry")
+	}
+	if _, ok := w.Entities.AI(zombie); ok {
+		t.Error("Dead monster's AI left in the entity registry")
+	}
+
+	a.Damage(w.Player, 10)
+	if w.IsAlive(w.Player) {
+		t.Fatal("Player didn't die")
+	}
+	if _, ok := w.Entities.Stats(w.Player); !ok {
+		t.Error("Dead player's stats removed")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	w, a, log := testAction()
+	zombie := testMonster(w, space.Loc(4, 2, 1))
+
+	// Step next to the zombie, kill it and step into its cell. Bumping into
+	// the void past the room does nothing.
+	a.AttackMove(w.Player, image.Pt(1, 0))
+	a.AttackMove(w.Player, image.Pt(1, 0))
+	a.AttackMove(w.Player, image.Pt(1, 0))
+	a.Damage(w.Player, 0)
+	a.Move(w.Player, image.Pt(0, -5))
+
+	expected := []event.Event{
+		event.Moved{Entity: w.Player, From: space.Loc(2, 2, 1), To: space.Loc(3, 2, 1), Dir: image.Pt(1, 0)},
+		event.Attacked{Attacker: w.Player, Target: zombie},
+		event.Damaged{Target: zombie, Loc: space.Loc(4, 2, 1), Amount: 1},
+		event.Died{Entity: zombie, Loc: space.Loc(4, 2, 1)},
+		event.Moved{Entity: w.Player, From: space.Loc(3, 2, 1), To: space.Loc(4, 2, 1), Dir: image.Pt(1, 0)},
+	}
+	if !reflect.DeepEqual(log.Events, expected) {
+		t.Errorf("Got events %v, expected %v", log.Events, expected)
+	}
+
+	log.Clear()
+	a.Damage(w.Player, 10)
+	expected = []event.Event{
+		event.Damaged{Target: w.Player, Loc: space.Loc(4, 2, 1), Amount: 10},
+		event.Died{Entity: w.Player, Loc: space.Loc(4, 2, 1)},
+	}
+	if !reflect.DeepEqual(log.Events, expected) {
+		t.Errorf("Got events %v, expected %v", log.Events, expected)
+	}
+}
@@ -39,6 +39,9 @@ type App interface {
 type app struct {
 	nanosecondsPerFrame int64
 	states              []State
+	capture             *capture
+	window              *window
+	palettizer          *palettizer
 }
 
 func (a *app) Run() {
@@ -67,9 +70,11 @@ func (a *app) Run() {
 			nUpdates = maxMultipleUpdates
 		}
 
+		a.window.update()
 		a.TopState().Draw()
-		gfx.BlitX2(sdl.Frame(), sdl.Video())
-		sdl.Flip()
+		frame := a.present(gfx.Frame())
+		a.capture.update(frame, currentTime)
+		a.window.show(frame)
 
 		a.TopState().Update(nUpdates * a.nanosecondsPerFrame)
 		lastTime += nUpdates * a.nanosecondsPerFrame
@@ -77,6 +82,17 @@ func (a *app) Run() {
 	sdl.Stop()
 }
 
+// present returns the finished frame as it is shown on the screen.
+func (a *app) present(frame gfx.Surface32Bit) gfx.Surface32Bit {
+	if !a.window.conf.Paletted {
+		return frame
+	}
+	if a.palettizer == nil {
+		a.palettizer = newPalettizer()
+	}
+	return a.palettizer.render(frame, a.TopState())
+}
+
 func (a *app) Stop() {
 	for len(a.states) > 0 {
 		a.PopState()
@@ -113,12 +129,13 @@ func Get() App {
 }
 
 func initApp() App {
-	sdl.Run(640, 480)
-	sdl.SetFrame(sdl.NewSurface(320, 240))
-
 	a := &app{}
+	a.window = newWindow()
+	gfx.SetFrame(gfx.NewSurface(FrameWidth, FrameHeight))
+
 	a.nanosecondsPerFrame = 33e6
 	a.states = []State{}
+	a.capture = newCapture()
 
 	return a
 }
@@ -0,0 +1,45 @@
+// backend.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"image"
+	"teratogen/gfx"
+	"teratogen/sdl"
+)
+
+// sdlBackend makes surfaces in the pixel format of the SDL video surface and
+// blits between them with SDL's blitter. It needs SDL to be running.
+type sdlBackend struct{}
+
+func (sdlBackend) NewSurface(w, h int) gfx.Surface { return sdl.NewSurface(w, h) }
+
+func (sdlBackend) ToSurface(img image.Image) gfx.Surface { return sdl.ToSurface(img) }
+
+func (sdlBackend) Blit(src gfx.Surface, bounds image.Rectangle, x, y int, dest gfx.Surface) bool {
+	s, ok := src.(*sdl.Surface)
+	if !ok {
+		return false
+	}
+	d, ok := dest.(*sdl.Surface)
+	if !ok {
+		return false
+	}
+	s.Blit(bounds, x, y, d)
+	return true
+}
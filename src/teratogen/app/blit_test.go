@@ -15,9 +15,10 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package gfx
+package app
 
 import (
+	"teratogen/gfx"
 	"teratogen/sdl"
 	"testing"
 )
@@ -46,7 +47,7 @@ func Benchmark2XZoomBlit(b *testing.B) {
 
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		BlitX2(sdl.Frame(), sdl.Video())
+		gfx.BlitX2(sdl.Frame(), sdl.Video())
 		sdl.Flip()
 	}
 	b.StopTimer()
@@ -74,7 +75,7 @@ func Benchmark3XZoomBlit(b *testing.B) {
 
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		BlitX3(sdl.Frame(), sdl.Video())
+		gfx.BlitX3(sdl.Frame(), sdl.Video())
 		sdl.Flip()
 	}
 	b.StopTimer()
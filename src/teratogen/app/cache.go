@@ -1,59 +0,0 @@
-// cache.go
-//
-// Copyright (C) 2012 Risto Saarelma
-//
-// This program is free software: you can redistribute it and/or modify
-// it under the terms of the GNU General Public License as published by
-// the Free Software Foundation, either version 3 of the License, or
-// (at your option) any later version.
-//
-// This program is distributed in the hope that it will be useful,
-// but WITHOUT ANY WARRANTY; without even the implied warranty of
-// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
-// GNU General Public License for more details.
-//
-// You should have received a copy of the GNU General Public License
-// along with this program.  If not, see <http://www.gnu.org/licenses/>.
-
-package app
-
-import (
-	"os"
-	"teratogen/archive"
-	"teratogen/cache"
-)
-
-var globalCache *cache.Cache = nil
-
-// Set up a file archive that first looks for files in the local physical
-// filesystem path, then in a zip file contained in the local binary.
-func initArchive() (fs archive.Device, err error) {
-	var devices = make([]archive.Device, 0)
-
-	fd, err := archive.FsDevice(".")
-	if err != nil {
-		// If the file system path won't work, things are bad.
-		return
-	}
-	devices = append(devices, fd)
-
-	zd, zerr := archive.FileZipDevice(os.Args[0])
-	// If the self exe isn't a zip, just don't add the device. Things still
-	// work if the assets can be found in the filesystem.
-	if zerr == nil {
-		devices = append(devices, zd)
-	}
-
-	return archive.New(devices...), nil
-}
-
-func Cache() *cache.Cache {
-	if globalCache == nil {
-		fs, err := initArchive()
-		if err != nil {
-			panic(err)
-		}
-		globalCache = cache.New(fs)
-	}
-	return globalCache
-}
@@ -0,0 +1,138 @@
+// capture.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"os"
+	"path"
+	"teratogen/archive"
+	"teratogen/gfx"
+	"teratogen/sdl"
+	"time"
+)
+
+// Hotkeys for capturing the screen. They work in every app state.
+const (
+	ScreenshotKey = sdl.K_F12
+	RecordKey     = sdl.K_F11
+)
+
+// ScreenshotDir is the directory in the user data directory where
+// screenshots and recordings are saved.
+const ScreenshotDir = "screenshots"
+
+// maxRecordTime is how long a recording can get before it is stopped
+// automatically.
+const maxRecordTime = 60e9
+
+// hotkey detects a key going down by polling the keyboard state, so that it
+// doesn't take key events away from the app states.
+type hotkey struct {
+	sym  sdl.KeySym
+	down bool
+}
+
+func (k *hotkey) pressed() bool {
+	down := sdl.IsKeyDown(k.sym)
+	result := down && !k.down
+	k.down = down
+	return result
+}
+
+// capture handles the screen capture hotkeys.
+type capture struct {
+	screenshotKey hotkey
+	recordKey     hotkey
+	recorder      *gfx.GifRecorder
+	recordStart   int64
+}
+
+func newCapture() *capture {
+	return &capture{
+		screenshotKey: hotkey{sym: ScreenshotKey},
+		recordKey:     hotkey{sym: RecordKey}}
+}
+
+// update is called with each finished frame before it is scaled onto the
+// screen.
+func (c *capture) update(frame gfx.Surface32Bit, t int64) {
+	if c.screenshotKey.pressed() {
+		c.screenshot(frame)
+	}
+
+	if c.recordKey.pressed() {
+		if c.recorder == nil {
+			c.recorder = gfx.NewGifRecorder(gfx.GamePalette)
+			c.recordStart = t
+			fmt.Fprintln(os.Stderr, "Recording started")
+		} else {
+			c.stopRecording()
+		}
+	}
+
+	if c.recorder != nil {
+		c.recorder.AddFrame(frame, t)
+		if t-c.recordStart >= maxRecordTime {
+			c.stopRecording()
+		}
+	}
+}
+
+func (c *capture) screenshot(frame gfx.Surface32Bit) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gfx.ToImage(frame)); err != nil {
+		fmt.Fprintln(os.Stderr, "Screenshot failed:", err)
+		return
+	}
+	saveCapture(captureName("png"), buf.Bytes())
+}
+
+// stopRecording encodes and saves the recording in the background.
+func (c *capture) stopRecording() {
+	recorder, name := c.recorder, captureName("gif")
+	c.recorder = nil
+	go func() {
+		var buf bytes.Buffer
+		if err := recorder.Encode(&buf); err != nil {
+			fmt.Fprintln(os.Stderr, "Recording failed:", err)
+			return
+		}
+		saveCapture(name, buf.Bytes())
+	}()
+}
+
+// captureName returns a timestamped file name for a capture.
+func captureName(ext string) string {
+	stamp := time.Now().Format("20060102-150405.000")
+	return path.Join(ScreenshotDir, "teratogen-"+stamp+"."+ext)
+}
+
+func saveCapture(name string, data []byte) {
+	d, err := UserData()
+	if err == nil {
+		err = archive.WriteFile(d, name, data)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Saving capture failed:", err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Saved", name)
+}
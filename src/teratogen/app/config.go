@@ -0,0 +1,80 @@
+// config.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"teratogen/archive"
+	"teratogen/gfx"
+)
+
+// Config holds the user settings that are kept in the config file.
+type Config struct {
+	// Scale is the integer factor the game display is scaled up by in a
+	// window.
+	Scale      int  `json:"scale"`
+	Fullscreen bool `json:"fullscreen"`
+	// Paletted shows the display through the game palette, which enables
+	// the palette effects.
+	Paletted bool `json:"paletted"`
+	// Filter is the name of the gfx filter used to scale the display.
+	Filter string `json:"filter"`
+}
+
+func DefaultConfig() Config {
+	return Config{Scale: 2, Filter: "nearest"}
+}
+
+// LoadConfig reads the config file from a device. Settings missing from the
+// file keep their default values, and a missing file gives the default
+// config.
+func LoadConfig(d archive.Device) (conf Config, err error) {
+	conf = DefaultConfig()
+	rc, err := d.Open(ConfigFile)
+	if os.IsNotExist(err) {
+		return conf, nil
+	} else if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	if err = json.NewDecoder(rc).Decode(&conf); err != nil {
+		err = errors.New(fmt.Sprintf("Bad config file: %s", err))
+		return DefaultConfig(), err
+	}
+	if conf.Scale < 1 {
+		conf.Scale = 1
+	}
+	if _, ok := gfx.Filters[conf.Filter]; !ok {
+		err = errors.New(fmt.Sprintf("Unknown display filter '%s'", conf.Filter))
+		conf.Filter = DefaultConfig().Filter
+	}
+	return
+}
+
+// SaveConfig writes the config file to a device.
+func SaveConfig(d archive.WritableDevice, conf Config) error {
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return archive.WriteFile(d, ConfigFile, append(data, '\n'))
+}
@@ -0,0 +1,85 @@
+// config_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"teratogen/archive"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d, err := archive.WritableFsDevice(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conf, err := LoadConfig(d); err != nil || conf != DefaultConfig() {
+		t.Errorf("Missing config file didn't give defaults: %v %v", conf, err)
+	}
+
+	if err = SaveConfig(d, Config{Scale: 3, Fullscreen: true, Filter: "crt"}); err != nil {
+		t.Fatal(err)
+	}
+	if conf, _ := LoadConfig(d); conf != (Config{Scale: 3, Fullscreen: true, Paletted: false, Filter: "crt"}) {
+		t.Errorf("Config didn't survive saving: %v", conf)
+	}
+
+	archive.WriteFile(d, ConfigFile, []byte(`{"fullscreen": true}`))
+	if conf, _ := LoadConfig(d); conf != (Config{Scale: 2, Fullscreen: true, Filter: "nearest"}) {
+		t.Errorf("Missing setting didn't get default: %v", conf)
+	}
+
+	archive.WriteFile(d, ConfigFile, []byte(`{"filter": "sepia"}`))
+	if conf, err := LoadConfig(d); err == nil || conf != DefaultConfig() {
+		t.Errorf("Unknown filter not reported: %v %v", conf, err)
+	}
+
+	archive.WriteFile(d, ConfigFile, []byte(`{"scale": `))
+	if conf, err := LoadConfig(d); err == nil || conf != DefaultConfig() {
+		t.Errorf("Bad config file not reported: %v %v", conf, err)
+	}
+}
+
+func TestScale(t *testing.T) {
+	for _, c := range []struct {
+		size  image.Point
+		scale int
+	}{
+		{image.Pt(640, 480), 2},
+		{image.Pt(1920, 1080), 4},
+		{image.Pt(1000, 400), 1},
+		{image.Pt(100, 100), 1},
+	} {
+		if s := bestScale(c.size); s != c.scale {
+			t.Errorf("Scale for %v was %d, expected %d", c.size, s, c.scale)
+		}
+	}
+
+	box := letterbox(image.Rect(0, 0, 1920, 1080), image.Pt(320, 240), 4)
+	if box != image.Rect(320, 60, 1600, 1020) {
+		t.Errorf("Bad letterbox %v", box)
+	}
+}
@@ -0,0 +1,68 @@
+// palette.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"teratogen/cache"
+	"teratogen/gfx"
+)
+
+// PaletteState is implemented by app states that change the screen palette
+// for effects. The palette is only used when the display is paletted.
+type PaletteState interface {
+	State
+
+	// Palette returns the colors to show the screen with, given the base
+	// palette. The result must be as long as the base palette.
+	Palette(base color.Palette) color.Palette
+}
+
+// palettizer shows the frames through the game palette.
+type palettizer struct {
+	base   color.Palette
+	filter *gfx.PaletteFilter
+	output gfx.Surface
+}
+
+func newPalettizer() *palettizer {
+	pal, err := cache.Get().TryGetPalette(cache.PaletteFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Using the default palette:", err)
+		pal = gfx.Arne16
+	}
+	base := gfx.ExtendPalette(pal)
+	return &palettizer{base: base, filter: gfx.NewPaletteFilter(base)}
+}
+
+// render returns the frame as seen through the palette of the state.
+func (p *palettizer) render(frame gfx.Surface32Bit, state State) gfx.Surface32Bit {
+	if p.output == nil || p.output.Bounds() != frame.Bounds() {
+		p.output = gfx.NewSurface(frame.Bounds().Dx(), frame.Bounds().Dy())
+	}
+
+	pal := p.base
+	if ps, ok := state.(PaletteState); ok {
+		pal = ps.Palette(p.base)
+	}
+	p.filter.SetColors(pal)
+	p.filter.Apply(frame, p.output)
+	return p.output
+}
@@ -0,0 +1,84 @@
+// save.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"os"
+	"teratogen/ser"
+	"teratogen/world"
+)
+
+// SaveFile is the saved game in the user data directory.
+const SaveFile = SaveDir + "/game.sav"
+
+// SaveContainer returns the container for reading and writing the game's
+// save files.
+func SaveContainer() *ser.Container {
+	return world.SaveContainer(Version)
+}
+
+// SaveGame writes the game world into the save file.
+func SaveGame(w *world.World) error {
+	d, err := UserData()
+	if err != nil {
+		return err
+	}
+	wc, err := d.Create(SaveFile)
+	if err != nil {
+		return err
+	}
+	err = SaveContainer().Save(w, wc)
+	if cerr := wc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// LoadGame reads the game world from the save file. It returns a nil world
+// if there is no saved game.
+func LoadGame() (w *world.World, err error) {
+	d, err := UserData()
+	if err != nil {
+		return
+	}
+	rc, err := d.Open(SaveFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	defer rc.Close()
+
+	obj, _, err := SaveContainer().Load(rc)
+	if err != nil {
+		return
+	}
+	return obj.(*world.World), nil
+}
+
+// RemoveSave removes the save file, if there is one.
+func RemoveSave() error {
+	d, err := UserData()
+	if err != nil {
+		return err
+	}
+	if err = d.Remove(SaveFile); os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
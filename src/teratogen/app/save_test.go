@@ -0,0 +1,68 @@
+// save_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"teratogen/archive"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+func TestSaveGame(t *testing.T) {
+	dir, err := ioutil.TempDir("", "teratogen-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d, err := archive.WritableFsDevice(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resetUserData()
+	useUserData(d)
+
+	if w, err := LoadGame(); w != nil || err != nil {
+		t.Errorf("Loaded a game without a save: %v %v", w, err)
+	}
+
+	w := world.New()
+	w.SetTerrain(space.Loc(1, 2, 1), world.WallTerrain)
+	if err := SaveGame(w); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded == nil || loaded.Terrain(space.Loc(1, 2, 1)).Kind != world.WallKind {
+		t.Errorf("Saved game not loaded")
+	}
+
+	if err := RemoveSave(); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := LoadGame(); w != nil {
+		t.Errorf("Loaded a removed save")
+	}
+	if err := RemoveSave(); err != nil {
+		t.Errorf("Removing a missing save failed: %s", err)
+	}
+}
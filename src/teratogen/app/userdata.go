@@ -0,0 +1,61 @@
+// userdata.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"teratogen/archive"
+)
+
+// Paths for the things the game keeps in the user data directory.
+const (
+	SaveDir    = "saves"
+	MorgueDir  = "morgue"
+	ConfigFile = "config.json"
+	ScoreFile  = "scores"
+)
+
+var (
+	userDataOnce sync.Once
+	userData     archive.WritableDevice
+	userDataErr  error
+)
+
+// UserData returns the device for the user's data directory, where the game
+// keeps save files, configuration, morgue files and high scores. If there is
+// no user data directory, for example when HOME isn't set, a userdata
+// directory next to the game executable is used instead.
+func UserData() (archive.WritableDevice, error) {
+	userDataOnce.Do(func() {
+		userData, userDataErr = archive.UserDevice("teratogen")
+		if userDataErr != nil {
+			userData, userDataErr = localUserData()
+		}
+	})
+	return userData, userDataErr
+}
+
+func localUserData() (archive.WritableDevice, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return archive.WritableFsDevice(filepath.Join(filepath.Dir(exe), "userdata"))
+}
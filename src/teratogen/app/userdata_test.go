@@ -0,0 +1,65 @@
+// userdata_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"teratogen/archive"
+	"testing"
+)
+
+// resetUserData makes the next UserData call look up the directory again.
+func resetUserData() {
+	userDataOnce = sync.Once{}
+	userData, userDataErr = nil, nil
+}
+
+// useUserData makes UserData return d until the next reset.
+func useUserData(d archive.WritableDevice) {
+	resetUserData()
+	userDataOnce.Do(func() { userData = d })
+}
+
+func TestUserDataWithoutHome(t *testing.T) {
+	for _, name := range []string{"HOME", "XDG_DATA_HOME", "APPDATA"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Unsetenv(name)
+	}
+	defer resetUserData()
+	resetUserData()
+
+	d, err := UserData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(filepath.Dir(exe), "userdata")
+	defer os.RemoveAll(dir)
+	if err := SaveConfig(d, DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ConfigFile)); err != nil {
+		t.Errorf("Config not saved next to the executable: %s", err)
+	}
+}
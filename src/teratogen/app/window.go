@@ -0,0 +1,171 @@
+// window.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"teratogen/gfx"
+	"teratogen/sdl"
+)
+
+// Size of the frame the game draws on. The frame is scaled up to fill the
+// window.
+const (
+	FrameWidth  = 320
+	FrameHeight = 240
+)
+
+// Hotkeys for the display settings.
+const (
+	// FullscreenKey toggles between fullscreen and windowed display.
+	FullscreenKey = sdl.K_F10
+	// FilterKey switches to the next display filter.
+	FilterKey = sdl.K_F9
+)
+
+// window shows the game frame on the screen at the best integer scale that
+// fits, and handles the fullscreen toggle and window resizing.
+type window struct {
+	conf          Config
+	fullscreenKey hotkey
+	filterKey     hotkey
+}
+
+// newWindow starts SDL with the video mode from the config file.
+func newWindow() *window {
+	w := &window{
+		fullscreenKey: hotkey{sym: FullscreenKey},
+		filterKey:     hotkey{sym: FilterKey}}
+
+	w.conf = DefaultConfig()
+	d, err := UserData()
+	if err == nil {
+		w.conf, err = LoadConfig(d)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	size := w.windowedSize()
+	sdl.Run(size.X, size.Y)
+	gfx.SetBackend(sdlBackend{})
+	if w.conf.Fullscreen {
+		w.setVideoMode()
+	}
+	return w
+}
+
+func (w *window) windowedSize() image.Point {
+	return image.Pt(FrameWidth*w.conf.Scale, FrameHeight*w.conf.Scale)
+}
+
+func (w *window) setVideoMode() {
+	size := sdl.DesktopSize()
+	if !w.conf.Fullscreen || size.X < FrameWidth || size.Y < FrameHeight {
+		size = w.windowedSize()
+	}
+	sdl.SetVideoMode(size.X, size.Y, w.conf.Fullscreen)
+}
+
+func (w *window) saveConfig() {
+	d, err := UserData()
+	if err == nil {
+		err = SaveConfig(d, w.conf)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Saving config failed:", err)
+	}
+}
+
+// update handles the video mode changes. It must not be called while the
+// screen is being drawn to.
+func (w *window) update() {
+	if w.fullscreenKey.pressed() {
+		w.conf.Fullscreen = !w.conf.Fullscreen
+		w.setVideoMode()
+		w.saveConfig()
+	}
+
+	if w.filterKey.pressed() {
+		w.conf.Filter = nextFilter(w.conf.Filter)
+		fmt.Fprintln(os.Stderr, "Display filter:", w.conf.Filter)
+		w.saveConfig()
+	}
+
+	if size, ok := sdl.ResizeRequest(); ok && !w.conf.Fullscreen {
+		sdl.SetVideoMode(size.X, size.Y, false)
+		w.conf.Scale = bestScale(size)
+		w.saveConfig()
+	}
+}
+
+// show scales the frame onto the screen, letterboxing it if the screen
+// isn't an exact multiple of the frame size, and flips the screen.
+func (w *window) show(frame gfx.Surface32Bit) {
+	screen := sdl.Video()
+	bounds := screen.Bounds()
+	scale := bestScale(bounds.Size())
+	inner := letterbox(bounds, frame.Bounds().Size(), scale)
+
+	for _, border := range []image.Rectangle{
+		{bounds.Min, image.Pt(bounds.Max.X, inner.Min.Y)},
+		{image.Pt(bounds.Min.X, inner.Max.Y), bounds.Max},
+		{image.Pt(bounds.Min.X, inner.Min.Y), image.Pt(inner.Min.X, inner.Max.Y)},
+		{image.Pt(inner.Max.X, inner.Min.Y), image.Pt(bounds.Max.X, inner.Max.Y)},
+	} {
+		if !border.Empty() {
+			screen.FillRect(border, gfx.Black)
+		}
+	}
+
+	gfx.Filters[w.conf.Filter].Blit(frame, screen, scale, inner.Min)
+	sdl.Flip()
+}
+
+// nextFilter returns the name of the filter after the named one.
+func nextFilter(name string) string {
+	for i, n := range gfx.FilterNames {
+		if n == name {
+			return gfx.FilterNames[(i+1)%len(gfx.FilterNames)]
+		}
+	}
+	return gfx.FilterNames[0]
+}
+
+// bestScale returns the largest integer scale at which the frame fits on a
+// screen of the given size, but at least 1.
+func bestScale(screenSize image.Point) int {
+	scale := screenSize.X / FrameWidth
+	if s := screenSize.Y / FrameHeight; s < scale {
+		scale = s
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	return scale
+}
+
+// letterbox returns the area a frame scaled by the scale takes when centered
+// on the screen.
+func letterbox(screen image.Rectangle, frameSize image.Point, scale int) image.Rectangle {
+	size := frameSize.Mul(scale)
+	min := screen.Min.Add(screen.Size().Sub(size).Div(2))
+	return image.Rectangle{min, min.Add(size)}
+}
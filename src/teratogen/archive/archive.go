@@ -26,13 +26,29 @@ import (
 	"image"
 	"image/png"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"teratogen/font"
 )
 
+// Device is a file storage device. Paths are relative to the root of the
+// device and use forward slashes.
 type Device interface {
 	Open(path string) (rc io.ReadCloser, err error)
+
+	// List returns the sorted names of the files and directories in a
+	// directory. Directory names end with a slash. The root directory is
+	// "".
+	List(dir string) (names []string, err error)
+
+	// Glob returns the sorted paths of the files matching a pattern. The
+	// pattern syntax is that of path.Match, applied to whole paths, so
+	// wildcards don't match slashes.
+	Glob(pattern string) (paths []string, err error)
 }
 
 type fsDevice string
@@ -45,10 +61,39 @@ func FsDevice(rootPath string) (fd Device, err error) {
 }
 
 func (fd fsDevice) Open(path string) (rc io.ReadCloser, err error) {
-	return os.Open(filepath.Join(string(fd), path))
+	return os.Open(filepath.Join(string(fd), filepath.FromSlash(path)))
 }
 
-type zipDevice struct{ reader *zip.Reader }
+func (fd fsDevice) List(dir string) (names []string, err error) {
+	infos, err := ioutil.ReadDir(filepath.Join(string(fd), filepath.FromSlash(dir)))
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if isTempFile(name) {
+			continue
+		}
+		if info.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (fd fsDevice) Glob(pattern string) (paths []string, err error) {
+	return glob(fd, pattern)
+}
+
+// zipDevice indexes the files and directories of a zip file for fast
+// lookups.
+type zipDevice struct {
+	files map[string]*zip.File
+	// dirs maps directory paths to the sorted names of their contents.
+	dirs map[string][]string
+}
 
 // FileZipDevice returns an archive device that represents the contents of a
 // zip file.
@@ -57,20 +102,73 @@ func FileZipDevice(path string) (zd Device, err error) {
 	if err != nil {
 		return
 	}
-	zd = zipDevice{&r.Reader}
+	zd = newZipDevice(&r.Reader)
 	return
 }
 
-func (zd zipDevice) Open(path string) (rc io.ReadCloser, err error) {
-	for _, f := range zd.reader.File {
-		if f.Name == path {
-			return f.Open()
+func newZipDevice(r *zip.Reader) *zipDevice {
+	zd := &zipDevice{
+		files: make(map[string]*zip.File),
+		dirs:  map[string][]string{"": []string{}}}
+	for _, f := range r.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if name == "" {
+			continue
+		}
+		if strings.HasSuffix(f.Name, "/") {
+			zd.addDir(name)
+		} else {
+			zd.files[name] = f
+			zd.addEntry(path.Dir(name), path.Base(name))
 		}
 	}
+	for _, names := range zd.dirs {
+		sort.Strings(names)
+	}
+	return zd
+}
+
+// addDir adds a directory and any missing parent directories to the index.
+func (zd *zipDevice) addDir(dir string) {
+	if dir == "." {
+		return
+	}
+	if _, ok := zd.dirs[dir]; ok {
+		return
+	}
+	zd.dirs[dir] = []string{}
+	zd.addEntry(path.Dir(dir), path.Base(dir)+"/")
+}
+
+func (zd *zipDevice) addEntry(dir, name string) {
+	zd.addDir(dir)
+	if dir == "." {
+		dir = ""
+	}
+	zd.dirs[dir] = append(zd.dirs[dir], name)
+}
+
+func (zd *zipDevice) Open(path string) (rc io.ReadCloser, err error) {
+	if f, ok := zd.files[path]; ok {
+		return f.Open()
+	}
 	err = errors.New(fmt.Sprintf("File '%s' not found", path))
 	return
 }
 
+func (zd *zipDevice) List(dir string) (names []string, err error) {
+	names, ok := zd.dirs[cleanDir(dir)]
+	if !ok {
+		err = errors.New(fmt.Sprintf("Directory '%s' not found", dir))
+		return
+	}
+	return append([]string(nil), names...), nil
+}
+
+func (zd *zipDevice) Glob(pattern string) (paths []string, err error) {
+	return glob(zd, pattern)
+}
+
 type multiDevice []Device
 
 func (md multiDevice) Open(path string) (rc io.ReadCloser, err error) {
@@ -83,6 +181,78 @@ func (md multiDevice) Open(path string) (rc io.ReadCloser, err error) {
 	return
 }
 
+// List merges the contents of the directory in all the devices that have
+// it.
+func (md multiDevice) List(dir string) (names []string, err error) {
+	seen := make(map[string]bool)
+	found := false
+	for _, d := range ([]Device)(md) {
+		devNames, devErr := d.List(dir)
+		if devErr != nil {
+			err = devErr
+			continue
+		}
+		found = true
+		for _, name := range devNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	if !found {
+		return
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (md multiDevice) Glob(pattern string) (paths []string, err error) {
+	return glob(md, pattern)
+}
+
+// glob implements Glob for any device by listing the directories that can
+// match the pattern.
+func glob(d Device, pattern string) (paths []string, err error) {
+	// Check the pattern syntax once, so that bad patterns don't just match
+	// nothing.
+	if _, err = path.Match(pattern, ""); err != nil {
+		return
+	}
+	globDir(d, "", strings.Split(cleanDir(pattern), "/"), &paths)
+	sort.Strings(paths)
+	return
+}
+
+func globDir(d Device, dir string, parts []string, paths *[]string) {
+	names, err := d.List(dir)
+	if err != nil {
+		return
+	}
+	last := len(parts) == 1
+	for _, name := range names {
+		isDir := strings.HasSuffix(name, "/")
+		if isDir == last {
+			continue
+		}
+		name = strings.TrimSuffix(name, "/")
+		if ok, _ := path.Match(parts[0], name); !ok {
+			continue
+		}
+		if last {
+			*paths = append(*paths, path.Join(dir, name))
+		} else {
+			globDir(d, path.Join(dir, name), parts[1:], paths)
+		}
+	}
+}
+
+// cleanDir normalizes a directory path, with the root as "".
+func cleanDir(dir string) string {
+	dir = path.Clean("/" + dir)
+	return strings.TrimPrefix(dir, "/")
+}
+
 func New(devs ...Device) Device {
 	return multiDevice(devs)
 }
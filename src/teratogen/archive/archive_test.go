@@ -0,0 +1,174 @@
+// archive_test.go
+//
+// Copyright (C) 2012 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testFiles = map[string]string{
+	"chars.png":             "chars",
+	"chunks/caves.txt":      "caves",
+	"chunks/rooms.txt":      "rooms",
+	"chunks/old/rooms.txt":  "old rooms",
+	"monsters/zombie.txt":   "zombie",
+	"sounds/bank1/boom.wav": "boom",
+}
+
+func testZip(t *testing.T, files map[string]string) Device {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	// An explicit directory entry.
+	w.Create("empty/")
+	for name, data := range files {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newZipDevice(r)
+}
+
+func testDirDevice(t *testing.T, files map[string]string) (Device, string) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePack(t, dir, files)
+	os.Mkdir(filepath.Join(dir, "empty"), 0755)
+	d, _ := FsDevice(dir)
+	return d, dir
+}
+
+func checkListing(t *testing.T, d Device) {
+	for dir, expect := range map[string][]string{
+		"":        {"chars.png", "chunks/", "empty/", "monsters/", "sounds/"},
+		"chunks":  {"caves.txt", "old/", "rooms.txt"},
+		"chunks/": {"caves.txt", "old/", "rooms.txt"},
+		"empty":   {},
+	} {
+		names, err := d.List(dir)
+		if err != nil {
+			t.Errorf("List %q: %s", dir, err)
+			continue
+		}
+		if len(names) != 0 || len(expect) != 0 {
+			if !reflect.DeepEqual(names, expect) {
+				t.Errorf("List %q: expected %v, got %v", dir, expect, names)
+			}
+		}
+	}
+	if _, err := d.List("nonexistent"); err == nil {
+		t.Error("Listed a missing directory")
+	}
+
+	for pattern, expect := range map[string][]string{
+		"chunks/*.txt":   {"chunks/caves.txt", "chunks/rooms.txt"},
+		"*/rooms.txt":    {"chunks/rooms.txt"},
+		"*/*/*.wav":      {"sounds/bank1/boom.wav"},
+		"monsters/z*":    {"monsters/zombie.txt"},
+		"chunks":         nil,
+		"nothing/*":      nil,
+		"chunks/[ac]*.*": {"chunks/caves.txt"},
+	} {
+		paths, err := d.Glob(pattern)
+		if err != nil {
+			t.Errorf("Glob %q: %s", pattern, err)
+		}
+		if !reflect.DeepEqual(paths, expect) {
+			t.Errorf("Glob %q: expected %v, got %v", pattern, expect, paths)
+		}
+	}
+	if _, err := d.Glob("[bad"); err == nil {
+		t.Error("Bad pattern accepted")
+	}
+
+	if got := readFile(t, d, "chunks/old/rooms.txt"); got != "old rooms" {
+		t.Errorf("Read %q", got)
+	}
+}
+
+func TestZipDevice(t *testing.T) {
+	zd := testZip(t, testFiles)
+	checkListing(t, zd)
+	if _, err := zd.Open("chunks"); err == nil {
+		t.Error("Opened a directory")
+	}
+}
+
+func TestFsDevice(t *testing.T) {
+	d, dir := testDirDevice(t, testFiles)
+	defer os.RemoveAll(dir)
+	checkListing(t, d)
+}
+
+func TestMultiDevice(t *testing.T) {
+	first, second := map[string]string{}, map[string]string{}
+	i := 0
+	for name, data := range testFiles {
+		if i%2 == 0 {
+			first[name] = data
+		} else {
+			second[name] = data
+		}
+		i++
+	}
+	// Shared files come from the first device.
+	first["chunks/rooms.txt"] = "rooms"
+	second["chunks/rooms.txt"] = "other rooms"
+
+	d, dir := testDirDevice(t, second)
+	defer os.RemoveAll(dir)
+	md := New(testZip(t, first), d)
+	checkListing(t, md)
+	if got := readFile(t, md, "chunks/rooms.txt"); got != "rooms" {
+		t.Errorf("Read %q", got)
+	}
+}
+
+func BenchmarkZipOpen(b *testing.B) {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for i := 0; i < 1000; i++ {
+		w.Create(path.Join("dir", string(rune('a'+i%26)), string(rune('a'+i/26))))
+	}
+	w.Create("last")
+	w.Close()
+	r, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	zd := newZipDevice(r)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rc, err := zd.Open("last")
+		if err != nil {
+			b.Fatal(err)
+		}
+		rc.Close()
+	}
+}
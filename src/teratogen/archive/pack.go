@@ -0,0 +1,132 @@
+// pack.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest file at the root of every pack.
+const ManifestFile = "pack.json"
+
+// Manifest describes an asset pack.
+type Manifest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Order is the load order of the pack. Packs with a higher order are
+	// loaded later and override the files of the packs loaded before them.
+	Order int `json:"order"`
+}
+
+// Pack is an asset or mod pack, a directory or a zip file with a manifest
+// whose files override the base assets of the game.
+type Pack struct {
+	Manifest
+	// Path is where the pack was loaded from.
+	Path   string
+	Device Device
+}
+
+// OpenPack opens a pack from a directory or a zip file.
+func OpenPack(path string) (pack *Pack, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	var d Device
+	if info.IsDir() {
+		d, err = FsDevice(path)
+	} else {
+		d, err = FileZipDevice(path)
+	}
+	if err != nil {
+		return
+	}
+
+	rc, err := d.Open(ManifestFile)
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Pack '%s' has no %s", path, ManifestFile))
+		return
+	}
+	defer rc.Close()
+	pack = &Pack{Path: path, Device: d}
+	if err = json.NewDecoder(rc).Decode(&pack.Manifest); err != nil {
+		err = errors.New(fmt.Sprintf("Bad manifest in pack '%s': %s", path, err))
+		return nil, err
+	}
+	if pack.Name == "" {
+		err = errors.New(fmt.Sprintf("Pack '%s' has no name", path))
+		return nil, err
+	}
+	return
+}
+
+// FindPacks opens the packs in a directory, which are the subdirectories and
+// zip files in it. The packs are returned in file name order. Bad packs are
+// skipped and reported in errs. A missing directory has no packs.
+func FindPacks(dir string) (packs []*Pack, errs []error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+		return
+	}
+	for _, info := range infos {
+		if !info.IsDir() && strings.ToLower(filepath.Ext(info.Name())) != ".zip" {
+			continue
+		}
+		pack, err := OpenPack(filepath.Join(dir, info.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		packs = append(packs, pack)
+	}
+	return
+}
+
+// SortPacks sorts packs into load order. Packs with the same order keep
+// their relative positions.
+func SortPacks(packs []*Pack) {
+	sort.Stable(packOrder(packs))
+}
+
+type packOrder []*Pack
+
+func (p packOrder) Len() int           { return len(p) }
+func (p packOrder) Less(i, j int) bool { return p[i].Order < p[j].Order }
+func (p packOrder) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// Overlay returns a device where the files of the packs override those of
+// the base device. The packs must be in load order, so the files of the last
+// pack have the highest priority.
+func Overlay(base Device, packs []*Pack) Device {
+	devs := []Device{}
+	for i := len(packs) - 1; i >= 0; i-- {
+		devs = append(devs, packs[i].Device)
+	}
+	return New(append(devs, base)...)
+}
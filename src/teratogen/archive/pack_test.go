@@ -0,0 +1,103 @@
+// pack_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePack writes a pack directory, or a zip file if the path ends with
+// .zip, with the given files.
+func writePack(t *testing.T, path string, files map[string]string) {
+	if !strings.HasSuffix(path, ".zip") {
+		for name, data := range files {
+			p := filepath.Join(path, name)
+			os.MkdirAll(filepath.Dir(p), 0755)
+			if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, data := range files {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPacks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writePack(t, filepath.Join(dir, "base"), map[string]string{
+		"tiles.png": "base tiles", "chars.png": "base chars", "items.png": "base items"})
+	mods := filepath.Join(dir, "mods")
+	writePack(t, filepath.Join(mods, "b-tiles"), map[string]string{
+		ManifestFile: `{"name": "Tiles", "version": "1.0", "order": 5}`,
+		"tiles.png":  "pack tiles"})
+	writePack(t, filepath.Join(mods, "a-sprites.zip"), map[string]string{
+		ManifestFile: `{"name": "Sprites", "version": "0.2", "order": 10}`,
+		"chars.png":  "pack chars",
+		"tiles.png":  "zip tiles"})
+	writePack(t, filepath.Join(mods, "broken"), map[string]string{
+		"tiles.png": "no manifest"})
+	writePack(t, filepath.Join(mods, "readme.txt"), map[string]string{})
+
+	packs, errs := FindPacks(mods)
+	if len(errs) != 1 {
+		t.Errorf("Expected an error for the broken pack, got %v", errs)
+	}
+	if len(packs) != 2 || packs[0].Name != "Sprites" || packs[1].Version != "1.0" {
+		t.Fatalf("Bad packs %v", packs)
+	}
+
+	SortPacks(packs)
+	if packs[0].Name != "Tiles" {
+		t.Errorf("Bad load order %v", packs)
+	}
+
+	base, _ := FsDevice(filepath.Join(dir, "base"))
+	d := Overlay(base, packs)
+	for path, expect := range map[string]string{
+		"tiles.png": "zip tiles", "chars.png": "pack chars", "items.png": "base items"} {
+		if got := readFile(t, d, path); got != expect {
+			t.Errorf("%s: expected %q, got %q", path, expect, got)
+		}
+	}
+
+	if packs, errs := FindPacks(filepath.Join(dir, "nonexistent")); packs != nil || errs != nil {
+		t.Error("Missing mod directory should have no packs")
+	}
+}
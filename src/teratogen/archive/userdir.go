@@ -0,0 +1,66 @@
+// userdir.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// UserDataDir returns the per-user directory where an application should
+// keep its data, like save files and configuration. On Linux and other
+// Unix systems this follows the XDG base directory specification.
+func UserDataDir(appName string) (dir string, err error) {
+	switch runtime.GOOS {
+	case "windows":
+		base := os.Getenv("APPDATA")
+		if base == "" {
+			return "", errors.New("APPDATA is not set")
+		}
+		return filepath.Join(base, appName), nil
+	case "darwin":
+		home := os.Getenv("HOME")
+		if home == "" {
+			return "", errors.New("HOME is not set")
+		}
+		return filepath.Join(home, "Library", "Application Support", appName), nil
+	}
+
+	// XDG_DATA_HOME must be an absolute path to be used, otherwise the
+	// default is used.
+	if base := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(base) {
+		return filepath.Join(base, appName), nil
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		return "", errors.New("HOME is not set")
+	}
+	return filepath.Join(home, ".local", "share", appName), nil
+}
+
+// UserDevice returns a writable device for the per-user data directory of
+// an application, creating the directory if needed.
+func UserDevice(appName string) (wd WritableDevice, err error) {
+	dir, err := UserDataDir(appName)
+	if err != nil {
+		return
+	}
+	return WritableFsDevice(dir)
+}
@@ -0,0 +1,151 @@
+// writable.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WritableDevice is a device that files can also be written to.
+type WritableDevice interface {
+	Device
+
+	// Create opens a file for writing, creating any missing parent
+	// directories. The write is atomic: the file is written under a
+	// temporary name and only replaces the old file when it is closed
+	// without errors.
+	Create(path string) (wc io.WriteCloser, err error)
+
+	// Remove removes a file.
+	Remove(path string) error
+}
+
+// writePath returns the native path of a file in the device. Paths that
+// lead outside the device root are errors.
+func (fd fsDevice) writePath(path string) (target string, err error) {
+	target = filepath.Join(string(fd), path)
+	rel, err := filepath.Rel(string(fd), target)
+	if err == nil && (rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		err = errors.New(fmt.Sprintf("Path '%s' is outside the device", path))
+	}
+	return
+}
+
+func (fd fsDevice) Create(path string) (wc io.WriteCloser, err error) {
+	target, err := fd.writePath(path)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(target)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(target)+".tmp")
+	if err != nil {
+		return
+	}
+	return &atomicFile{file: f, target: target}, nil
+}
+
+func (fd fsDevice) Remove(path string) error {
+	target, err := fd.writePath(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(target)
+}
+
+// WritableFsDevice returns a writable archive device for a native
+// filesystem path. The directory is created if it doesn't exist.
+func WritableFsDevice(rootPath string) (wd WritableDevice, err error) {
+	path, err := filepath.Abs(rootPath)
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return
+	}
+	return fsDevice(path), nil
+}
+
+// WriteFile writes data to a file in a writable device.
+func WriteFile(d WritableDevice, path string, data []byte) error {
+	wc, err := d.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = wc.Write(data)
+	if cerr := wc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// atomicFile writes into a temporary file and renames it over the target
+// file when closed. If anything fails, the temporary file is removed and the
+// target is left untouched.
+type atomicFile struct {
+	file   *os.File
+	target string
+	err    error
+}
+
+func (af *atomicFile) Write(p []byte) (n int, err error) {
+	if af.err != nil {
+		return 0, af.err
+	}
+	n, err = af.file.Write(p)
+	af.err = err
+	return
+}
+
+func (af *atomicFile) Close() error {
+	if af.file == nil {
+		return errors.New(fmt.Sprintf("File '%s' already closed", af.target))
+	}
+	temp := af.file.Name()
+	err := af.err
+	if err == nil {
+		err = af.file.Sync()
+	}
+	if cerr := af.file.Close(); err == nil {
+		err = cerr
+	}
+	af.file = nil
+	if err == nil {
+		err = os.Rename(temp, af.target)
+	}
+	if err != nil {
+		os.Remove(temp)
+	}
+	return err
+}
+
+// isTempFile returns whether a file name is one used for unfinished atomic
+// writes.
+func isTempFile(name string) bool {
+	matched, _ := filepath.Match(".*.tmp*", name)
+	return matched
+}
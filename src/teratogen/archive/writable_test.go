@@ -0,0 +1,179 @@
+// writable_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package archive
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func tempDevice(t *testing.T) (WritableDevice, string) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := WritableFsDevice(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, dir
+}
+
+func readFile(t *testing.T, d Device, path string) string {
+	rc, err := d.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAtomicWrite(t *testing.T) {
+	d, dir := tempDevice(t)
+	defer os.RemoveAll(dir)
+
+	if err := WriteFile(d, "saves/game.sav", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, d, "saves/game.sav"); got != "first" {
+		t.Errorf("Read %q", got)
+	}
+
+	// The old file stays until the new one is closed.
+	wc, err := d.Create("saves/game.sav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wc.Write([]byte("second"))
+	if got := readFile(t, d, "saves/game.sav"); got != "first" {
+		t.Errorf("Unfinished write visible: %q", got)
+	}
+	if names, _ := d.List("saves"); !reflect.DeepEqual(names, []string{"game.sav"}) {
+		t.Errorf("Temporary file listed: %v", names)
+	}
+	if err := wc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, d, "saves/game.sav"); got != "second" {
+		t.Errorf("Read %q", got)
+	}
+	if err := wc.Close(); err == nil {
+		t.Error("Closed twice")
+	}
+}
+
+func TestFailedWrite(t *testing.T) {
+	d, dir := tempDevice(t)
+	defer os.RemoveAll(dir)
+
+	WriteFile(d, "config", []byte("good"))
+	wc, _ := d.Create("config")
+	af := wc.(*atomicFile)
+	af.Write([]byte("bad"))
+	af.err = errors.New("disk full")
+	if err := wc.Close(); err == nil {
+		t.Error("Failed write closed without error")
+	}
+	if got := readFile(t, d, "config"); got != "good" {
+		t.Errorf("Failed write replaced file: %q", got)
+	}
+	infos, _ := ioutil.ReadDir(filepath.Join(dir, "data"))
+	if len(infos) != 1 {
+		t.Errorf("Temporary file left behind")
+	}
+}
+
+func TestWriteOutsideDevice(t *testing.T) {
+	d, dir := tempDevice(t)
+	defer os.RemoveAll(dir)
+
+	if err := WriteFile(d, "../escaped", []byte("bad")); err == nil {
+		t.Error("Wrote a file outside the device")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); err == nil {
+		t.Error("File written outside the device")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "outside"), []byte("keep"), 0644)
+	if err := d.Remove("saves/../../outside"); err == nil {
+		t.Error("Removed a file outside the device")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside")); err != nil {
+		t.Error("File outside the device removed")
+	}
+
+	if err := WriteFile(d, "saves/../config", []byte("good")); err != nil {
+		t.Errorf("Path that stays in the device rejected: %s", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	d, dir := tempDevice(t)
+	defer os.RemoveAll(dir)
+
+	for _, path := range []string{"b", "a", "morgue/1.txt", "saves/x.sav"} {
+		if err := WriteFile(d, path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := d.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "morgue/", "saves/"}) {
+		t.Errorf("Bad listing %v", names)
+	}
+	if err := d.Remove("a"); err != nil {
+		t.Error(err)
+	}
+	if _, err := d.List("nonexistent"); err == nil {
+		t.Error("Listed a missing directory")
+	}
+}
+
+func TestUserDataDir(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("XDG only")
+	}
+	oldXdg, oldHome := os.Getenv("XDG_DATA_HOME"), os.Getenv("HOME")
+	defer os.Setenv("XDG_DATA_HOME", oldXdg)
+	defer os.Setenv("HOME", oldHome)
+
+	os.Setenv("HOME", "/home/user")
+	os.Setenv("XDG_DATA_HOME", "/data")
+	if dir, _ := UserDataDir("game"); dir != "/data/game" {
+		t.Errorf("Bad XDG dir %s", dir)
+	}
+	os.Setenv("XDG_DATA_HOME", "relative")
+	if dir, _ := UserDataDir("game"); dir != "/home/user/.local/share/game" {
+		t.Errorf("Bad default dir %s", dir)
+	}
+	os.Setenv("XDG_DATA_HOME", "")
+	if dir, _ := UserDataDir("game"); dir != "/home/user/.local/share/game" {
+		t.Errorf("Bad default dir %s", dir)
+	}
+}
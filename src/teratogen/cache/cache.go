@@ -20,38 +20,74 @@
 package cache
 
 import (
+	"errors"
+	"fmt"
 	"image"
+	"image/color"
+	"io"
+	"path"
+	"strings"
 	"teratogen/archive"
 	"teratogen/font"
 	"teratogen/gfx"
-	"teratogen/sdl"
 )
 
+// SpriteManifest is the file that defines the named sprites on the sprite
+// sheets. SpriteDir is the directory of the individual sprite images that
+// are packed into the sprite atlas. PaletteFile is the file for the base
+// colors of the paletted display.
+const (
+	SpriteManifest = "assets/sprites.json"
+	SpriteDir      = "assets/sprites"
+	PaletteFile    = "assets/palette.gpl"
+)
+
+// AtlasSheetSize is the size of the sprite atlas sheets.
+var AtlasSheetSize = image.Pt(256, 256)
+
 type Cache struct {
 	fs       archive.Device
-	surfaces map[surfaceSpec]*sdl.Surface
+	surfaces map[surfaceSpec]gfx.Surface
 	fonts    map[font.Spec]*font.Font
+	palettes map[string]color.Palette
+
+	// The sprite manifest is loaded when the first image is needed and the
+	// sprite atlas when the first sprite is needed.
+	manifest      *gfx.SpriteManifest
+	spritesLoaded bool
 }
 
 func New(fs archive.Device) (result *Cache) {
 	result = new(Cache)
 	result.fs = fs
-	result.surfaces = make(map[surfaceSpec]*sdl.Surface)
+	result.surfaces = make(map[surfaceSpec]gfx.Surface)
 	result.fonts = make(map[font.Spec]*font.Font)
+	result.palettes = make(map[string]color.Palette)
 	return
 }
 
 func (c *Cache) CheckImageSpec(spec gfx.ImageSpec) error {
-	_, err := c.getSurface(surfaceSpec{spec.File})
+	_, err := c.TryGetDrawable(spec)
 	return err
 }
 
-func (c *Cache) GetDrawable(spec gfx.ImageSpec) gfx.Drawable {
+func (c *Cache) TryGetDrawable(spec gfx.ImageSpec) (result gfx.Drawable, err error) {
+	if spec.IsNamed() {
+		return c.getNamedDrawable(spec)
+	}
 	surface, err := c.getSurface(surfaceSpec{spec.File})
 	if err != nil {
+		return
+	}
+	return gfx.ImageDrawable{Surface: surface, Rect: spec.Bounds, Offset: spec.Offset}, nil
+}
+
+func (c *Cache) GetDrawable(spec gfx.ImageSpec) (result gfx.Drawable) {
+	var err error
+	if result, err = c.TryGetDrawable(spec); err != nil {
 		panic(err)
 	}
-	return gfx.ImageDrawable{surface, spec.Bounds, spec.Offset}
+	return
 }
 
 func (c *Cache) TryGetFont(spec font.Spec) (result *font.Font, err error) {
@@ -74,7 +110,31 @@ func (c *Cache) GetFont(spec font.Spec) (result *font.Font) {
 	return
 }
 
-func (c *Cache) getSurface(spec surfaceSpec) (result *sdl.Surface, err error) {
+func (c *Cache) TryGetPalette(file string) (result color.Palette, err error) {
+	result, ok := c.palettes[file]
+	if !ok {
+		var r io.ReadCloser
+		if r, err = c.fs.Open(file); err != nil {
+			return
+		}
+		defer r.Close()
+		if result, err = gfx.ParsePalette(r); err != nil {
+			return
+		}
+		c.palettes[file] = result
+	}
+	return
+}
+
+func (c *Cache) GetPalette(file string) (result color.Palette) {
+	var err error
+	if result, err = c.TryGetPalette(file); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (c *Cache) getSurface(spec surfaceSpec) (result gfx.Surface, err error) {
 	result, ok := c.surfaces[spec]
 	if !ok {
 		var png image.Image
@@ -82,16 +142,141 @@ func (c *Cache) getSurface(spec surfaceSpec) (result *sdl.Surface, err error) {
 		if err != nil {
 			return
 		}
-		result = sdl.ToSurface(png)
 
-		// XXX: Hardcoding the same colorkey for all images.
-		result.SetColorKey(gfx.Cyan)
+		var settings gfx.ImageSettings
+		if settings, err = c.imageSettings(spec.File); err != nil {
+			return
+		}
+		if settings.ColorKey == nil {
+			result = gfx.NewBlendedSurface(png)
+		} else {
+			result = gfx.ToSurface(png)
+			result.SetColorKey(settings.ColorKey)
+		}
 
 		c.surfaces[spec] = result
 	}
 	return
 }
 
+// TryGetSpriteDef returns the definition of a named sprite.
+func (c *Cache) TryGetSpriteDef(name string) (def gfx.SpriteDef, err error) {
+	if !c.spritesLoaded {
+		if err = c.loadSprites(); err != nil {
+			return
+		}
+	}
+	def, ok := c.manifest.Sprites[name]
+	if !ok {
+		err = errors.New(fmt.Sprintf("No sprite named '%s'", name))
+	}
+	return
+}
+
+func (c *Cache) GetSpriteDef(name string) (def gfx.SpriteDef) {
+	var err error
+	if def, err = c.TryGetSpriteDef(name); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (c *Cache) getNamedDrawable(spec gfx.ImageSpec) (result gfx.Drawable, err error) {
+	def, err := c.TryGetSpriteDef(spec.File)
+	if err != nil {
+		return
+	}
+	if spec.Frame < 0 || spec.Frame >= len(def.Frames) {
+		err = errors.New(fmt.Sprintf("Sprite '%s' has no frame %d", spec.File, spec.Frame))
+		return
+	}
+	frame := def.Frames[spec.Frame]
+	surface, err := c.getSurface(surfaceSpec{frame.File})
+	if err != nil {
+		return
+	}
+	center := frame.Bounds.Size().Div(2)
+	return gfx.ImageDrawable{
+		Surface: surface,
+		Rect:    frame.Bounds,
+		Offset:  spec.Offset.Sub(center).Add(frame.Offset)}, nil
+}
+
+// loadManifest loads the sprite manifest if it isn't loaded yet. Missing is
+// set if there is no manifest file.
+func (c *Cache) loadManifest() (missing bool, err error) {
+	if c.manifest != nil {
+		return
+	}
+	r, err := c.fs.Open(SpriteManifest)
+	if err != nil {
+		return true, err
+	}
+	defer r.Close()
+	c.manifest, err = gfx.ParseSpriteManifest(r)
+	return
+}
+
+// imageSettings returns the manifest settings of an image file. Without a
+// sprite manifest, all images get the default settings.
+func (c *Cache) imageSettings(file string) (settings gfx.ImageSettings, err error) {
+	if missing, err := c.loadManifest(); missing {
+		return gfx.DefaultImageSettings, nil
+	} else if err != nil {
+		return settings, err
+	}
+	return c.manifest.ImageSettings(file), nil
+}
+
+// loadAtlas packs all the PNG images in the sprite directory into an atlas.
+// The images are named by their file names without the .png extension.
+func (c *Cache) loadAtlas() (atlas *gfx.Atlas, err error) {
+	paths, err := c.fs.Glob(path.Join(SpriteDir, "*.png"))
+	if err != nil {
+		return
+	}
+	images := make(map[string]image.Image)
+	for _, p := range paths {
+		var img image.Image
+		if img, err = archive.LoadPng(c.fs, p); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", p, err))
+		}
+		images[strings.TrimSuffix(path.Base(p), ".png")] = img
+	}
+	return gfx.PackAtlas(images, AtlasSheetSize)
+}
+
+// loadSprites loads the sprite manifest and packs the sprite images into
+// the atlas. An image in the sprite directory replaces a manifest sprite
+// with the same name.
+func (c *Cache) loadSprites() (err error) {
+	if _, err = c.loadManifest(); err != nil {
+		return
+	}
+	atlas, err := c.loadAtlas()
+	if err != nil {
+		return
+	}
+
+	// The atlas sheets go in the surface cache under made up file names.
+	// They keep the alpha of the sprite images.
+	for i, sheet := range atlas.Sheets {
+		c.surfaces[surfaceSpec{atlasSheetFile(i)}] = gfx.NewBlendedSurface(sheet)
+	}
+	for _, name := range atlas.Names() {
+		sprite, _ := atlas.Sprite(name)
+		c.manifest.Sprites[name] = gfx.SpriteDef{Frames: []gfx.ImageSpec{
+			gfx.SubImage(atlasSheetFile(sprite.Sheet), sprite.Rect)}}
+	}
+
+	c.spritesLoaded = true
+	return
+}
+
+func atlasSheetFile(sheet int) string {
+	return fmt.Sprintf("%s#%d", SpriteDir, sheet)
+}
+
 type surfaceSpec struct {
 	File string
 }
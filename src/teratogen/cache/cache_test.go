@@ -0,0 +1,63 @@
+// cache_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cache
+
+import (
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"teratogen/archive"
+	"teratogen/gfx"
+	"testing"
+)
+
+func TestPlainImageWithoutManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file, err := os.Create(filepath.Join(dir, "plain.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = png.Encode(file, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := archive.FsDevice(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gfx.SetBackend(gfx.RGBABackend{})
+	c := New(fs)
+	drawable, err := c.TryGetDrawable(gfx.SubImage("plain.png", image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatalf("Plain image needed a sprite manifest: %s", err)
+	}
+	if _, ok := drawable.(gfx.ImageDrawable).Surface.ColorKey(); !ok {
+		t.Errorf("Plain image didn't get the default settings")
+	}
+	if _, err := c.TryGetSpriteDef("wall"); err == nil {
+		t.Errorf("Got a sprite without a sprite manifest")
+	}
+}
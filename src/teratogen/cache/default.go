@@ -0,0 +1,120 @@
+// default.go
+//
+// Copyright (C) 2012 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"teratogen/archive"
+)
+
+var globalCache *Cache = nil
+
+// ModDir is the directory under the working directory and the user data
+// directory where asset packs are looked for.
+const ModDir = "mods"
+
+// extraPacks are the paths of asset packs given on the command line.
+var extraPacks []string
+
+// AddPacks adds asset packs to load in addition to the ones in the mod
+// directories. Packs with the same load order are loaded in the order they
+// are added, after the ones in the mod directories. Must be called before
+// the assets are first used.
+func AddPacks(paths ...string) {
+	if globalCache != nil {
+		panic("Adding packs after the assets have been loaded")
+	}
+	extraPacks = append(extraPacks, paths...)
+}
+
+// loadPacks opens the asset packs and returns them in load order. Bad packs
+// are reported and skipped.
+func loadPacks() []*archive.Pack {
+	dirs := []string{ModDir}
+	if userDir, err := archive.UserDataDir("teratogen"); err == nil {
+		dirs = append(dirs, filepath.Join(userDir, ModDir))
+	}
+
+	var packs []*archive.Pack
+	var errs []error
+	for _, dir := range dirs {
+		found, dirErrs := archive.FindPacks(dir)
+		packs = append(packs, found...)
+		errs = append(errs, dirErrs...)
+	}
+	for _, path := range extraPacks {
+		pack, err := archive.OpenPack(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		packs = append(packs, pack)
+	}
+
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "Skipping asset pack:", err)
+	}
+	archive.SortPacks(packs)
+	return packs
+}
+
+// Set up a file archive that first looks for files in the asset packs, then
+// in the local physical filesystem path, then in a zip file contained in the
+// local binary.
+func initArchive() (fs archive.Device, err error) {
+	var devices = make([]archive.Device, 0)
+
+	fd, err := archive.FsDevice(".")
+	if err != nil {
+		// If the file system path won't work, things are bad.
+		return
+	}
+	devices = append(devices, fd)
+
+	zd, zerr := archive.FileZipDevice(os.Args[0])
+	// If the self exe isn't a zip, just don't add the device. Things still
+	// work if the assets can be found in the filesystem.
+	if zerr == nil {
+		devices = append(devices, zd)
+	}
+
+	return archive.Overlay(archive.New(devices...), loadPacks()), nil
+}
+
+// SetArchive makes the assets load from the given device instead of the
+// default archive. It must be called before the assets are first used.
+func SetArchive(fs archive.Device) {
+	if globalCache != nil {
+		panic("Setting the archive after the assets have been loaded")
+	}
+	globalCache = New(fs)
+}
+
+// Get returns the cache of the default asset archive.
+func Get() *Cache {
+	if globalCache == nil {
+		fs, err := initArchive()
+		if err != nil {
+			panic(err)
+		}
+		globalCache = New(fs)
+	}
+	return globalCache
+}
@@ -21,11 +21,10 @@ package anim
 
 import (
 	"image"
-	"teratogen/app"
+	"teratogen/cache"
 	"teratogen/display/util"
 	"teratogen/gfx"
 	"teratogen/space"
-	"time"
 )
 
 type Animation interface {
@@ -55,7 +54,7 @@ func (af animationFrame) Draw(offset image.Point) {
 }
 
 func now() int64 {
-	return time.Now().UnixNano()
+	return util.Now()
 }
 
 type animationStore struct {
@@ -106,7 +105,7 @@ func (a *Anim) CollectSpritesAt(
 		// Create sprites from the current frames of live animations.
 		sprites = append(
 			sprites,
-			gfx.Sprite{layer, screenPos, animStore.CurrentFrame()})
+			gfx.Sprite{Layer: layer, Offset: screenPos, Drawable: animStore.CurrentFrame()})
 	}
 	return sprites
 }
@@ -132,10 +131,25 @@ func (c Cycle) Frame(t int64) gfx.Drawable {
 	return c.Frames[idx]
 }
 
+// Duration returns the time it takes to show all the frames once.
+func (c Cycle) Duration() int64 {
+	return c.TimePerFrame * int64(len(c.Frames))
+}
+
+// NamedCycle makes a cycle from a named sprite with frame timing.
+func NamedCycle(name string) Cycle {
+	def := cache.Get().GetSpriteDef(name)
+	var frames []gfx.Drawable
+	for i := range def.Frames {
+		frames = append(frames, cache.Get().GetDrawable(util.SpriteFrame(name, i)))
+	}
+	return Cycle{def.TimePerFrame, frames, def.Loops}
+}
+
 func NewCycle(timePerFrame int64, loops bool, frameSpecs []gfx.ImageSpec) Cycle {
 	var frames []gfx.Drawable
 	for _, spec := range frameSpecs {
-		frames = append(frames, app.Cache().GetDrawable(spec))
+		frames = append(frames, cache.Get().GetDrawable(spec))
 	}
 
 	return Cycle{timePerFrame, frames, loops}
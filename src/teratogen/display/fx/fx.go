@@ -20,11 +20,12 @@
 package fx
 
 import (
+	"fmt"
 	"image"
 	"teratogen/display/anim"
 	"teratogen/display/util"
+	"teratogen/event"
 	"teratogen/gfx"
-	"teratogen/sdl"
 	"teratogen/space"
 	"teratogen/world"
 )
@@ -57,6 +58,20 @@ func New(a *anim.Anim, w *world.World) *Fx {
 	return &Fx{anim: a, world: w}
 }
 
+// Handle shows the visual effects for game events. Subscribe it to the
+// event bus of the game.
+func (f *Fx) Handle(e event.Event) {
+	switch e := e.(type) {
+	case event.Damaged:
+		f.Blast(e.Loc, BloodSquib)
+	case event.Shot:
+		if e.HitWall {
+			f.Blast(e.End, Sparks)
+		}
+		f.Beam(e.From, e.Dir, e.Dist, GunBeam)
+	}
+}
+
 // Msgf prints a formatted message to the 
 func (f *Fx) Msgf(format string, a ...interface{}) {
 	// TODO: Get messaging system attached, deploy there.
@@ -83,7 +98,7 @@ func (f *Fx) Beam(origin space.Location, dir image.Point, length int, kind BeamK
 	f.anim.Add(
 		anim.Func(func(t int64, offset image.Point) {
 			gfx.Line(
-				sdl.Frame(),
+				gfx.Frame(),
 				offset.Add(util.HalfTile),
 				offset.Add(util.HalfTile).Add(screenVec),
 				gfx.LerpCol(gfx.Gold, gfx.Black, float64(t)/float64(.5e9)))
@@ -92,40 +107,21 @@ func (f *Fx) Beam(origin space.Location, dir image.Point, length int, kind BeamK
 
 // Blast generates an explosion effect in the game world.
 func (f *Fx) Blast(loc space.Location, kind BlastKind) {
-	switch kind {
-	case SmallExplosion:
-		frames := anim.NewCycle(.1e9, false, util.SmallIcons(util.Items, 32, 33, 34, 35))
-		f.anim.Add(
-			anim.Func(func(t int64, offset image.Point) {
-				frames.Frame(t).Draw(offset)
-			}), space.SimpleFootprint(loc), .4e9)
-	case LargeExplosion:
-		frames := anim.NewCycle(.10e9, false, util.LargeIcons(util.Items, 5, 6, 7, 8, 9))
-		f.anim.Add(
-			anim.Func(func(t int64, offset image.Point) {
-				frames.Frame(t).Draw(offset)
-			}), space.SimpleFootprint(loc), .5e9)
-	case Sparks:
-		frames := anim.NewCycle(.07e9, false, util.SmallIcons(util.Items, 36, 37, 38))
-		f.anim.Add(
-			anim.Func(func(t int64, offset image.Point) {
-				frames.Frame(t).Draw(offset)
-			}), space.SimpleFootprint(loc), .21e9)
-	case BloodSquib:
-		frames := anim.NewCycle(.07e9, false, util.SmallIcons(util.Items, 39, 40, 41))
-		f.anim.Add(
-			anim.Func(func(t int64, offset image.Point) {
-				frames.Frame(t).Draw(offset)
-			}), space.SimpleFootprint(loc), .21e9)
-	case Smoke:
-		frames := anim.NewCycle(.07e9, false, util.SmallIcons(util.Items, 42, 43, 44))
-		f.anim.Add(
-			anim.Func(func(t int64, offset image.Point) {
-				frames.Frame(t).Draw(offset)
-			}), space.SimpleFootprint(loc), .21e9)
-	default:
-		println("Unknown blast kind ", kind)
-		return
+	name, ok := blastSprites[kind]
+	if !ok {
+		panic(fmt.Sprintf("Unknown blast kind %d", kind))
 	}
+	frames := anim.NamedCycle(name)
+	f.anim.Add(
+		anim.Func(func(t int64, offset image.Point) {
+			frames.Frame(t).Draw(offset)
+		}), space.SimpleFootprint(loc), frames.Duration())
+}
 
+var blastSprites = map[BlastKind]string{
+	SmallExplosion: "small-explosion",
+	LargeExplosion: "large-explosion",
+	Sparks:         "sparks",
+	BloodSquib:     "blood-squib",
+	Smoke:          "smoke",
 }
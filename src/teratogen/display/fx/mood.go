@@ -0,0 +1,103 @@
+// mood.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fx
+
+import (
+	"image/color"
+	"math"
+	"teratogen/display/util"
+	"teratogen/event"
+	"teratogen/gfx"
+	"teratogen/world"
+)
+
+// Timing of the palette effects in nanoseconds.
+const (
+	flashTime = .3e9
+	fadeTime  = 2e9
+	pulseTime = 1.5e9
+)
+
+var (
+	flashColor = color.RGBA{0xBE, 0x26, 0x33, 0xFF}
+	pulseColor = color.RGBA{0x40, 0x00, 0x00, 0xFF}
+)
+
+// Mood tints the screen palette to reflect the state of the player: a red
+// flash when hurt, a slow pulse at low health and a fade to black on death.
+type Mood struct {
+	world *world.World
+
+	flashing   bool
+	flashStart int64
+	fading     bool
+	fadeStart  int64
+}
+
+func NewMood(w *world.World) *Mood {
+	return &Mood{world: w}
+}
+
+// Handle starts palette effects for game events. Subscribe it to the event
+// bus of the game.
+func (m *Mood) Handle(e event.Event) {
+	switch e := e.(type) {
+	case event.Damaged:
+		if e.Target == m.world.Player {
+			m.flashing, m.flashStart = true, util.Now()
+		}
+	case event.Died:
+		if e.Entity == m.world.Player {
+			m.FadeOut()
+		}
+	}
+}
+
+// FadeOut starts fading the screen to black if it isn't fading already.
+func (m *Mood) FadeOut() {
+	if !m.fading {
+		m.fading, m.fadeStart = true, util.Now()
+	}
+}
+
+// IsFaded returns whether the screen has faded to black.
+func (m *Mood) IsFaded() bool {
+	return m.fading && util.Now()-m.fadeStart >= fadeTime
+}
+
+// Palette applies the current effects to a palette.
+func (m *Mood) Palette(base color.Palette) color.Palette {
+	t := util.Now()
+	pal := base
+
+	if stats, ok := m.world.Entities.Stats(m.world.Player); ok &&
+		stats.Health() > 0 && stats.Health()*4 <= stats.MaxHealth() {
+		phase := float64(t%pulseTime) / pulseTime
+		pal = gfx.LerpPalette(pal, pulseColor, .2*(1-math.Cos(2*math.Pi*phase)))
+	}
+
+	if dt := t - m.flashStart; m.flashing && dt < flashTime {
+		pal = gfx.LerpPalette(pal, flashColor, .6*(1-float64(dt)/flashTime))
+	}
+
+	if m.fading {
+		pal = gfx.LerpPalette(pal, gfx.Black, math.Min(1, float64(t-m.fadeStart)/fadeTime))
+	}
+
+	return pal
+}
@@ -0,0 +1,363 @@
+// golden_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package display
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"teratogen/action"
+	"teratogen/archive"
+	"teratogen/cache"
+	"teratogen/display/anim"
+	"teratogen/display/fx"
+	"teratogen/display/hud"
+	"teratogen/display/util"
+	"teratogen/display/view"
+	"teratogen/entity"
+	"teratogen/event"
+	"teratogen/factory"
+	"teratogen/gfx"
+	"teratogen/mapgen"
+	"teratogen/query"
+	"teratogen/space"
+	"teratogen/typography"
+	"teratogen/world"
+	"testing"
+)
+
+// Run "go test teratogen/display -update" to rewrite the golden images after
+// an intentional change to the display.
+var update = flag.Bool("update", false, "rewrite the golden images")
+
+const goldenDir = "testdata"
+
+// Pixels whose color channels differ by at most channelTolerance count as
+// equal. An image matches its golden if at most pixelTolerance pixels
+// differ.
+const (
+	channelTolerance = 8
+	pixelTolerance   = 16
+)
+
+var screenRect = image.Rect(0, 0, 320, 240)
+
+// The home map has walls in every IsoWallType configuration, furniture,
+// doors, a big mob (M) next to the player (@) and a portal (P) into the
+// portal map.
+const homeMap = `
+#################
+#.......#.......#
+#.b..c..#..p.p..#
+#.bb.t..|.......#
+#.......#..###..#
+#...#...#..#....#
+#...##..####..#.#
+#...............P
+#.....M.@.......#
+#..###......#...#
+#..#.#..........#
+#..###..........#
+#.....|.........#
+#..<....#.#.#...#
+#.......###.....#
+#...............#
+#################
+`
+
+const portalMap = `
+#########
+#.......#
+#..ctc..#
+P.......#
+#..p.p..#
+#.......#
+#########
+`
+
+const (
+	homeZone   = 1
+	portalZone = 2
+)
+
+var mapLegend = map[rune]world.Terrain{
+	'#': world.WallTerrain,
+	'.': world.FloorTerrain,
+	'|': world.DoorTerrain,
+	'<': world.StairTerrain,
+	'b': world.BarrelTerrain,
+	'c': world.ChairTerrain,
+	't': world.CounterTerrain,
+	'p': world.PlantTerrain,
+	'@': world.FloorTerrain,
+	'M': world.FloorTerrain,
+	'P': world.FloorTerrain,
+}
+
+// writeMap writes an ASCII map into a zone of the world and returns the
+// locations of the cells that aren't plain terrain.
+func writeMap(w *world.World, zone uint16, asciiMap string) map[rune]space.Location {
+	marks := map[rune]space.Location{}
+	for y, line := range strings.Split(strings.TrimSpace(asciiMap), "\n") {
+		for x, ch := range line {
+			ter, ok := mapLegend[ch]
+			if !ok {
+				panic("Unknown map cell " + string(ch))
+			}
+			loc := space.Loc(int16(x), int16(y), zone)
+			w.SetTerrain(loc, ter)
+			switch ch {
+			case '@', 'M', 'P':
+				marks[ch] = loc
+			}
+		}
+	}
+	return marks
+}
+
+var goldenInitDone = false
+
+// goldenInit sets up headless rendering that reads the assets from the
+// source tree and a display clock that doesn't move.
+func goldenInit(t testing.TB) {
+	if goldenInitDone {
+		return
+	}
+	fs, err := archive.FsDevice(filepath.Join("..", "..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetArchive(fs)
+	util.Now = func() int64 { return 0 }
+	gfx.SetBackend(gfx.RGBABackend{})
+	goldenInitDone = true
+}
+
+type goldenFixture struct {
+	world *world.World
+	anim  *anim.Anim
+}
+
+func newGoldenFixture(t *testing.T) *goldenFixture {
+	goldenInit(t)
+	rand.Seed(1)
+
+	w := world.New()
+	home := writeMap(w, homeZone, homeMap)
+	beyond := writeMap(w, portalZone, portalMap)
+	w.Manifold.SetPortalTo(home['P'], beyond['P'])
+
+	w.Player = factory.Spawn(factory.Player, w)
+	w.Place(w.Player, home['@'])
+	w.Place(factory.Spawn("master abomination", w), home['M'])
+
+	act := action.New(w, mapgen.New(w), query.New(w), event.NewBus())
+	act.DoFov(w.Player)
+
+	return &goldenFixture{w, anim.New()}
+}
+
+// render draws with fn on a fresh frame and returns the frame.
+func render(fn func()) *gfx.RGBASurface {
+	frame := gfx.NewRGBASurface(screenRect.Dx(), screenRect.Dy())
+	frame.Clear(gfx.Black)
+	gfx.SetFrame(frame)
+	defer gfx.SetFrame(nil)
+	fn()
+	return frame
+}
+
+func channelDiff(a, b uint32) uint32 {
+	if a > b {
+		return (a - b) >> 8
+	}
+	return (b - a) >> 8
+}
+
+func colorsMatch(c1, c2 color.Color) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return channelDiff(r1, r2) <= channelTolerance &&
+		channelDiff(g1, g2) <= channelTolerance &&
+		channelDiff(b1, b2) <= channelTolerance &&
+		channelDiff(a1, a2) <= channelTolerance
+}
+
+func savePng(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, img)
+}
+
+// checkGolden compares an image against the golden image of the given name,
+// or rewrites the golden if the update flag is set. A mismatching image is
+// saved next to the golden for inspection.
+func checkGolden(t *testing.T, name string, img image.Image) {
+	path := filepath.Join(goldenDir, name+".png")
+	if *update {
+		if err := os.MkdirAll(goldenDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := savePng(path, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Missing golden image, run the test with -update: %s", err)
+	}
+	defer file.Close()
+	golden, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actualPath := filepath.Join(goldenDir, name+".actual.png")
+	if golden.Bounds() != img.Bounds() {
+		savePng(actualPath, img)
+		t.Fatalf("%s: size %s, golden is %s", name, img.Bounds(), golden.Bounds())
+	}
+
+	diffs := 0
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if !colorsMatch(img.At(x, y), golden.At(x, y)) {
+				diffs++
+			}
+		}
+	}
+	if diffs > pixelTolerance {
+		savePng(actualPath, img)
+		t.Errorf("%s: %d pixels differ from the golden image, see %s",
+			name, diffs, actualPath)
+	} else {
+		os.Remove(actualPath)
+	}
+}
+
+// TestFixtureWalls makes sure the fixture keeps showing every wall tile type
+// the view can choose.
+func TestFixtureWalls(t *testing.T) {
+	f := newGoldenFixture(t)
+	fov, _ := f.world.Entities.Fov(f.world.Player)
+	chart := fov.FovChart()
+
+	seen := map[int]bool{}
+	area := util.ChartArea(screenRect)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			pt := image.Pt(x, y)
+			if f.world.Terrain(chart.At(pt)).Kind == world.WallKind {
+				seen[view.TerrainTileOffset(f.world, chart, pt)] = true
+			}
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if !seen[i] {
+			t.Errorf("Wall type %d not visible in the fixture", i)
+		}
+	}
+	if f.world.Terrain(chart.At(image.Pt(9, -1))).Kind != world.OpenKind ||
+		chart.At(image.Pt(9, -1)).Zone != portalZone {
+		t.Errorf("Portal zone not visible in the fixture")
+	}
+}
+
+// TestSprites checks that the sprite manifest has the sprites the game
+// refers to by name.
+func TestSprites(t *testing.T) {
+	f := newGoldenFixture(t)
+	for ter := world.VoidTerrain; ter <= world.PlantTerrain; ter++ {
+		if err := cache.Get().CheckImageSpec(util.Sprite(world.GetTerrainData(ter).Icon)); err != nil {
+			t.Error(err)
+		}
+	}
+	if def := cache.Get().GetSpriteDef("wall"); len(def.Frames) != 4 {
+		t.Errorf("Walls have %d frames, expected one per wall type", len(def.Frames))
+	}
+
+	effects := fx.New(f.anim, f.world)
+	for kind := fx.SmallExplosion; kind <= fx.Smoke; kind++ {
+		effects.Blast(space.Loc(1, 1, homeZone), kind)
+	}
+}
+
+func TestViewGolden(t *testing.T) {
+	f := newGoldenFixture(t)
+	v := view.New(f.world, f.anim)
+	checkGolden(t, "view", render(func() { v.Draw(screenRect) }))
+}
+
+func TestHudGolden(t *testing.T) {
+	f := newGoldenFixture(t)
+	stats, _ := f.world.Entities.Stats(f.world.Player)
+	stats.Damage(5)
+	stats.AddShield(3)
+
+	h := hud.New(f.world)
+	h.Msg("A long message that is too wide for one line of the heads-up display, so it gets split over several lines.")
+	h.Handle(event.ItemPicked{Entity: f.world.Player, Item: entity.ID(0)})
+	checkGolden(t, "hud", render(func() { h.Draw(screenRect) }))
+}
+
+func TestTypographyGolden(t *testing.T) {
+	goldenInit(t)
+	edges := []typography.Edge{
+		typography.None,
+		typography.Emboss,
+		typography.DropShadow,
+		typography.Round,
+		typography.Blocky}
+
+	checkGolden(t, "typography", render(func() {
+		gfx.Frame().Clear(gfx.SlateGray)
+		for i, edge := range edges {
+			style := util.TextStyle().Colors(gfx.Khaki, gfx.Black).Edge(edge)
+			pos := image.Pt(8, 16+i*int(style.LineHeight()+4))
+			style.Render(fmt.Sprintf("Edge style %d: The quick brown fox.", edge), pos)
+		}
+	}))
+}
+
+func TestPaletteEffectGolden(t *testing.T) {
+	f := newGoldenFixture(t)
+	v := view.New(f.world, f.anim)
+	frame := render(func() { v.Draw(screenRect) })
+
+	mood := fx.NewMood(f.world)
+	mood.Handle(event.Damaged{Target: f.world.Player, Amount: 1})
+
+	base := gfx.ExtendPalette(cache.Get().GetPalette(cache.PaletteFile))
+	filter := gfx.NewPaletteFilter(base)
+	filter.SetColors(mood.Palette(base))
+	output := gfx.NewRGBASurface(screenRect.Dx(), screenRect.Dy())
+	filter.Apply(frame, output)
+	checkGolden(t, "palette-effect", output)
+}
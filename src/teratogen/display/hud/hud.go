@@ -21,14 +21,12 @@ package hud
 
 import (
 	"image"
-	"teratogen/app"
+	"teratogen/cache"
 	"teratogen/display/util"
-	"teratogen/entity"
+	"teratogen/event"
 	"teratogen/gfx"
-	"teratogen/sdl"
 	"teratogen/typography"
 	"teratogen/world"
-	"time"
 )
 
 const timeToReadLetter = .05e9
@@ -47,8 +45,8 @@ func New(w *world.World) *Hud {
 func (h *Hud) Draw(bounds image.Rectangle) {
 	h.update()
 
-	sdl.Frame().SetClipRect(bounds)
-	defer sdl.Frame().ClearClipRect()
+	gfx.Frame().SetClipRect(bounds)
+	defer gfx.Frame().ClearClipRect()
 
 	style := util.TextStyle().ForeColor(gfx.Khaki).Edge(typography.Round)
 
@@ -68,13 +66,16 @@ func (h *Hud) Draw(bounds image.Rectangle) {
 }
 
 func (h *Hud) drawHealth(bounds image.Rectangle) {
-	heart := app.Cache().GetDrawable(util.SmallIcon(util.Items, 22))
-	halfHeart := app.Cache().GetDrawable(util.SmallIcon(util.Items, 23))
-	noHeart := app.Cache().GetDrawable(util.SmallIcon(util.Items, 24))
-	shield := app.Cache().GetDrawable(util.SmallIcon(util.Items, 25))
-	halfShield := app.Cache().GetDrawable(util.SmallIcon(util.Items, 26))
-
-	pc, _ := h.world.Player.(entity.Stats)
+	heart := cache.Get().GetDrawable(util.Sprite("heart"))
+	halfHeart := cache.Get().GetDrawable(util.Sprite("half-heart"))
+	noHeart := cache.Get().GetDrawable(util.Sprite("no-heart"))
+	shield := cache.Get().GetDrawable(util.Sprite("shield"))
+	halfShield := cache.Get().GetDrawable(util.Sprite("half-shield"))
+
+	pc, ok := h.world.Entities.Stats(h.world.Player)
+	if !ok {
+		return
+	}
 	offset := bounds.Min
 	for i := 0; i < pc.MaxHealth(); i += 2 {
 		n := pc.Health() - i
@@ -105,8 +106,32 @@ func (h *Hud) Msg(str string) {
 	}
 }
 
+// Handle posts messages about game events that concern the player. Subscribe
+// it to the event bus of the game.
+func (h *Hud) Handle(e event.Event) {
+	switch e := e.(type) {
+	case event.Died:
+		if e.Entity == h.world.Player {
+			h.Msg("You die.")
+		}
+	case event.FloorChanged:
+		if e.Entity != h.world.Player {
+			return
+		}
+		if e.To > e.From {
+			h.Msg("You go down the stairs.")
+		} else {
+			h.Msg("You go up the stairs.")
+		}
+	case event.ItemPicked:
+		if e.Entity == h.world.Player {
+			h.Msg("You pick up an item.")
+		}
+	}
+}
+
 func (h *Hud) update() {
-	t := time.Now().UnixNano()
+	t := util.Now()
 	if len(h.msgs) > 0 {
 		if t >= h.msgExpires {
 			// Assume the oldest message is read and remove it.
@@ -122,6 +147,6 @@ func (h *Hud) setExpires() {
 		if delay < 1e9 {
 			delay = 1e9
 		}
-		h.msgExpires = time.Now().UnixNano() + delay
+		h.msgExpires = util.Now() + delay
 	}
 }
@@ -24,8 +24,13 @@ import (
 	"teratogen/font"
 	"teratogen/gfx"
 	"teratogen/typography"
+	"time"
 )
 
+// Now returns the current time in nanoseconds for display animations. It
+// can be replaced to render the display at a fixed moment.
+var Now = func() int64 { return time.Now().UnixNano() }
+
 const (
 	BaseViewZ         = 0
 	ViewLayersPerZ    = 4
@@ -38,12 +43,6 @@ const (
 	TileH = 8
 )
 
-const (
-	Tiles = "assets/tiles.png"
-	Chars = "assets/chars.png"
-	Items = "assets/items.png"
-)
-
 var HalfTile = image.Pt(TileW/2, TileH/2)
 
 // ChartToScreen maps a point in the game tile coordinates into screen pixel
@@ -91,63 +90,16 @@ func ChartArea(screenArea image.Rectangle) image.Rectangle {
 	return image.Rect(minX, minY, maxX+1, maxY+1)
 }
 
-func smallIconRect(idx int) image.Rectangle {
-	x, y := (idx%16)*TileW, (idx/16)*TileH
-	return image.Rect(x, y, x+TileW, y+TileH)
-}
-
-func largeIconRect(idx int) image.Rectangle {
-	x, y := (idx%5)*TileW*3, (idx/5)*TileH*3
-	return image.Rect(x, y, x+TileW*3, y+TileH*3)
+// Sprite returns the first frame of a named sprite, drawn centered on its
+// cell. The sprites are defined in cache.SpriteManifest and by the PNG files
+// in cache.SpriteDir.
+func Sprite(name string) gfx.ImageSpec {
+	return SpriteFrame(name, 0)
 }
 
-func isoIconRect(idx int) image.Rectangle {
-	x, y := (idx%16)*TileW*2, (idx/16)*TileH*2
-	return image.Rect(x, y, x+TileW*2, y+TileH*2)
-}
-
-// SmallIcon returns a single-cell icon from the given icon sheet counting
-// indexes from left to right and from top to bottom.
-func SmallIcon(sheet string, idx int) gfx.ImageSpec {
-	return gfx.SubImage(sheet, smallIconRect(idx))
-}
-
-// SmallIcons works like SmallIcon but produces multiple images.
-func SmallIcons(sheet string, indices ...int) []gfx.ImageSpec {
-	result := []gfx.ImageSpec{}
-	for _, idx := range indices {
-		result = append(result, SmallIcon(sheet, idx))
-	}
-	return result
-}
-
-// LargeIcon returns a sevel-cell icon (basically 3x3 small icons) from the
-// given icon sheet counting indexes from left to right and top to bottom. The
-// icon will be offseted so that it's draw position corresponds to its central
-// cell.
-func LargeIcon(sheet string, idx int) gfx.ImageSpec {
-	return gfx.OffsetSubImage(sheet, largeIconRect(idx), image.Pt(-TileW, -TileH))
-}
-
-// LargeIcons works like LargeIcon but produces multiple images.
-func LargeIcons(sheet string, indices ...int) []gfx.ImageSpec {
-	result := []gfx.ImageSpec{}
-	for _, idx := range indices {
-		result = append(result, LargeIcon(sheet, idx))
-	}
-	return result
-}
-
-func IsoIcon(sheet string, idx int) gfx.ImageSpec {
-	return gfx.OffsetSubImage(sheet, isoIconRect(idx), image.Pt(-TileW/2, -TileH/2))
-}
-
-func IsoIcons(sheet string, indices ...int) []gfx.ImageSpec {
-	result := []gfx.ImageSpec{}
-	for _, idx := range indices {
-		result = append(result, IsoIcon(sheet, idx))
-	}
-	return result
+// SpriteFrame returns a frame of a named sprite, drawn centered on its cell.
+func SpriteFrame(name string, frame int) gfx.ImageSpec {
+	return gfx.NamedImage(name, frame, HalfTile)
 }
 
 var defaultStyle *typography.Style = nil
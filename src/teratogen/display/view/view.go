@@ -20,11 +20,12 @@ package view
 
 import (
 	"image"
-	"teratogen/app"
+	"teratogen/cache"
 	"teratogen/display/anim"
 	"teratogen/display/util"
+	"teratogen/entity"
 	"teratogen/gfx"
-	"teratogen/sdl"
+	"teratogen/num"
 	"teratogen/space"
 	"teratogen/tile"
 	"teratogen/world"
@@ -33,6 +34,43 @@ import (
 type View struct {
 	world *world.World
 	anim  *anim.Anim
+
+	// The parts of the view that only depend on the field of view and the
+	// terrain are cached and only rebuilt when the key changes.
+	key cacheKey
+	// The charted cells in view.
+	cells []cell
+	// Terrain sprites that can't change without the key changing. The flat
+	// ones are also drawn on the terrain layer.
+	flat, tall gfx.SpriteBatch
+	terrain    gfx.Surface
+
+	// Reused between frames to avoid allocating a new batch every time.
+	sprites gfx.SpriteBatch
+}
+
+// cacheKey identifies the state the cached parts of the view were built
+// from.
+type cacheKey struct {
+	fov             entity.Fov
+	fovRevision     int
+	terrainRevision int
+	bounds          image.Rectangle
+}
+
+// cell is a charted location in view.
+type cell struct {
+	chartPos image.Point
+	loc      space.Location
+	// Screen position of the chart origin, adjusted for the depth of the
+	// cell's zone.
+	screenOffset image.Point
+	// Doors have their sprite chosen every frame, see doorSprite.
+	door bool
+}
+
+func (c cell) offset() image.Point {
+	return util.ChartToScreen(c.chartPos).Add(c.screenOffset)
 }
 
 func New(w *world.World, a *anim.Anim) (result *View) {
@@ -43,72 +81,180 @@ func New(w *world.World, a *anim.Anim) (result *View) {
 }
 
 func (v *View) chart() space.Chart {
-	return v.world.Player.FovChart()
+	if fov, ok := v.world.Entities.Fov(v.world.Player); ok {
+		return fov.FovChart()
+	}
+	return space.MapChart{}
+}
+
+func (v *View) currentKey(bounds image.Rectangle) (key cacheKey) {
+	key.bounds = bounds
+	key.terrainRevision = v.world.TerrainRevision()
+	if fov, ok := v.world.Entities.Fov(v.world.Player); ok {
+		key.fov = fov
+		key.fovRevision = fov.FovRevision()
+	}
+	return
 }
 
 func zLine(p image.Point) int {
 	return (p.X + p.Y) * util.ViewLayersPerZ
 }
 
-func (v *View) collectSpritesAt(
-	sprites gfx.SpriteBatch,
-	chartPos image.Point,
-	screenOffset image.Point) gfx.SpriteBatch {
-	loc := v.chart().At(chartPos)
-
-	// XXX: This is a hack. Should have a robust function that maps locs to
-	// relative Z levels.
-	depthChange := int(loc.Zone - v.chart().At(image.Pt(0, 0)).Zone)
-	screenOffset = screenOffset.Add(image.Pt(0, util.TileH*depthChange))
-
-	offset := util.ChartToScreen(chartPos).Add(screenOffset)
-
-	// Collect terrain tile sprite.
-	if v.world.Contains(loc) {
-		idx := TerrainTileOffset(v.world, v.chart(), chartPos)
-
-		terrain := v.world.Terrain(loc)
-		// Hack: Don't draw doors when someone is standing in the doorway.
-		if terrain.Kind == world.DoorKind && v.world.IsBlocked(loc) {
-			terrain = world.GetTerrainData(world.FloorTerrain)
-			idx = 0
+// update rebuilds the cached cells and terrain if the field of view, the
+// terrain or the bounds have changed since the last update.
+func (v *View) update(bounds image.Rectangle) {
+	key := v.currentKey(bounds)
+	if v.terrain != nil && key == v.key {
+		return
+	}
+	v.key = key
+
+	v.cells, v.flat, v.tall = v.cells[:0], v.flat[:0], v.tall[:0]
+
+	chart := v.chart()
+	originZone := int(chart.At(image.Pt(0, 0)).Zone)
+	chartBounds := util.ChartArea(bounds)
+	screenOffset := util.CenterOrigin(bounds)
+
+	// TODO: Fog of war display on locations that are explored but not currently
+	// visible.
+	for y := chartBounds.Min.Y; y < chartBounds.Max.Y; y++ {
+		for x := chartBounds.Min.X; x < chartBounds.Max.X; x++ {
+			c := cell{chartPos: image.Pt(x, y), loc: chart.At(image.Pt(x, y))}
+			if c.loc == (space.Location{}) {
+				continue
+			}
+
+			// XXX: This is a hack. Should have a robust function that maps
+			// locs to relative Z levels.
+			depthChange := int(c.loc.Zone) - originZone
+			c.screenOffset = screenOffset.Add(image.Pt(0, util.TileH*depthChange))
+
+			if v.world.Contains(c.loc) {
+				terrain := v.world.Terrain(c.loc)
+				c.door = terrain.Kind == world.DoorKind
+				if !c.door {
+					icon := util.SpriteFrame(
+						terrain.Icon, TerrainTileOffset(v.world, chart, c.chartPos))
+					sprite := gfx.Sprite{
+						Layer:    zLine(c.chartPos),
+						Offset:   c.offset(),
+						Drawable: cache.Get().GetDrawable(icon)}
+					// Flat terrain never covers anything else, so it can go
+					// on the terrain layer.
+					switch {
+					case !sprite.IsVisibleIn(bounds):
+					case terrain.IsFlat():
+						v.flat = append(v.flat, sprite)
+					default:
+						v.tall = append(v.tall, sprite)
+					}
+				}
+			}
+			v.cells = append(v.cells, c)
 		}
+	}
+	v.flat.Sort()
+
+	v.drawTerrain(bounds)
+}
 
-		sprites = append(sprites, gfx.Sprite{
-			Layer:    zLine(chartPos),
-			Offset:   offset,
-			Drawable: app.Cache().GetDrawable(terrain.Icon[idx])})
+// drawTerrain draws the flat terrain on the terrain layer.
+func (v *View) drawTerrain(bounds image.Rectangle) {
+	if v.terrain == nil || v.terrain.Bounds().Size() != bounds.Size() {
+		v.terrain = gfx.NewSurface(bounds.Dx(), bounds.Dy())
 	}
+	v.terrain.Clear(gfx.Black)
 
-	// Collect dynamic object sprites.
-	for _, oe := range v.world.Spatial.At(loc) {
-		spritable := oe.Entity.(gfx.Spritable)
-		if spritable == nil {
-			continue
+	gfx.WithFrame(v.terrain, func() {
+		for _, sprite := range v.flat {
+			sprite.Offset = sprite.Offset.Sub(bounds.Min)
+			sprite.Draw()
 		}
-		objChartPos := chartPos.Sub(oe.Offset)
-		sprite := spritable.Sprite(util.ChartToScreen(objChartPos).Add(screenOffset))
-		// Entities will put an adjustment in their sprite layer value if they
-		// are multi-tile ones and need to be sorted with a higher layer
-		// value.
-		sprite.Layer += zLine(objChartPos) + util.EntityLayerOffset
-		sprites = append(sprites, sprite)
+	})
+}
+
+// doorSprite returns the sprite for a door cell.
+func (v *View) doorSprite(c cell) gfx.Sprite {
+	icon := util.SpriteFrame(
+		v.world.Terrain(c.loc).Icon, TerrainTileOffset(v.world, v.chart(), c.chartPos))
+	// Hack: Don't draw doors when someone is standing in the doorway.
+	if v.world.IsBlocked(c.loc) {
+		icon = util.Sprite(world.GetTerrainData(world.FloorTerrain).Icon)
 	}
+	return gfx.Sprite{
+		Layer:    zLine(c.chartPos),
+		Offset:   c.offset(),
+		Drawable: cache.Get().GetDrawable(icon)}
+}
+
+// collectDynamicSprites collects the sprites that may change without the
+// cache key changing: doors, entities and animations.
+func (v *View) collectDynamicSprites(sprites gfx.SpriteBatch) gfx.SpriteBatch {
+	for _, c := range v.cells {
+		if c.door {
+			sprites = append(sprites, v.doorSprite(c))
+		}
 
-	sprites = v.anim.CollectSpritesAt(sprites, loc, offset, util.AnimLayer)
+		for _, oe := range v.world.Spatial.At(c.loc) {
+			spr, ok := v.world.Entities.Sprite(oe.Entity.(entity.ID))
+			if !ok {
+				continue
+			}
+			objChartPos := c.chartPos.Sub(oe.Offset)
+			sprite := entitySprite(spr, util.ChartToScreen(objChartPos).Add(c.screenOffset))
+			sprite.Layer += zLine(objChartPos) + util.EntityLayerOffset
+			sprites = append(sprites, sprite)
+		}
 
+		sprites = v.anim.CollectSpritesAt(sprites, c.loc, c.offset(), util.AnimLayer)
+	}
 	return sprites
 }
 
+// entitySprite makes the sprite for an entity's sprite component. The layer
+// is relative to the entity's own cell.
+func entitySprite(s *entity.Sprite, offset image.Point) gfx.Sprite {
+	layer := 0
+	if s.IsBig {
+		// Big entities get drawn by their frontmost point.
+		layer += 2 * util.ViewLayersPerZ
+	}
+	return gfx.Sprite{
+		Layer:    layer,
+		Offset:   offset.Add(bob(s.Phase)),
+		Drawable: cache.Get().GetDrawable(util.Sprite(s.Name))}
+}
+
+// bob returns the motion offset for the idle animation of entities.
+func bob(phase int) image.Point {
+	t := util.Now()
+
+	// Give different entities persistent random phases to their bob.
+	t += int64(1e9 * num.Noise(phase))
+
+	if t%500e6 < 250e6 {
+		return image.Pt(0, -1)
+	}
+
+	return image.Pt(0, 0)
+}
+
+// Draw draws the view. The flat terrain comes from the cached terrain layer,
+// everything else that is visible is drawn as sprites on top of it.
 func (v *View) Draw(bounds image.Rectangle) {
-	sdl.Frame().SetClipRect(bounds)
-	defer sdl.Frame().ClearClipRect()
-	sdl.Frame().Clear(gfx.Black)
+	gfx.Frame().SetClipRect(bounds)
+	defer gfx.Frame().ClearClipRect()
 
-	sprites := gfx.SpriteBatch{}
-	sprites = v.CollectSprites(sprites, bounds)
-	sprites.Sort()
-	sprites.Draw()
+	v.update(bounds)
+	gfx.Blit(v.terrain, v.terrain.Bounds(), bounds.Min.X, bounds.Min.Y, gfx.Frame())
+
+	v.sprites = append(v.sprites[:0], v.tall...)
+	v.sprites = v.collectDynamicSprites(v.sprites)
+	v.sprites = v.sprites.Cull(bounds)
+	v.sprites.Sort()
+	v.sprites.Draw()
 }
 
 // CollectSprites collects all the sprites in the visible world chart into the
@@ -116,19 +262,10 @@ func (v *View) Draw(bounds image.Rectangle) {
 func (v *View) CollectSprites(
 	sprites gfx.SpriteBatch,
 	bounds image.Rectangle) gfx.SpriteBatch {
-	// TODO: Fog of war display on locations that are explored but not currently
-	// visible.
-
-	chartBounds := util.ChartArea(bounds)
-	screenOffset := util.CenterOrigin(bounds)
-
-	for y := chartBounds.Min.Y; y < chartBounds.Max.Y; y++ {
-		for x := chartBounds.Min.X; x < chartBounds.Max.X; x++ {
-			sprites = v.collectSpritesAt(sprites, image.Pt(x, y), screenOffset)
-		}
-	}
-
-	return sprites
+	v.update(bounds)
+	sprites = append(sprites, v.flat...)
+	sprites = append(sprites, v.tall...)
+	return v.collectDynamicSprites(sprites)
 }
 
 // TerrainTileOffest checks the neighbourhood of a charted tile to see if it
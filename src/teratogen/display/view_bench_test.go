@@ -0,0 +1,126 @@
+// view_bench_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package display
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"teratogen/action"
+	"teratogen/cache"
+	"teratogen/display/anim"
+	"teratogen/display/util"
+	"teratogen/display/view"
+	"teratogen/event"
+	"teratogen/factory"
+	"teratogen/gfx"
+	"teratogen/mapgen"
+	"teratogen/query"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+// A large view with a big open area, some pillars and mobs in it.
+var largeViewRect = image.Rect(0, 0, 640, 480)
+
+func newLargeView(b *testing.B) (*view.View, *world.World) {
+	goldenInit(b)
+	rand.Seed(1)
+
+	const size = 64
+	w := world.New()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			ter := world.FloorTerrain
+			if x == 0 || y == 0 || x == size-1 || y == size-1 || (x%6 == 3 && y%6 == 3) {
+				ter = world.WallTerrain
+			}
+			w.SetTerrain(space.Loc(int16(x), int16(y), 1), ter)
+		}
+	}
+
+	w.Player = factory.Spawn(factory.Player, w)
+	w.Place(w.Player, space.Loc(size/2, size/2, 1))
+	for i := 0; i < 20; i++ {
+		w.Place(factory.Spawn("zombie", w), space.Loc(int16(4+i*2), int16(size/2+2), 1))
+	}
+	action.New(w, mapgen.New(w), query.New(w), event.NewBus()).DoFov(w.Player)
+
+	gfx.SetFrame(gfx.NewRGBASurface(largeViewRect.Dx(), largeViewRect.Dy()))
+	return view.New(w, anim.New()), w
+}
+
+// Compare drawing a frame when the terrain layer stays cached and when it
+// must be redrawn because the terrain changes every frame.
+
+func BenchmarkViewDraw(b *testing.B) {
+	v, _ := newLargeView(b)
+	defer gfx.SetFrame(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v.Draw(largeViewRect)
+	}
+}
+
+func BenchmarkViewDrawTerrainChange(b *testing.B) {
+	v, w := newLargeView(b)
+	defer gfx.SetFrame(nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ter := world.Terrain(world.FloorTerrain)
+		if i%2 == 0 {
+			ter = world.WallTerrain
+		}
+		w.SetTerrain(space.Loc(0, 0, 1), ter)
+		v.Draw(largeViewRect)
+	}
+}
+
+// Alpha blended sprites, like the ones from the sprite atlas, and tinted
+// sprites can't use a plain copy or a color key blit.
+
+func BenchmarkBlendedSprites(b *testing.B) {
+	benchmarkSprites(b, util.Sprite("heart"), nil)
+}
+
+func BenchmarkTintedSprites(b *testing.B) {
+	benchmarkSprites(b, util.Sprite("zombie"), gfx.Dimmed(.5))
+}
+
+// benchmarkSprites draws a sprite over the whole large view area.
+func benchmarkSprites(b *testing.B, spec gfx.ImageSpec, tint color.Color) {
+	goldenInit(b)
+	gfx.SetFrame(gfx.NewRGBASurface(largeViewRect.Dx(), largeViewRect.Dy()))
+	defer gfx.SetFrame(nil)
+	sprite := cache.Get().GetDrawable(spec)
+	if tint != nil {
+		sprite = gfx.Tint(sprite, tint)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for y := 0; y < largeViewRect.Dy(); y += 8 {
+			for x := 0; x < largeViewRect.Dx(); x += 8 {
+				sprite.Draw(image.Pt(x, y))
+			}
+		}
+	}
+}
@@ -15,8 +15,12 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-// Package entity provides interfaces for the game world entities, such as
-// items and creatures.
+// Package entity provides the registry and the component types for the game
+// world entities, such as items and creatures.
+//
+// Entities are plain IDs. Their capabilities are the components attached to
+// the ID in the registry. The positions of entities are kept in the spatial
+// index of the world.
 package entity
 
 import (
@@ -24,18 +28,25 @@ import (
 	"teratogen/space"
 )
 
-// BlockMove is an entity that can block movement.
-type BlockMove interface {
-	BlocksMove() bool
+// ID is a stable identifier for an entity. IDs are never reused, so they can
+// be used to refer to entities in save files. The zero ID means no entity.
+type ID uint32
+
+// Body is the physical shape component of an entity.
+type Body struct {
+	// Shape of the entity on the map, nil for single cell entities.
+	Footprint  *space.FootprintTemplate
+	BlocksMove bool
 }
 
 // Fov is a field of view component, it means an entity can remember the
 // surroundings it has seen in a manifold chart.
 type Fov interface {
 	FovChart() space.Chart
-	MoveFovOrigin(vec image.Point)
+	MoveFovOrigin(vec image.Point, zone uint16)
 	MarkFov(pt image.Point, loc space.Location)
-	ClearFov()
+	// FovRevision changes whenever the contents of the chart change.
+	FovRevision() int
 }
 
 // Stats are the interface for active entities that fight and get hurt.
@@ -48,5 +59,24 @@ type Stats interface {
 	AddShield(amount int)
 }
 
-// Entity type is just an alias for interface{} for more explicit notation.
-type Entity interface{}
+// Sprite is the appearance component of an entity. It only names the
+// graphics, the display layer looks them up.
+type Sprite struct {
+	// Name of the sprite in the sprite manifest.
+	Name string
+	// Big entities are drawn in front of all the cells they cover.
+	IsBig bool
+	// Seed for the phase of the idle animation.
+	Phase int
+}
+
+// AI is the component for computer-controlled entities.
+type AI struct {
+	// How far the entity notices enemies.
+	SightRadius int
+}
+
+// Inventory is the component for entities that carry items.
+type Inventory struct {
+	Items []ID
+}
@@ -0,0 +1,221 @@
+// registry.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package entity
+
+import (
+	"sort"
+	"teratogen/ser"
+)
+
+func init() {
+	// The registry stores the components in interface values, so their
+	// types must be known to ser.
+	ser.RegisterValue("Body", &Body{})
+	ser.RegisterValue("Sprite", &Sprite{})
+	ser.RegisterValue("AI", &AI{})
+	ser.RegisterValue("Inventory", &Inventory{})
+}
+
+// Registry keeps track of the existing entities and their components. The
+// Each methods iterate in ascending ID order, which is the order the
+// entities were created in.
+type Registry struct {
+	lastID ID
+	ids    []ID
+
+	bodies      store
+	stats       store
+	fovs        store
+	sprites     store
+	ais         store
+	inventories store
+}
+
+func NewRegistry() (result *Registry) {
+	result = new(Registry)
+	result.Init()
+	return
+}
+
+func (r *Registry) Init() {
+	r.lastID = 0
+	r.ids = []ID{}
+	for _, s := range r.stores() {
+		s.init()
+	}
+}
+
+func (r *Registry) stores() []*store {
+	return []*store{&r.bodies, &r.stats, &r.fovs, &r.sprites, &r.ais, &r.inventories}
+}
+
+// New creates a new entity with no components.
+func (r *Registry) New() ID {
+	r.lastID++
+	r.ids = append(r.ids, r.lastID)
+	return r.lastID
+}
+
+// Destroy removes an entity and all its components.
+func (r *Registry) Destroy(id ID) {
+	if i, ok := findID(r.ids, id); ok {
+		r.ids = append(r.ids[:i], r.ids[i+1:]...)
+	}
+	for _, s := range r.stores() {
+		s.remove(id)
+	}
+}
+
+func (r *Registry) Exists(id ID) bool {
+	_, ok := findID(r.ids, id)
+	return ok
+}
+
+// Each calls fn for every existing entity.
+func (r *Registry) Each(fn func(ID)) {
+	for _, id := range append([]ID(nil), r.ids...) {
+		fn(id)
+	}
+}
+
+func (r *Registry) SetBody(id ID, body *Body) { r.bodies.set(id, body) }
+
+func (r *Registry) Body(id ID) (*Body, bool) {
+	v, ok := r.bodies.get(id)
+	body, _ := v.(*Body)
+	return body, ok
+}
+
+func (r *Registry) EachBody(fn func(ID, *Body)) {
+	r.bodies.each(func(id ID, v interface{}) { fn(id, v.(*Body)) })
+}
+
+func (r *Registry) SetStats(id ID, stats Stats) { r.stats.set(id, stats) }
+
+func (r *Registry) Stats(id ID) (Stats, bool) {
+	v, ok := r.stats.get(id)
+	stats, _ := v.(Stats)
+	return stats, ok
+}
+
+func (r *Registry) EachStats(fn func(ID, Stats)) {
+	r.stats.each(func(id ID, v interface{}) { fn(id, v.(Stats)) })
+}
+
+func (r *Registry) SetFov(id ID, fov Fov) { r.fovs.set(id, fov) }
+
+func (r *Registry) Fov(id ID) (Fov, bool) {
+	v, ok := r.fovs.get(id)
+	fov, _ := v.(Fov)
+	return fov, ok
+}
+
+func (r *Registry) EachFov(fn func(ID, Fov)) {
+	r.fovs.each(func(id ID, v interface{}) { fn(id, v.(Fov)) })
+}
+
+func (r *Registry) SetSprite(id ID, sprite *Sprite) { r.sprites.set(id, sprite) }
+
+func (r *Registry) Sprite(id ID) (*Sprite, bool) {
+	v, ok := r.sprites.get(id)
+	sprite, _ := v.(*Sprite)
+	return sprite, ok
+}
+
+func (r *Registry) EachSprite(fn func(ID, *Sprite)) {
+	r.sprites.each(func(id ID, v interface{}) { fn(id, v.(*Sprite)) })
+}
+
+func (r *Registry) SetAI(id ID, ai *AI) { r.ais.set(id, ai) }
+
+func (r *Registry) AI(id ID) (*AI, bool) {
+	v, ok := r.ais.get(id)
+	ai, _ := v.(*AI)
+	return ai, ok
+}
+
+func (r *Registry) EachAI(fn func(ID, *AI)) {
+	r.ais.each(func(id ID, v interface{}) { fn(id, v.(*AI)) })
+}
+
+func (r *Registry) SetInventory(id ID, inv *Inventory) { r.inventories.set(id, inv) }
+
+func (r *Registry) Inventory(id ID) (*Inventory, bool) {
+	v, ok := r.inventories.get(id)
+	inv, _ := v.(*Inventory)
+	return inv, ok
+}
+
+func (r *Registry) EachInventory(fn func(ID, *Inventory)) {
+	r.inventories.each(func(id ID, v interface{}) { fn(id, v.(*Inventory)) })
+}
+
+// store holds the components of a single type. The typed accessors of
+// Registry convert the values from and to the component types.
+type store struct {
+	values map[ID]interface{}
+	// The IDs with a component in ascending order.
+	ids []ID
+}
+
+func (s *store) init() {
+	s.values = make(map[ID]interface{})
+	s.ids = []ID{}
+}
+
+func (s *store) get(id ID) (value interface{}, ok bool) {
+	value, ok = s.values[id]
+	return
+}
+
+func (s *store) set(id ID, value interface{}) {
+	if _, ok := s.values[id]; !ok {
+		i, _ := findID(s.ids, id)
+		s.ids = append(s.ids, 0)
+		copy(s.ids[i+1:], s.ids[i:])
+		s.ids[i] = id
+	}
+	s.values[id] = value
+}
+
+func (s *store) remove(id ID) {
+	if _, ok := s.values[id]; !ok {
+		return
+	}
+	delete(s.values, id)
+	i, _ := findID(s.ids, id)
+	s.ids = append(s.ids[:i], s.ids[i+1:]...)
+}
+
+// each calls fn for the components in ID order. Components may be added and
+// removed during the iteration, the ones that exist when each is called get
+// visited unless they are removed before their turn.
+func (s *store) each(fn func(ID, interface{})) {
+	for _, id := range append([]ID(nil), s.ids...) {
+		if value, ok := s.values[id]; ok {
+			fn(id, value)
+		}
+	}
+}
+
+// findID returns the position of id in the sorted ids slice, or the position
+// where it would be inserted, and whether it was found.
+func findID(ids []ID, id ID) (int, bool) {
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	return i, i < len(ids) && ids[i] == id
+}
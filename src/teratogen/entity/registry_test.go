@@ -0,0 +1,78 @@
+// registry_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	a, b, c := r.New(), r.New(), r.New()
+	if a == 0 || a == b || b == c {
+		t.Fatal("Bad IDs")
+	}
+
+	// Set components out of order, iteration should still go by ID.
+	r.SetAI(c, &AI{SightRadius: 3})
+	r.SetAI(a, &AI{SightRadius: 1})
+	r.SetBody(b, &Body{BlocksMove: true})
+
+	ids := []ID{}
+	r.EachAI(func(id ID, ai *AI) { ids = append(ids, id) })
+	if !reflect.DeepEqual(ids, []ID{a, c}) {
+		t.Errorf("Bad iteration order %v", ids)
+	}
+
+	if ai, ok := r.AI(c); !ok || ai.SightRadius != 3 {
+		t.Error("Bad component value")
+	}
+	if _, ok := r.AI(b); ok {
+		t.Error("Component on entity that doesn't have it")
+	}
+
+	r.Destroy(a)
+	if r.Exists(a) {
+		t.Error("Destroyed entity exists")
+	}
+	if _, ok := r.AI(a); ok {
+		t.Error("Destroyed entity's component exists")
+	}
+
+	// IDs are not reused.
+	if d := r.New(); d == a || d <= c {
+		t.Errorf("Reused ID %d", d)
+	}
+}
+
+func TestRegistryRemoveWhileIterating(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < 4; i++ {
+		r.SetAI(r.New(), &AI{})
+	}
+	n := 0
+	r.EachAI(func(id ID, ai *AI) {
+		n++
+		r.Destroy(id)
+		r.Destroy(id + 1)
+	})
+	if n != 2 {
+		t.Errorf("Visited %d entities, expected 2", n)
+	}
+}
@@ -0,0 +1,117 @@
+// event.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package event defines the events that the game logic publishes about what
+// happens in the game world. The display layer subscribes to the events to
+// show effects and messages, so the game logic doesn't need to know about
+// it.
+package event
+
+import (
+	"image"
+	"teratogen/entity"
+	"teratogen/space"
+)
+
+// Event is any of the event types in this package. Subscribers use a type
+// switch to pick the ones they are interested in.
+type Event interface{}
+
+// Moved is published when an entity moves to a new location.
+type Moved struct {
+	Entity   entity.ID
+	From, To space.Location
+	Dir      image.Point
+}
+
+// Attacked is published when an entity attacks another one in melee.
+type Attacked struct {
+	Attacker, Target entity.ID
+}
+
+// Shot is published when an entity fires a shot. If the shot hit a wall,
+// HitWall is set and End is the wall location.
+type Shot struct {
+	Shooter entity.ID
+	From    space.Location
+	Dir     image.Point
+	Dist    int
+	End     space.Location
+	HitWall bool
+}
+
+// Damaged is published when an entity takes damage.
+type Damaged struct {
+	Target entity.ID
+	Loc    space.Location
+	Amount int
+}
+
+// Died is published when an entity dies and is removed from the world.
+type Died struct {
+	Entity entity.ID
+	Loc    space.Location
+}
+
+// FloorChanged is published when an entity moves to a different floor.
+type FloorChanged struct {
+	Entity   entity.ID
+	From, To uint16
+}
+
+// ItemPicked is published when an entity picks up an item.
+type ItemPicked struct {
+	Entity, Item entity.ID
+}
+
+// Bus delivers published events to the subscribers.
+type Bus struct {
+	subscribers []func(Event)
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a function that gets called with every event published on
+// the bus. The subscribers are called in the order they subscribed.
+func (b *Bus) Subscribe(fn func(Event)) {
+	b.subscribers = append(b.subscribers, fn)
+}
+
+// Publish sends an event to all the subscribers.
+func (b *Bus) Publish(e Event) {
+	for _, fn := range b.subscribers {
+		fn(e)
+	}
+}
+
+// Log is a subscriber that records events, for example to check what
+// happened during a turn.
+type Log struct {
+	Events []Event
+}
+
+// Record is the subscriber function of the log.
+func (l *Log) Record(e Event) {
+	l.Events = append(l.Events, e)
+}
+
+// Clear empties the log.
+func (l *Log) Clear() {
+	l.Events = nil
+}
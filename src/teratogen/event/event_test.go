@@ -0,0 +1,50 @@
+// event_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package event
+
+import (
+	"teratogen/space"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	order := []int{}
+	bus.Subscribe(func(Event) { order = append(order, 1) })
+	bus.Subscribe(func(Event) { order = append(order, 2) })
+	log := new(Log)
+	bus.Subscribe(log.Record)
+
+	bus.Publish(Attacked{1, 2})
+	bus.Publish(Died{2, space.Loc(1, 2, 1)})
+
+	if len(order) != 4 || order[0] != 1 || order[1] != 2 {
+		t.Errorf("Bad subscriber call order %v", order)
+	}
+	if len(log.Events) != 2 {
+		t.Fatalf("Expected 2 logged events, got %d", len(log.Events))
+	}
+	if e, ok := log.Events[1].(Died); !ok || e.Entity != 2 {
+		t.Errorf("Unexpected event %v", log.Events[1])
+	}
+
+	log.Clear()
+	if len(log.Events) != 0 {
+		t.Errorf("Log not cleared")
+	}
+}
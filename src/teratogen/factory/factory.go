@@ -20,77 +20,74 @@ package factory
 
 import (
 	"math/rand"
-	"teratogen/display/util"
 	"teratogen/entity"
-	"teratogen/gfx"
 	"teratogen/mob"
 	"teratogen/world"
 )
 
-type spawnFunc func(*world.World) entity.Entity
+type spawnFunc func(*world.World) entity.ID
 
 type spawn struct {
 	commonness int
 	minDepth   int
+	isBig      bool
 	init       spawnFunc
 }
 
-func genPC(w *world.World) entity.Entity {
-	return mob.NewPC(w, mob.Spec{Icon: util.SmallIcon(util.Chars, 16), MaxHealth: 6})
+func genPC(w *world.World) entity.ID {
+	return mob.NewPC(w, mob.Spec{Icon: "player", MaxHealth: 6})
 }
 
-func icon(idx int) gfx.ImageSpec { return util.SmallIcon(util.Chars, idx) }
-
-func largeIcon(idx int) gfx.ImageSpec { return util.LargeIcon(util.Chars, idx) }
-
-func pc(icon gfx.ImageSpec, health int) spawnFunc {
-	return spawnFunc(func(w *world.World) entity.Entity {
+func pc(icon string, health int) spawnFunc {
+	return spawnFunc(func(w *world.World) entity.ID {
 		return mob.NewPC(w, mob.Spec{Icon: icon, MaxHealth: health})
 	})
 }
 
-func monster(icon gfx.ImageSpec, health int) spawnFunc {
-	return spawnFunc(func(w *world.World) entity.Entity {
+func monster(icon string, health int) spawnFunc {
+	return spawnFunc(func(w *world.World) entity.ID {
 		return mob.New(w, mob.Spec{Icon: icon, MaxHealth: health})
 	})
 }
 
-func largeMonster(icon gfx.ImageSpec, health int) spawnFunc {
-	return spawnFunc(func(w *world.World) entity.Entity {
+func largeMonster(icon string, health int) spawnFunc {
+	return spawnFunc(func(w *world.World) entity.ID {
 		return mob.New(w, mob.Spec{Icon: icon, MaxHealth: health, IsBig: true})
 	})
 }
 
 var spawns = map[string]spawn{
-	"player":             {0, 0, pc(icon(16), 20)},
-	"zombie":             {30, 0, monster(icon(1), 2)},
-	"dog-thing":          {40, 0, monster(icon(2), 1)},
-	"spitter":            {15, 2, monster(icon(3), 2)},
-	"cyclops":            {15, 2, monster(icon(6), 2)},
-	"death ooze":         {15, 3, monster(icon(7), 4)},
-	"bear":               {3, 0, monster(icon(23), 4)},
-	"master abomination": {10, 0, largeMonster(largeIcon(5), 10)},
-	"dominator-537":      {10, 0, largeMonster(largeIcon(6), 10)},
-	"void devourer":      {10, 0, largeMonster(largeIcon(7), 10)},
-	"viscera guardian":   {10, 0, largeMonster(largeIcon(8), 10)},
+	"player":             {0, 0, false, pc("player", 20)},
+	"zombie":             {30, 0, false, monster("zombie", 2)},
+	"dog-thing":          {40, 0, false, monster("dog-thing", 1)},
+	"spitter":            {15, 2, false, monster("spitter", 2)},
+	"cyclops":            {15, 2, false, monster("cyclops", 2)},
+	"death ooze":         {15, 3, false, monster("death-ooze", 4)},
+	"bear":               {3, 0, false, monster("bear", 4)},
+	"master abomination": {10, 0, true, largeMonster("master-abomination", 10)},
+	"dominator-537":      {10, 0, true, largeMonster("dominator-537", 10)},
+	"void devourer":      {10, 0, true, largeMonster("void-devourer", 10)},
+	"viscera guardian":   {10, 0, true, largeMonster("viscera-guardian", 10)},
 }
 
 const (
 	Player = "player"
 )
 
-func Spawn(id string, w *world.World) entity.Entity {
+func Spawn(id string, w *world.World) entity.ID {
 	if spawn, ok := spawns[id]; ok {
 		return spawn.init(w)
 	}
 	panic("Unknown spawn id")
 }
 
-func RandomMonster(depth int, w *world.World) entity.Entity {
+// RandomMonster spawns a random monster suitable for the depth. Big
+// monsters are only spawned if allowBig is set, see mapgen.AllowsBigMobs.
+func RandomMonster(depth int, allowBig bool, w *world.World) entity.ID {
 	dist := map[string]int{}
 	total := 0
 	for name, s := range spawns {
-		if s.minDepth <= depth && s.commonness > 0 {
+		if s.minDepth <= depth && s.commonness > 0 && (allowBig || !s.isBig) {
 			dist[name] = s.commonness
 			total += s.commonness
 		}
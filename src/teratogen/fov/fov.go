@@ -59,7 +59,7 @@ func (f *Fov) process(origin space.Location, radius int, begin, end angle) {
 			// The type of terrain changed, recurse a deeper process with
 			// current arc and start a new arc.
 			if !group.blocksSight {
-				f.process(origin.Beyond(group.portal), radius, begin.above(), a.above())
+				f.processBeyond(origin, group.portal, radius, begin.above(), a.above())
 			}
 			f.process(origin, radius, a, end)
 			return
@@ -68,10 +68,24 @@ func (f *Fov) process(origin space.Location, radius int, begin, end angle) {
 	}
 	// Recurse after finishing the whole arc.
 	if !group.blocksSight {
-		f.process(origin.Beyond(group.portal), radius, begin.above(), end.above())
+		f.processBeyond(origin, group.portal, radius, begin.above(), end.above())
 	}
 }
 
+// processBeyond continues processing from the origin shifted by a portal.
+// Nothing is seen past the edge of the coordinate range.
+func (f *Fov) processBeyond(origin space.Location, portal space.Portal, radius int, begin, end angle) {
+	if portal.Zone != 0 {
+		loc, ok := origin.TryAdd(image.Pt(int(portal.X), int(portal.Y)))
+		if !ok {
+			return
+		}
+		origin = loc
+		origin.Zone = portal.Zone
+	}
+	f.process(origin, radius, begin, end)
+}
+
 // group is used to define contiguous sets of cells along the fov outer radius
 // that can be handled as a single unit. These cells must have an identical
 // portal and an identical opaqueness.
@@ -81,7 +95,11 @@ type group struct {
 }
 
 func (f *Fov) group(origin space.Location, offset image.Point) group {
-	rawLoc := origin.Add(offset)
+	rawLoc, ok := origin.TryAdd(offset)
+	if !ok {
+		// Edge of the coordinate range, treat it as an opaque wall.
+		return group{true, space.NullPortal()}
+	}
 	return group{f.blocksSight(f.mf.Traverse(rawLoc)), f.mf.Portal(rawLoc)}
 }
 
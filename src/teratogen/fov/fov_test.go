@@ -57,3 +57,23 @@ func TestFov(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestFovAtEdge(t *testing.T) {
+	mf := space.NewManifold()
+	seen := map[image.Point]space.Location{}
+	fov := New(func(loc space.Location) bool { return false },
+		func(pt image.Point, loc space.Location) { seen[pt] = loc }, mf)
+
+	fov.Run(space.Loc(space.MaxCoord-1, space.MinCoord+1, 1), 4)
+
+	if seen[image.Pt(-3, 0)] != space.Loc(space.MaxCoord-4, space.MinCoord+1, 1) {
+		t.Fail()
+	}
+	for pt, loc := range seen {
+		if pt.X > 1 || pt.Y < -1 {
+			if loc != (space.Location{}) {
+				t.Errorf("Fov wrapped around the edge to %s", loc)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// atlas.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+// Atlas is a set of named images packed into a few large sheets. The images
+// keep their alpha channel on the sheets, and the space between them is
+// transparent, so the sheets are meant to be blitted with alpha blending.
+type Atlas struct {
+	Sheets  []*image.RGBA
+	sprites map[string]AtlasSprite
+}
+
+// AtlasSprite is the location of an image in an atlas.
+type AtlasSprite struct {
+	Sheet int
+	Rect  image.Rectangle
+}
+
+// PackAtlas packs named images into sheets of the given size. It packs the
+// images into rows from the tallest to the lowest, starting a new sheet
+// when the current one is full.
+func PackAtlas(images map[string]image.Image, sheetSize image.Point) (result *Atlas, err error) {
+	names := []string{}
+	for name, img := range images {
+		size := img.Bounds().Size()
+		if size.X > sheetSize.X || size.Y > sheetSize.Y {
+			return nil, errors.New(fmt.Sprintf(
+				"Image %s of size %s doesn't fit on a %s atlas sheet", name, size, sheetSize))
+		}
+		names = append(names, name)
+	}
+	sort.Sort(&atlasOrder{names, images})
+
+	result = &Atlas{sprites: make(map[string]AtlasSprite)}
+	var sheet *image.RGBA
+	var pos image.Point
+	rowHeight := 0
+	for _, name := range names {
+		img := images[name]
+		size := img.Bounds().Size()
+		if sheet != nil && pos.X+size.X > sheetSize.X {
+			pos = image.Pt(0, pos.Y+rowHeight)
+			rowHeight = 0
+		}
+		if sheet == nil || pos.Y+size.Y > sheetSize.Y {
+			sheet = image.NewRGBA(image.Rectangle{image.ZP, sheetSize})
+			result.Sheets = append(result.Sheets, sheet)
+			pos, rowHeight = image.ZP, 0
+		}
+
+		rect := image.Rectangle{pos, pos.Add(size)}
+		draw.Draw(sheet, rect, img, img.Bounds().Min, draw.Src)
+		result.sprites[name] = AtlasSprite{len(result.Sheets) - 1, rect}
+
+		pos.X += size.X
+		if size.Y > rowHeight {
+			rowHeight = size.Y
+		}
+	}
+	return
+}
+
+// Sprite returns the location of a named image in the atlas.
+func (a *Atlas) Sprite(name string) (sprite AtlasSprite, ok bool) {
+	sprite, ok = a.sprites[name]
+	return
+}
+
+// Names returns the sorted names of the images in the atlas.
+func (a *Atlas) Names() (names []string) {
+	for name, _ := range a.sprites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Sorts images from tallest to lowest, then widest to narrowest, then by
+// name so that the packing doesn't depend on map order.
+type atlasOrder struct {
+	names  []string
+	images map[string]image.Image
+}
+
+func (a *atlasOrder) Len() int { return len(a.names) }
+
+func (a *atlasOrder) Less(i, j int) bool {
+	s1 := a.images[a.names[i]].Bounds().Size()
+	s2 := a.images[a.names[j]].Bounds().Size()
+	if s1.Y != s2.Y {
+		return s1.Y > s2.Y
+	}
+	if s1.X != s2.X {
+		return s1.X > s2.X
+	}
+	return a.names[i] < a.names[j]
+}
+
+func (a *atlasOrder) Swap(i, j int) {
+	a.names[i], a.names[j] = a.names[j], a.names[i]
+}
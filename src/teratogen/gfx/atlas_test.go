@@ -0,0 +1,92 @@
+// atlas_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func testImage(w, h int, c color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestPackAtlas(t *testing.T) {
+	images := map[string]image.Image{}
+	for i := 0; i < 20; i++ {
+		images[fmt.Sprintf("img%d", i)] = testImage(4+i%3*4, 4+i%5*2, color.RGBA{uint8(i), 0, 0, 0xFF})
+	}
+	images["ghost"] = testImage(8, 8, color.NRGBA{0xFF, 0, 0, 0x10})
+
+	atlas, err := PackAtlas(images, image.Pt(32, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atlas.Sheets) < 2 {
+		t.Errorf("Images didn't overflow to a second sheet")
+	}
+	if names := atlas.Names(); len(names) != len(images) || names[0] != "ghost" {
+		t.Errorf("Bad atlas names %v", names)
+	}
+
+	for name, img := range images {
+		sprite, ok := atlas.Sprite(name)
+		if !ok {
+			t.Fatalf("Image %s missing from the atlas", name)
+		}
+		if sprite.Rect.Size() != img.Bounds().Size() {
+			t.Errorf("Image %s packed at %s", name, sprite.Rect)
+		}
+		for other := range images {
+			sprite2, _ := atlas.Sprite(other)
+			if other != name && sprite2.Sheet == sprite.Sheet && sprite2.Rect.Overlaps(sprite.Rect) {
+				t.Errorf("Images %s and %s overlap", name, other)
+			}
+		}
+
+		// The translucent ghost keeps its alpha.
+		expect := color.RGBAModel.Convert(img.At(0, 0)).(color.RGBA)
+		sheet := atlas.Sheets[sprite.Sheet]
+		if c := sheet.RGBAAt(sprite.Rect.Min.X, sprite.Rect.Min.Y); c != expect {
+			t.Errorf("Image %s has color %v on the sheet, expected %v", name, c, expect)
+		}
+	}
+
+	if c := atlas.Sheets[0].RGBAAt(31, 31); c != (color.RGBA{}) {
+		t.Errorf("Sheet background isn't transparent: %v", c)
+	}
+
+	if _, ok := atlas.Sprite("nonexistent"); ok {
+		t.Errorf("Found a nonexistent image")
+	}
+}
+
+func TestPackAtlasTooBig(t *testing.T) {
+	images := map[string]image.Image{"big": testImage(40, 8, Black)}
+	if _, err := PackAtlas(images, image.Pt(32, 32)); err == nil {
+		t.Errorf("Oversized image didn't give an error")
+	}
+}
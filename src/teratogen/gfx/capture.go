@@ -0,0 +1,125 @@
+// capture.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// ToImage copies the contents of a surface into a new image.
+func ToImage(s Surface32Bit) *image.RGBA {
+	bounds := s.Bounds()
+	result := image.NewRGBA(bounds)
+	pix, pitch := s.Pixels32(), s.Pitch32()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			result.Set(x, y, s.GetColor(pix[x+y*pitch]))
+		}
+	}
+	return result
+}
+
+// Quantizer maps the pixels of surfaces to the nearest colors in a palette.
+// It remembers the mappings it has made, so converting many frames with
+// only a few colors is fast.
+type Quantizer struct {
+	palette color.Palette
+	lookup  map[uint32]uint8
+}
+
+func NewQuantizer(pal color.Palette) *Quantizer {
+	if len(pal) == 0 || len(pal) > 256 {
+		panic("Bad palette size")
+	}
+	return &Quantizer{pal, make(map[uint32]uint8)}
+}
+
+// Paletted converts the contents of a surface into a paletted image.
+func (q *Quantizer) Paletted(s Surface32Bit) *image.Paletted {
+	bounds := s.Bounds()
+	result := image.NewPaletted(bounds, q.palette)
+	pix, pitch := s.Pixels32(), s.Pitch32()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		line := result.Pix[(y-bounds.Min.Y)*result.Stride:]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			line[x-bounds.Min.X] = q.index(s, pix[x+y*pitch])
+		}
+	}
+	return result
+}
+
+// index returns the palette index for a pixel value of a surface.
+func (q *Quantizer) index(s Surface32Bit, c32 uint32) uint8 {
+	idx, ok := q.lookup[c32]
+	if !ok {
+		idx = uint8(q.palette.Index(s.GetColor(c32)))
+		q.lookup[c32] = idx
+	}
+	return idx
+}
+
+// GifRecorder collects surface contents into an animated GIF.
+type GifRecorder struct {
+	quantizer *Quantizer
+	frames    []*image.Paletted
+	// Capture times of the frames in nanoseconds.
+	times []int64
+}
+
+func NewGifRecorder(pal color.Palette) *GifRecorder {
+	return &GifRecorder{quantizer: NewQuantizer(pal)}
+}
+
+// AddFrame captures the current contents of a surface. The time is used to
+// work out how long each frame is shown in the animation.
+func (r *GifRecorder) AddFrame(s Surface32Bit, time int64) {
+	r.frames = append(r.frames, r.quantizer.Paletted(s))
+	r.times = append(r.times, time)
+}
+
+// Len returns the number of recorded frames.
+func (r *GifRecorder) Len() int {
+	return len(r.frames)
+}
+
+// Encode writes the recorded frames as a looping animated GIF.
+func (r *GifRecorder) Encode(w io.Writer) error {
+	anim := &gif.GIF{Image: r.frames, Delay: r.delays()}
+	return gif.EncodeAll(w, anim)
+}
+
+// delays converts the frame times into the GIF frame delays in hundredths of
+// a second. Rounding errors are carried over to the next frame so that the
+// animation doesn't drift. The last frame gets the delay of the one before
+// it.
+func (r *GifRecorder) delays() []int {
+	result := make([]int, len(r.times))
+	var carry int64
+	for i := 0; i+1 < len(r.times); i++ {
+		ns := r.times[i+1] - r.times[i] + carry
+		result[i] = int(ns / 1e7)
+		carry = ns - int64(result[i])*1e7
+	}
+	if len(result) > 1 {
+		result[len(result)-1] = result[len(result)-2]
+	}
+	return result
+}
@@ -0,0 +1,75 @@
+// capture_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"testing"
+)
+
+func TestQuantizer(t *testing.T) {
+	s := NewRGBASurface(16, 2)
+	for i, col := range Arne16 {
+		s.Set(i, 0, col)
+	}
+	s.FillRect(image.Rect(0, 1, 16, 2), Khaki)
+
+	img := NewQuantizer(GamePalette).Paletted(s)
+	for i := range Arne16 {
+		if img.ColorIndexAt(i, 0) != uint8(i) {
+			t.Errorf("Palette color %d mapped to %d", i, img.ColorIndexAt(i, 0))
+		}
+	}
+	if img.At(0, 1) != GamePalette.Convert(Khaki) {
+		t.Errorf("Off-palette color not mapped to the nearest palette color")
+	}
+	if ToImage(s).At(3, 0) != Arne16[3] {
+		t.Errorf("ToImage doesn't preserve colors")
+	}
+}
+
+func TestGifRecorder(t *testing.T) {
+	s := NewRGBASurface(8, 8)
+	r := NewGifRecorder(GamePalette)
+	for i := 0; i < 3; i++ {
+		s.Clear(Arne16[i])
+		r.AddFrame(s, int64(i)*33e6)
+	}
+
+	delays := r.delays()
+	if delays[0] != 3 || delays[1] != 3 || delays[2] != 3 {
+		t.Errorf("Bad frame delays %v", delays)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != r.Len() {
+		t.Fatalf("Expected %d frames, got %d", r.Len(), len(anim.Image))
+	}
+	if anim.Image[2].At(0, 0) != Arne16[2] {
+		t.Errorf("Bad frame contents")
+	}
+}
@@ -52,6 +52,15 @@ func ScaleCol(col color.Color, scale float64) (result color.Color) {
 	return color.RGBA{r8, g8, b8, uint8(a >> 8)}
 }
 
+// MulColor multiplies the non-premultiplied channels of two colors. It is
+// used to combine tints.
+func MulColor(c1, c2 color.Color) color.Color {
+	n1 := color.NRGBAModel.Convert(c1).(color.NRGBA)
+	n2 := color.NRGBAModel.Convert(c2).(color.NRGBA)
+	mul := func(x, y uint8) uint8 { return uint8(uint32(x) * uint32(y) / 0xFF) }
+	return color.NRGBA{mul(n1.R, n2.R), mul(n1.G, n2.G), mul(n1.B, n2.B), mul(n1.A, n2.A)}
+}
+
 func lerpComponent(a, b uint32, x float64) uint8 {
 	return uint8((float64(a) + (float64(b)-float64(a))*x) / 256)
 }
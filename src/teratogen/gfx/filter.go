@@ -0,0 +1,276 @@
+// filter.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"image/color"
+)
+
+// Filter draws a frame onto a larger surface, scaled up by an integer
+// factor, with its top left corner at offset. Parts that fall outside the
+// target surface are clipped.
+//
+// The filters that change colors assume a 32-bit pixel format with 8 bits
+// per channel.
+type Filter interface {
+	Blit(src, dest Surface32Bit, scale int, offset image.Point)
+}
+
+// FilterNames lists the names of the filters in Filters in display order.
+var FilterNames = []string{"nearest", "scanlines", "crt", "scalex"}
+
+// Filters are the output filters by name.
+var Filters = map[string]Filter{
+	"nearest":   NearestFilter{},
+	"scanlines": ScanlineFilter{},
+	"crt":       CrtFilter{},
+	"scalex":    &ScaleXFilter{},
+}
+
+// NearestFilter scales the pixels into plain blocks.
+type NearestFilter struct{}
+
+func (NearestFilter) Blit(src, dest Surface32Bit, scale int, offset image.Point) {
+	BlitScaled(src, dest, scale, offset)
+}
+
+// ScanlineFilter darkens the bottom line of each scaled pixel block like the
+// gaps between the scanlines of an old monitor.
+type ScanlineFilter struct{}
+
+func (ScanlineFilter) Blit(src, dest Surface32Bit, scale int, offset image.Point) {
+	BlitScaled(src, dest, scale, offset)
+	if scale < 2 {
+		return
+	}
+	alpha := dest.MapColor(color.RGBA{0, 0, 0, 0xFF})
+	pix, pitch := dest.Pixels32(), dest.Pitch32()
+	area := scaledArea(src, dest, scale, offset)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		if (y-offset.Y)%scale != scale-1 {
+			continue
+		}
+		line := pix[y*pitch+area.Min.X : y*pitch+area.Max.X]
+		for x, c := range line {
+			line[x] = halve(c)&^alpha | c&alpha
+		}
+	}
+}
+
+// CrtFilter emulates the aperture grille of a color CRT by dimming two of
+// the three color channels on each column of the scaled image, in addition
+// to showing scanlines.
+type CrtFilter struct{}
+
+func (CrtFilter) Blit(src, dest Surface32Bit, scale int, offset image.Point) {
+	BlitScaled(src, dest, scale, offset)
+	if scale < 2 {
+		return
+	}
+	alpha := dest.MapColor(color.RGBA{0, 0, 0, 0xFF})
+	grille := []uint32{
+		dest.MapColor(color.RGBA{0xFF, 0, 0, 0}) | alpha,
+		dest.MapColor(color.RGBA{0, 0xFF, 0, 0}) | alpha,
+		dest.MapColor(color.RGBA{0, 0, 0xFF, 0}) | alpha}
+	pix, pitch := dest.Pixels32(), dest.Pitch32()
+	area := scaledArea(src, dest, scale, offset)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		scanline := (y-offset.Y)%scale == scale-1
+		column := (area.Min.X - offset.X) % 3
+		line := pix[y*pitch+area.Min.X : y*pitch+area.Max.X]
+		for x, c := range line {
+			if scanline {
+				c = threeQuarters(c)&^alpha | c&alpha
+			}
+			keep := grille[column]
+			line[x] = c&keep | threeQuarters(c)&^keep
+			if column++; column == 3 {
+				column = 0
+			}
+		}
+	}
+}
+
+// ScaleXFilter is an edge-aware pixel art scaler. It uses the Scale2x
+// algorithm for even scales and Scale3x for multiples of three, growing the
+// result further with plain blocks if needed. Other scales are drawn with
+// plain blocks.
+type ScaleXFilter struct {
+	buffer *RGBASurface
+}
+
+func (f *ScaleXFilter) Blit(src, dest Surface32Bit, scale int, offset image.Point) {
+	var factor int
+	switch {
+	case scale%2 == 0:
+		factor = 2
+	case scale%3 == 0:
+		factor = 3
+	default:
+		BlitScaled(src, dest, scale, offset)
+		return
+	}
+
+	size := src.Bounds().Size().Mul(factor)
+	if f.buffer == nil || f.buffer.Bounds().Size() != size {
+		f.buffer = NewRGBASurface(size.X, size.Y)
+	}
+	// The buffer is only used to hold the pixel values, so its own pixel
+	// format doesn't matter.
+	if factor == 2 {
+		scale2x(src, f.buffer)
+	} else {
+		scale3x(src, f.buffer)
+	}
+	BlitScaled(f.buffer, dest, scale/factor, offset)
+}
+
+// scale2x doubles the size of src into dest using the Scale2x algorithm.
+func scale2x(src, dest Surface32Bit) {
+	srcPix, srcPitch := src.Pixels32(), src.Pitch32()
+	destPix, destPitch := dest.Pixels32(), dest.Pitch32()
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	for y := 0; y < h; y++ {
+		up, down := y-1, y+1
+		if up < 0 {
+			up = 0
+		}
+		if down >= h {
+			down = h - 1
+		}
+		line, upLine, downLine := srcPix[y*srcPitch:], srcPix[up*srcPitch:], srcPix[down*srcPitch:]
+		out0, out1 := destPix[y*2*destPitch:], destPix[(y*2+1)*destPitch:]
+
+		for x := 0; x < w; x++ {
+			left, right := x-1, x+1
+			if left < 0 {
+				left = 0
+			}
+			if right >= w {
+				right = w - 1
+			}
+			p := line[x]
+			a, b, c, d := upLine[x], line[right], line[left], downLine[x]
+
+			e0, e1, e2, e3 := p, p, p, p
+			if c == a && c != d && a != b {
+				e0 = a
+			}
+			if a == b && a != c && b != d {
+				e1 = b
+			}
+			if d == c && d != b && c != a {
+				e2 = c
+			}
+			if b == d && b != a && d != c {
+				e3 = d
+			}
+			out0[x*2], out0[x*2+1] = e0, e1
+			out1[x*2], out1[x*2+1] = e2, e3
+		}
+	}
+}
+
+// scale3x triples the size of src into dest using the Scale3x algorithm.
+func scale3x(src, dest Surface32Bit) {
+	srcPix, srcPitch := src.Pixels32(), src.Pitch32()
+	destPix, destPitch := dest.Pixels32(), dest.Pitch32()
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	at := func(x, y int) uint32 {
+		if x < 0 {
+			x = 0
+		} else if x >= w {
+			x = w - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		return srcPix[x+y*srcPitch]
+	}
+
+	for y := 0; y < h; y++ {
+		out := [][]uint32{
+			destPix[y*3*destPitch:],
+			destPix[(y*3+1)*destPitch:],
+			destPix[(y*3+2)*destPitch:]}
+		for x := 0; x < w; x++ {
+			// Neighborhood:
+			// A B C
+			// D E F
+			// G H I
+			a, b, c := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+			d, e, f := at(x-1, y), at(x, y), at(x+1, y)
+			g, h2, i := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+
+			e0, e1, e2 := e, e, e
+			e3, e5 := e, e
+			e6, e7, e8 := e, e, e
+			if b != h2 && d != f {
+				if d == b {
+					e0 = d
+				}
+				if (d == b && e != c) || (b == f && e != a) {
+					e1 = b
+				}
+				if b == f {
+					e2 = f
+				}
+				if (d == b && e != g) || (d == h2 && e != a) {
+					e3 = d
+				}
+				if (b == f && e != i) || (h2 == f && e != c) {
+					e5 = f
+				}
+				if d == h2 {
+					e6 = d
+				}
+				if (d == h2 && e != i) || (h2 == f && e != g) {
+					e7 = h2
+				}
+				if h2 == f {
+					e8 = f
+				}
+			}
+			out[0][x*3], out[0][x*3+1], out[0][x*3+2] = e0, e1, e2
+			out[1][x*3], out[1][x*3+1], out[1][x*3+2] = e3, e, e5
+			out[2][x*3], out[2][x*3+1], out[2][x*3+2] = e6, e7, e8
+		}
+	}
+}
+
+// scaledArea returns the part of dest covered by src scaled by scale and
+// placed at offset.
+func scaledArea(src, dest Surface32Bit, scale int, offset image.Point) image.Rectangle {
+	size := src.Bounds().Size().Mul(scale)
+	return image.Rectangle{offset, offset.Add(size)}.Intersect(dest.Bounds())
+}
+
+// halve halves every 8-bit channel of a pixel value.
+func halve(c uint32) uint32 {
+	return (c >> 1) & 0x7F7F7F7F
+}
+
+// threeQuarters scales every 8-bit channel of a pixel value by 3/4.
+func threeQuarters(c uint32) uint32 {
+	return (c>>1)&0x7F7F7F7F + (c>>2)&0x3F3F3F3F
+}
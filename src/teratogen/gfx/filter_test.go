@@ -0,0 +1,194 @@
+// filter_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	black = color.RGBA{0, 0, 0, 0xFF}
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+func TestScanlineFilter(t *testing.T) {
+	src, dest := NewRGBASurface(2, 2), NewRGBASurface(6, 6)
+	src.Clear(white)
+	ScanlineFilter{}.Blit(src, dest, 3, image.Pt(1, 1))
+
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			expect := color.RGBA{}
+			switch {
+			case x < 1 || y < 1:
+			case y == 3 || y == 6:
+				expect = color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}
+			default:
+				expect = white
+			}
+			if c := dest.RGBAAt(x, y); c != expect {
+				t.Errorf("Pixel %d, %d is %v, expected %v", x, y, c, expect)
+			}
+		}
+	}
+}
+
+func TestCrtFilter(t *testing.T) {
+	src, dest := NewRGBASurface(1, 1), NewRGBASurface(3, 3)
+	src.Clear(white)
+	CrtFilter{}.Blit(src, dest, 3, image.ZP)
+
+	if c := dest.RGBAAt(0, 0); c != (color.RGBA{0xFF, 0xBE, 0xBE, 0xFF}) {
+		t.Errorf("Red column is %v", c)
+	}
+	if c := dest.RGBAAt(1, 1); c != (color.RGBA{0xBE, 0xFF, 0xBE, 0xFF}) {
+		t.Errorf("Green column is %v", c)
+	}
+	if c := dest.RGBAAt(2, 2); c != (color.RGBA{0x8E, 0x8E, 0xBE, 0xFF}) {
+		t.Errorf("Blue column on scanline is %v", c)
+	}
+}
+
+func TestScale2x(t *testing.T) {
+	// A diagonal edge gets smoothed, a lone pixel stays square.
+	src := NewRGBASurface(2, 2)
+	src.Clear(black)
+	src.Set(0, 0, white)
+	src.Set(1, 0, white)
+	src.Set(0, 1, white)
+	dest := NewRGBASurface(4, 4)
+	scale2x(src, dest)
+
+	if c := dest.RGBAAt(3, 3); c != black {
+		t.Errorf("Corner pixel is %v", c)
+	}
+	if c := dest.RGBAAt(2, 2); c != white {
+		t.Errorf("Diagonal edge not smoothed: %v", c)
+	}
+
+	src, dest = NewRGBASurface(3, 3), NewRGBASurface(6, 6)
+	src.Clear(black)
+	src.Set(1, 1, white)
+	scale2x(src, dest)
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			expect := black
+			if x/2 == 1 && y/2 == 1 {
+				expect = white
+			}
+			if c := dest.RGBAAt(x, y); c != expect {
+				t.Errorf("Pixel %d, %d is %v, expected %v", x, y, c, expect)
+			}
+		}
+	}
+}
+
+func TestScale3x(t *testing.T) {
+	// A diagonal edge gets smoothed, a lone pixel stays square.
+	src := NewRGBASurface(2, 2)
+	src.Clear(black)
+	src.Set(0, 0, white)
+	src.Set(1, 0, white)
+	src.Set(0, 1, white)
+	dest := NewRGBASurface(6, 6)
+	scale3x(src, dest)
+
+	corner := []string{
+		"WWB",
+		"WBB",
+		"BBB"}
+	for y, line := range corner {
+		for x, ch := range line {
+			expect := black
+			if ch == 'W' {
+				expect = white
+			}
+			if c := dest.RGBAAt(3+x, 3+y); c != expect {
+				t.Errorf("Diagonal edge pixel %d, %d is %v, expected %v", 3+x, 3+y, c, expect)
+			}
+		}
+	}
+
+	src, dest = NewRGBASurface(3, 3), NewRGBASurface(9, 9)
+	src.Clear(black)
+	src.Set(1, 1, white)
+	scale3x(src, dest)
+	for y := 0; y < 9; y++ {
+		for x := 0; x < 9; x++ {
+			expect := black
+			if x/3 == 1 && y/3 == 1 {
+				expect = white
+			}
+			if c := dest.RGBAAt(x, y); c != expect {
+				t.Errorf("Pixel %d, %d is %v, expected %v", x, y, c, expect)
+			}
+		}
+	}
+}
+
+func TestFilterClipping(t *testing.T) {
+	src := NewRGBASurface(4, 4)
+	src.Clear(white)
+	for _, name := range FilterNames {
+		dest := NewRGBASurface(5, 5)
+		// Would panic if the filter wrote outside dest.
+		Filters[name].Blit(src, dest, 2, image.Pt(2, -1))
+		if c := dest.RGBAAt(1, 1); c != (color.RGBA{}) {
+			t.Errorf("Filter %s drew outside its area: %v", name, c)
+		}
+		if c := dest.RGBAAt(2, 0); c.A != 0xFF {
+			t.Errorf("Filter %s didn't draw inside its area: %v", name, c)
+		}
+	}
+}
+
+// Benchmark the output filters at the default display scale.
+
+func benchmarkFilter(b *testing.B, filter Filter, scale int) {
+	b.StopTimer()
+	src := NewRGBASurface(320, 240)
+	dest := NewRGBASurface(320*scale, 240*scale)
+
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		filter.Blit(src, dest, scale, image.ZP)
+	}
+	b.StopTimer()
+}
+
+func BenchmarkNearestFilter(b *testing.B) {
+	benchmarkFilter(b, NearestFilter{}, 2)
+}
+
+func BenchmarkScanlineFilter(b *testing.B) {
+	benchmarkFilter(b, ScanlineFilter{}, 2)
+}
+
+func BenchmarkCrtFilter(b *testing.B) {
+	benchmarkFilter(b, CrtFilter{}, 2)
+}
+
+func BenchmarkScale2xFilter(b *testing.B) {
+	benchmarkFilter(b, &ScaleXFilter{}, 2)
+}
+
+func BenchmarkScale3xFilter(b *testing.B) {
+	benchmarkFilter(b, &ScaleXFilter{}, 3)
+}
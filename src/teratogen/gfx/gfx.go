@@ -23,7 +23,6 @@ import (
 	"image"
 	"image/color"
 	"teratogen/num"
-	"teratogen/sdl"
 	"unsafe"
 )
 
@@ -39,10 +38,10 @@ type Surface32Bit interface {
 	GetColor(c32 uint32) color.Color
 }
 
-// Scaled returns a SDL surface where the graphics have been multiplied by an
+// Scaled returns a surface where the graphics have been multiplied by an
 // even multiple of dimensions. Useful for doubling or tripling pixel
 // dimensions of small pixel art.
-func Scaled(orig *sdl.Surface, scale image.Point) (result *sdl.Surface) {
+func Scaled(orig Surface, scale image.Point) (result Surface) {
 	if scale.X < 1 || scale.Y < 1 {
 		panic("Bad scale dimensions")
 	}
@@ -51,7 +50,7 @@ func Scaled(orig *sdl.Surface, scale image.Point) (result *sdl.Surface) {
 		return orig
 	}
 
-	result = sdl.NewSurface(orig.Bounds().Dx()*scale.X, orig.Bounds().Dy()*scale.Y)
+	result = NewSurface(orig.Bounds().Dx()*scale.X, orig.Bounds().Dy()*scale.Y)
 
 	oPix := orig.Pixels32()
 	rPix := result.Pixels32()
@@ -95,7 +94,54 @@ func BlitX3(src, dest Surface32Bit) {
 	}
 }
 
-func GradientRect(s *sdl.Surface, rect image.Rectangle, topCol, bottomCol color.Color) {
+// BlitScaled blits src onto dest with its top left corner at offset and each
+// pixel grown into a scale by scale block. The parts that fall outside dest
+// are clipped.
+func BlitScaled(src, dest Surface32Bit, scale int, offset image.Point) {
+	if scale < 1 {
+		panic("Bad scale")
+	}
+	srcPix := src.Pixels32()
+	srcPitch := src.Pitch32()
+	destPix := dest.Pixels32()
+	destPitch := dest.Pitch32()
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	target := image.Rect(0, 0, w*scale, h*scale).Add(offset)
+
+	if !target.In(dest.Bounds()) {
+		target = target.Intersect(dest.Bounds())
+		for y := target.Min.Y; y < target.Max.Y; y++ {
+			srcLine := srcPix[(y-offset.Y)/scale*srcPitch:]
+			for x := target.Min.X; x < target.Max.X; x++ {
+				destPix[x+y*destPitch] = srcLine[(x-offset.X)/scale]
+			}
+		}
+		return
+	}
+
+	for y := 0; y < h; y++ {
+		line := destPix[(offset.Y+y*scale)*destPitch+offset.X:]
+		switch scale {
+		case 1:
+			copy(line[:w], srcPix[y*srcPitch:])
+		case 2:
+			hline2X(srcPix[y*srcPitch:], line, w)
+		case 3:
+			hline3X(srcPix[y*srcPitch:], line, w)
+		default:
+			for x := 0; x < w*scale; x++ {
+				line[x] = srcPix[y*srcPitch+x/scale]
+			}
+		}
+		// The rest of the scaled lines are copies of the first one.
+		for i := 1; i < scale; i++ {
+			copy(destPix[(offset.Y+y*scale+i)*destPitch+offset.X:][:w*scale], line[:w*scale])
+		}
+	}
+}
+
+func GradientRect(s Surface, rect image.Rectangle, topCol, bottomCol color.Color) {
 	dy := rect.Dy()
 	for y := 0; y < dy; y++ {
 		s.FillRect(image.Rect(rect.Min.X, rect.Min.Y+y, rect.Max.X, rect.Min.Y+y+1),
@@ -106,22 +152,37 @@ func GradientRect(s *sdl.Surface, rect image.Rectangle, topCol, bottomCol color.
 	}
 }
 
-// ImageDrawable is a Drawable made from a SDL surface.
+// ImageDrawable is a Drawable made from a surface.
 type ImageDrawable struct {
-	Surface *sdl.Surface
+	Surface Surface
 	Rect    image.Rectangle
 	Offset  image.Point
+	// Tint modulates the colors of the image, see BlitTinted. Nil for no
+	// tint.
+	Tint color.Color
 }
 
 func (d ImageDrawable) Draw(offset image.Point) {
 	offset = offset.Add(d.Offset)
-	d.Surface.Blit(d.Rect, offset.X, offset.Y, sdl.Frame())
+	BlitTinted(d.Surface, d.Rect, offset.X, offset.Y, Frame(), d.Tint)
+}
+
+func (d ImageDrawable) Tinted(tint color.Color) Drawable {
+	if d.Tint != nil {
+		tint = MulColor(d.Tint, tint)
+	}
+	d.Tint = tint
+	return d
 }
 
 func (d ImageDrawable) Bounds() image.Rectangle {
 	return d.Rect.Add(d.Offset)
 }
 
+func (d ImageDrawable) Extent() image.Rectangle {
+	return image.Rectangle{d.Offset, d.Offset.Add(d.Rect.Size())}
+}
+
 func (d ImageDrawable) String() string {
 	return fmt.Sprintf("ImageDrawable %s", d.Bounds())
 }
@@ -152,18 +213,34 @@ func hline3X(src, dest []uint32, n int) {
 	}
 }
 
+// ImageSpec is either a subimage of an image file or a frame of a named
+// sprite. For a named sprite, File is the name, Bounds is empty and Offset
+// is where the center of the image is drawn.
 type ImageSpec struct {
 	File   string
 	Bounds image.Rectangle
 	Offset image.Point
+	// The frame of a named sprite.
+	Frame int
+}
+
+// IsNamed returns whether the spec refers to a named sprite.
+func (s ImageSpec) IsNamed() bool {
+	return s.Bounds.Empty()
 }
 
 func SubImage(file string, bounds image.Rectangle) ImageSpec {
-	return ImageSpec{file, bounds, image.Pt(0, 0)}
+	return ImageSpec{file, bounds, image.Pt(0, 0), 0}
 }
 
 func OffsetSubImage(file string, bounds image.Rectangle, offset image.Point) ImageSpec {
-	return ImageSpec{file, bounds, offset}
+	return ImageSpec{file, bounds, offset, 0}
+}
+
+// NamedImage refers to a frame of a named sprite. The center of the image is
+// drawn at center.
+func NamedImage(name string, frame int, center image.Point) ImageSpec {
+	return ImageSpec{name, image.ZR, center, frame}
 }
 
 // Context is a interface for Spritable objects to get UI level resources to
@@ -179,6 +256,35 @@ type Drawable interface {
 	Draw(offset image.Point)
 }
 
-func Line(s *sdl.Surface, p1, p2 image.Point, col color.Color) {
+// Tinter is implemented by drawables that can be drawn with a tint.
+type Tinter interface {
+	// Tinted returns a copy of the drawable with its colors multiplied by
+	// tint, as in BlitTinted.
+	Tinted(tint color.Color) Drawable
+}
+
+// Tint returns the drawable with a tint if it can be tinted and the
+// drawable unchanged otherwise.
+func Tint(d Drawable, tint color.Color) Drawable {
+	if t, ok := d.(Tinter); ok {
+		return t.Tinted(tint)
+	}
+	return d
+}
+
+// Translucent returns a white tint that draws images with the given opacity
+// between 0 and 1.
+func Translucent(opacity float64) color.Color {
+	return color.NRGBA{0xFF, 0xFF, 0xFF, uint8(num.Clamp(0, 1, opacity) * 0xFF)}
+}
+
+// Dimmed returns an opaque tint that darkens images to the given brightness
+// between 0 and 1.
+func Dimmed(brightness float64) color.Color {
+	v := uint8(num.Clamp(0, 1, brightness) * 0xFF)
+	return color.NRGBA{v, v, v, 0xFF}
+}
+
+func Line(s Surface, p1, p2 image.Point, col color.Color) {
 	num.BresenhamLine(func(p image.Point) { s.Set(p.X, p.Y, col) }, p1, p2)
 }
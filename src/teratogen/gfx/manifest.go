@@ -0,0 +1,197 @@
+// manifest.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// SpriteManifest holds the named sprites and the settings for the images
+// they are cut from.
+type SpriteManifest struct {
+	Sprites map[string]SpriteDef
+	// Images that aren't listed use DefaultImageSettings.
+	Images map[string]ImageSettings
+}
+
+// ImageSettings tell how the transparent parts of an image are drawn.
+type ImageSettings struct {
+	// Pixels of ColorKey are left out when the image is drawn. If ColorKey
+	// is nil, the image is alpha blended using its alpha channel instead.
+	ColorKey color.Color
+}
+
+var DefaultImageSettings = ImageSettings{ColorKey: Cyan}
+
+// SpriteDef is a named sprite with one or more frames. The frames are
+// subimages whose Offset shifts the image from being centered on the point
+// where it is drawn.
+type SpriteDef struct {
+	Frames []ImageSpec
+	// Nanoseconds per frame when the sprite is an animation, zero if it
+	// isn't.
+	TimePerFrame int64
+	Loops        bool
+}
+
+// Sprite manifest file structure. A grid cuts a sheet into equal sized
+// cells, sprites can then give their frames as cell indices counting from
+// left to right and top to bottom. Sprites can also give the rectangles of
+// their frames as [x, y, w, h]. The color key of an image is a color
+// accepted by ParseColor or "none" for alpha blending.
+//
+//     {
+//       "images": {"assets/ghosts.png": {"colorKey": "none"}},
+//       "grids": {"small": {"cell": [8, 8], "columns": 16}},
+//       "sprites": {
+//         "blood-squib": {
+//           "sheet": "assets/items.png", "grid": "small",
+//           "frames": [39, 40, 41], "frameTime": 0.07},
+//         "crate": {
+//           "sheet": "assets/tiles.png", "rects": [[0, 32, 16, 20]],
+//           "offset": [0, -2]}
+//       }
+//     }
+
+type manifest struct {
+	Images  map[string]manifestImage  `json:"images"`
+	Grids   map[string]manifestGrid   `json:"grids"`
+	Sprites map[string]manifestSprite `json:"sprites"`
+}
+
+type manifestImage struct {
+	ColorKey string `json:"colorKey"`
+}
+
+type manifestGrid struct {
+	Cell    [2]int `json:"cell"`
+	Columns int    `json:"columns"`
+}
+
+type manifestSprite struct {
+	Sheet  string   `json:"sheet"`
+	Grid   string   `json:"grid"`
+	Frames []int    `json:"frames"`
+	Rects  [][4]int `json:"rects"`
+	Offset [2]int   `json:"offset"`
+	// Seconds per frame.
+	FrameTime float64 `json:"frameTime"`
+	Loops     bool    `json:"loops"`
+}
+
+// ParseSpriteManifest reads a JSON sprite manifest.
+func ParseSpriteManifest(r io.Reader) (result *SpriteManifest, err error) {
+	var m manifest
+	if err = json.NewDecoder(r).Decode(&m); err != nil {
+		return
+	}
+
+	result = &SpriteManifest{
+		Sprites: make(map[string]SpriteDef),
+		Images:  make(map[string]ImageSettings)}
+	for name, s := range m.Sprites {
+		if result.Sprites[name], err = s.def(m.Grids); err != nil {
+			return nil, errors.New(fmt.Sprintf("Sprite %s: %s", name, err))
+		}
+	}
+	for file, img := range m.Images {
+		if result.Images[file], err = img.settings(); err != nil {
+			return nil, errors.New(fmt.Sprintf("Image %s: %s", file, err))
+		}
+	}
+	return
+}
+
+// ImageSettings returns the settings for an image file.
+func (m *SpriteManifest) ImageSettings(file string) ImageSettings {
+	if settings, ok := m.Images[file]; ok {
+		return settings
+	}
+	return DefaultImageSettings
+}
+
+func (img manifestImage) settings() (settings ImageSettings, err error) {
+	switch img.ColorKey {
+	case "":
+		return DefaultImageSettings, nil
+	case "none":
+		return ImageSettings{}, nil
+	}
+	key, ok := ParseColor(img.ColorKey)
+	if !ok {
+		err = errors.New(fmt.Sprintf("Bad color key '%s'", img.ColorKey))
+	}
+	return ImageSettings{ColorKey: key}, err
+}
+
+func (s manifestSprite) def(grids map[string]manifestGrid) (def SpriteDef, err error) {
+	if s.Sheet == "" {
+		err = errors.New("No sheet")
+		return
+	}
+	if s.FrameTime < 0 {
+		err = errors.New("Negative frame time")
+		return
+	}
+	offset := image.Pt(s.Offset[0], s.Offset[1])
+
+	var rects []image.Rectangle
+	switch {
+	case len(s.Frames) > 0 && len(s.Rects) > 0:
+		err = errors.New("Both frames and rects given")
+		return
+	case len(s.Frames) > 0:
+		grid, ok := grids[s.Grid]
+		if !ok {
+			err = errors.New(fmt.Sprintf("Unknown grid '%s'", s.Grid))
+			return
+		}
+		if grid.Columns < 1 || grid.Cell[0] < 1 || grid.Cell[1] < 1 {
+			err = errors.New(fmt.Sprintf("Bad grid '%s'", s.Grid))
+			return
+		}
+		for _, idx := range s.Frames {
+			w, h := grid.Cell[0], grid.Cell[1]
+			x, y := (idx%grid.Columns)*w, (idx/grid.Columns)*h
+			rects = append(rects, image.Rect(x, y, x+w, y+h))
+		}
+	case len(s.Rects) > 0:
+		for _, r := range s.Rects {
+			rects = append(rects, image.Rect(r[0], r[1], r[0]+r[2], r[1]+r[3]))
+		}
+	default:
+		err = errors.New("No frames")
+		return
+	}
+
+	for _, rect := range rects {
+		if rect.Empty() || rect.Min.X < 0 || rect.Min.Y < 0 {
+			err = errors.New(fmt.Sprintf("Bad frame rectangle %s", rect))
+			return
+		}
+		def.Frames = append(def.Frames, OffsetSubImage(s.Sheet, rect, offset))
+	}
+	def.TimePerFrame = int64(s.FrameTime * 1e9)
+	def.Loops = s.Loops
+	return
+}
@@ -0,0 +1,84 @@
+// manifest_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+const testManifest = `{
+  "images": {
+    "ghosts.png": {"colorKey": "none"},
+    "items.png": {"colorKey": "magenta"}},
+  "grids": {"iso": {"cell": [16, 16], "columns": 4}},
+  "sprites": {
+    "door": {"sheet": "tiles.png", "grid": "iso", "frames": [1, 5]},
+    "squib": {
+      "sheet": "items.png", "rects": [[8, 0, 8, 8]], "offset": [0, -2],
+      "frameTime": 0.07, "loops": true}
+  }
+}`
+
+func TestParseSpriteManifest(t *testing.T) {
+	m, err := ParseSpriteManifest(strings.NewReader(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := m.Sprites
+
+	door := defs["door"]
+	if len(door.Frames) != 2 || door.TimePerFrame != 0 ||
+		door.Frames[0] != SubImage("tiles.png", image.Rect(16, 0, 32, 16)) ||
+		door.Frames[1] != SubImage("tiles.png", image.Rect(16, 16, 32, 32)) {
+		t.Errorf("Bad grid sprite %v", door)
+	}
+
+	squib := defs["squib"]
+	if len(squib.Frames) != 1 || squib.TimePerFrame != .07e9 || !squib.Loops ||
+		squib.Frames[0] != OffsetSubImage("items.png", image.Rect(8, 0, 16, 8), image.Pt(0, -2)) {
+		t.Errorf("Bad rect sprite %v", squib)
+	}
+
+	if m.ImageSettings("ghosts.png").ColorKey != nil {
+		t.Errorf("Alpha blended image has a color key")
+	}
+	if m.ImageSettings("items.png").ColorKey != Magenta {
+		t.Errorf("Bad color key %v", m.ImageSettings("items.png").ColorKey)
+	}
+	if m.ImageSettings("tiles.png") != DefaultImageSettings {
+		t.Errorf("Unlisted image doesn't use default settings")
+	}
+}
+
+func TestBadSpriteManifest(t *testing.T) {
+	for _, m := range []string{
+		`{"sprites": {"a": {"sheet": "a.png", "grid": "none", "frames": [1]}}}`,
+		`{"sprites": {"a": {"sheet": "a.png"}}}`,
+		`{"sprites": {"a": {"rects": [[0, 0, 8, 8]]}}}`,
+		`{"sprites": {"a": {"sheet": "a.png", "rects": [[0, 0, 0, 8]]}}}`,
+		`{"sprites": {"a": {"sheet": "a.png", "rects": [[0, 0, 8, 8]], "frameTime": -1}}}`,
+		`{"images": {"a.png": {"colorKey": "plaid"}}}`,
+		`{"sprites": `,
+	} {
+		if _, err := ParseSpriteManifest(strings.NewReader(m)); err == nil {
+			t.Errorf("Bad manifest %s didn't give an error", m)
+		}
+	}
+}
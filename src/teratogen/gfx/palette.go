@@ -0,0 +1,154 @@
+// palette.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// Arne16 is the 16-color palette the game's pixel art is drawn with.
+var Arne16 = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	color.RGBA{0x9D, 0x9D, 0x9D, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0xBE, 0x26, 0x33, 0xFF},
+	color.RGBA{0xE0, 0x6F, 0x8B, 0xFF},
+	color.RGBA{0x49, 0x3C, 0x2B, 0xFF},
+	color.RGBA{0xA4, 0x64, 0x22, 0xFF},
+	color.RGBA{0xEB, 0x89, 0x31, 0xFF},
+	color.RGBA{0xF7, 0xE2, 0x6B, 0xFF},
+	color.RGBA{0x2F, 0x48, 0x4E, 0xFF},
+	color.RGBA{0x44, 0x89, 0x1A, 0xFF},
+	color.RGBA{0xA3, 0xCE, 0x27, 0xFF},
+	color.RGBA{0x1B, 0x26, 0x32, 0xFF},
+	color.RGBA{0x00, 0x57, 0x84, 0xFF},
+	color.RGBA{0x31, 0xA2, 0xF2, 0xFF},
+	color.RGBA{0xB2, 0xDC, 0xEF, 0xFF},
+}
+
+// GamePalette is the palette for paletted output of the game display. It
+// has the Arne16 colors first, so that the pixel art maps exactly, followed
+// by a color cube for the text and effect colors.
+var GamePalette = ExtendPalette(Arne16)
+
+// ExtendPalette returns a copy of the palette followed by a 6x6x6 color
+// cube, so that colors not in the original palette have a close match. The
+// palette can have at most 40 colors to leave room for the cube.
+func ExtendPalette(pal color.Palette) color.Palette {
+	if len(pal)+6*6*6 > 256 {
+		panic("Palette too large to extend")
+	}
+	result := append(color.Palette{}, pal...)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				result = append(result, color.RGBA{
+					uint8(r * 0x33), uint8(g * 0x33), uint8(b * 0x33), 0xFF})
+			}
+		}
+	}
+	return result
+}
+
+// ParsePalette reads a palette in the GIMP palette file format.
+func ParsePalette(r io.Reader) (pal color.Palette, err error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		err = errors.New("Not a GIMP palette file")
+		return
+	}
+	for lineNum := 2; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.Contains(line, ":") {
+			// Skip comments and the name and column headers.
+			continue
+		}
+		var r, g, b uint8
+		if _, err = fmt.Sscan(line, &r, &g, &b); err != nil {
+			err = errors.New(fmt.Sprintf("Bad palette line %d: %s", lineNum, err))
+			return nil, err
+		}
+		pal = append(pal, color.RGBA{r, g, b, 0xFF})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pal) == 0 || len(pal) > 256 {
+		err = errors.New(fmt.Sprintf("Bad number of palette colors %d", len(pal)))
+		return nil, err
+	}
+	return
+}
+
+// LerpPalette returns a palette where each color is moved towards the target
+// color by amount, which is between 0 and 1.
+func LerpPalette(pal color.Palette, target color.Color, amount float64) color.Palette {
+	result := make(color.Palette, len(pal))
+	for i, col := range pal {
+		result[i] = LerpCol(col, target, amount)
+	}
+	return result
+}
+
+// PaletteFilter shows true color frames through a palette. The frame colors
+// are mapped to the nearest colors of the base palette, and the resulting
+// indices are shown with the colors of the current palette. Changing the
+// current palette recolors the whole screen at once, which is how palette
+// effects work.
+type PaletteFilter struct {
+	quantizer *Quantizer
+	colors    color.Palette
+}
+
+func NewPaletteFilter(base color.Palette) *PaletteFilter {
+	return &PaletteFilter{NewQuantizer(base), base}
+}
+
+// SetColors sets the current palette. It must be as long as the base
+// palette.
+func (f *PaletteFilter) SetColors(pal color.Palette) {
+	if len(pal) != len(f.quantizer.palette) {
+		panic("Palette size doesn't match the base palette")
+	}
+	f.colors = pal
+}
+
+// Apply draws src on dest through the palette. The surfaces must be the same
+// size.
+func (f *PaletteFilter) Apply(src, dest Surface32Bit) {
+	mapped := make([]uint32, len(f.colors))
+	for i, col := range f.colors {
+		mapped[i] = dest.MapColor(col)
+	}
+
+	srcPix, srcPitch := src.Pixels32(), src.Pitch32()
+	destPix, destPitch := dest.Pixels32(), dest.Pitch32()
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < h; y++ {
+		srcLine := srcPix[y*srcPitch : y*srcPitch+w]
+		destLine := destPix[y*destPitch : y*destPitch+w]
+		for x, c32 := range srcLine {
+			destLine[x] = mapped[f.quantizer.index(src, c32)]
+		}
+	}
+}
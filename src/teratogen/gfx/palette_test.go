@@ -0,0 +1,77 @@
+// palette_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	pal, err := ParsePalette(strings.NewReader(`GIMP Palette
+Name: Test
+Columns: 2
+#
+  0   0   0	Black
+255 215   0	Gold
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pal) != 2 || pal[0] != Black || pal[1] != Gold {
+		t.Errorf("Bad parsed palette %v", pal)
+	}
+
+	for _, bad := range []string{
+		"",
+		"0 0 0\n",
+		"GIMP Palette\n",
+		"GIMP Palette\n0 0\n",
+		"GIMP Palette\n0 0 256\n",
+	} {
+		if _, err := ParsePalette(strings.NewReader(bad)); err == nil {
+			t.Errorf("Bad palette %q parsed", bad)
+		}
+	}
+}
+
+func TestPaletteFilter(t *testing.T) {
+	base := ExtendPalette(Arne16)
+	src := NewRGBASurface(4, 4)
+	src.Clear(Arne16[3])
+	src.Set(1, 1, Arne16[2])
+	dest := NewRGBASurface(4, 4)
+
+	filter := NewPaletteFilter(base)
+	filter.Apply(src, dest)
+	if dest.At(0, 0) != Arne16[3] || dest.At(1, 1) != Arne16[2] {
+		t.Errorf("Base palette changed colors")
+	}
+
+	filter.SetColors(LerpPalette(base, Black, 1))
+	filter.Apply(src, dest)
+	if dest.At(0, 0) != Black || dest.At(1, 1) != Black {
+		t.Errorf("Palette effect not applied")
+	}
+
+	faded := LerpPalette(color.Palette{White}, Black, .5)
+	if faded[0] != (color.RGBA{0x7F, 0x7F, 0x7F, 0xFF}) && faded[0] != (color.RGBA{0x80, 0x80, 0x80, 0xFF}) {
+		t.Errorf("Bad faded color %v", faded[0])
+	}
+}
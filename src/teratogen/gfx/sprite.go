@@ -19,6 +19,7 @@ package gfx
 
 import (
 	"image"
+	"image/color"
 	"sort"
 	"unsafe"
 )
@@ -31,12 +32,34 @@ type Sprite struct {
 	Layer    int
 	Offset   image.Point
 	Drawable Drawable
+	// Tint is applied to drawables that can be tinted, see BlitTinted. Nil
+	// for no tint.
+	Tint color.Color
 }
 
 func (s Sprite) Draw() {
+	if s.Tint != nil {
+		Tint(s.Drawable, s.Tint).Draw(s.Offset)
+		return
+	}
 	s.Drawable.Draw(s.Offset)
 }
 
+// Extended is implemented by drawables that know the screen area they cover
+// when drawn at the origin.
+type Extended interface {
+	Extent() image.Rectangle
+}
+
+// IsVisibleIn returns whether the sprite might draw something inside the
+// area. Sprites whose drawables don't know their extent are always visible.
+func (s Sprite) IsVisibleIn(area image.Rectangle) bool {
+	if e, ok := s.Drawable.(Extended); ok {
+		return e.Extent().Add(s.Offset).Overlaps(area)
+	}
+	return true
+}
+
 // For sorting sprites.
 
 type SpriteBatch []Sprite
@@ -66,6 +89,20 @@ func (s SpriteBatch) Sort() {
 	sort.Sort(s)
 }
 
+// Cull removes the sprites that aren't visible in the area from the batch.
+// The order of the remaining sprites is not preserved.
+func (s SpriteBatch) Cull(area image.Rectangle) SpriteBatch {
+	for i := 0; i < len(s); {
+		if s[i].IsVisibleIn(area) {
+			i++
+			continue
+		}
+		s[i] = s[len(s)-1]
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
 // Draw draws a SpriteBatch that is assumed to be sorted. Duplicate sprites
 // are skipped.
 func (s SpriteBatch) Draw() {
@@ -0,0 +1,47 @@
+// sprite_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"testing"
+)
+
+type unboundedDrawable struct{}
+
+func (unboundedDrawable) Draw(offset image.Point) {}
+
+func TestSpriteCull(t *testing.T) {
+	d := ImageDrawable{nil, image.Rect(0, 0, 8, 8), image.Pt(-4, -4), nil}
+	batch := SpriteBatch{
+		{0, image.Pt(0, 0), d, nil},
+		{1, image.Pt(-10, 0), d, nil},
+		{2, image.Pt(103, 50), d, nil},
+		{3, image.Pt(104, 50), d, nil},
+		{4, image.Pt(-100, -100), unboundedDrawable{}, nil}}
+	batch = batch.Cull(image.Rect(0, 0, 100, 100))
+	batch.Sort()
+
+	layers := []int{}
+	for _, s := range batch {
+		layers = append(layers, s.Layer)
+	}
+	if len(layers) != 3 || layers[0] != 0 || layers[1] != 2 || layers[2] != 4 {
+		t.Errorf("Culling left layers %v, expected [0 2 4]", layers)
+	}
+}
@@ -0,0 +1,425 @@
+// surface.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"unsafe"
+)
+
+// Surface is a 32-bit pixel surface that the graphics code can draw on and
+// blit from. Both SDL surfaces and the pure-Go RGBASurface implement it.
+type Surface interface {
+	Surface32Bit
+
+	Set(x, y int, c color.Color)
+	FillRect(rect image.Rectangle, c color.Color)
+
+	// Clear fills the current clip rectangle of the surface.
+	Clear(c color.Color)
+
+	SetClipRect(rect image.Rectangle)
+	ClearClipRect()
+	// ClipRect returns the area of the surface that drawing is limited to.
+	ClipRect() image.Rectangle
+
+	// SetColorKey makes pixels of the given color transparent when the
+	// surface is blitted.
+	SetColorKey(c color.Color)
+	// ColorKey returns the pixel value of the transparent color, if the
+	// surface has one.
+	ColorKey() (key uint32, ok bool)
+}
+
+// Backend creates surfaces for a specific rendering implementation.
+type Backend interface {
+	NewSurface(w, h int) Surface
+	ToSurface(img image.Image) Surface
+}
+
+// FastBlitter is implemented by backends that have a faster blitter of
+// their own for their surfaces.
+type FastBlitter interface {
+	// Blit works like the Blit function, but only handles surfaces made by
+	// the backend. It returns false without drawing anything for other
+	// surfaces.
+	Blit(src Surface, bounds image.Rectangle, x, y int, dest Surface) bool
+}
+
+// RGBABackend makes RGBASurfaces. It needs no display and can be used for
+// headless rendering.
+type RGBABackend struct{}
+
+func (RGBABackend) NewSurface(w, h int) Surface { return NewRGBASurface(w, h) }
+
+func (RGBABackend) ToSurface(img image.Image) Surface {
+	result := NewRGBASurface(img.Bounds().Dx(), img.Bounds().Dy())
+	draw.Draw(result, result.Bounds(), img, img.Bounds().Min, draw.Src)
+	return result
+}
+
+var backend Backend = RGBABackend{}
+
+var frame Surface
+
+// SetBackend sets the backend used by NewSurface and ToSurface. The default
+// backend is RGBABackend, the game sets up its SDL backend when it opens its
+// window.
+func SetBackend(b Backend) {
+	backend = b
+}
+
+// NewSurface makes a new surface using the current backend.
+func NewSurface(w, h int) Surface {
+	return backend.NewSurface(w, h)
+}
+
+// ToSurface converts an image into a surface using the current backend.
+func ToSurface(img image.Image) Surface {
+	return backend.ToSurface(img)
+}
+
+// SetFrame sets the surface that drawables are rendered to.
+func SetFrame(s Surface) {
+	frame = s
+}
+
+// Frame returns the surface that drawables are currently rendered to.
+func Frame() Surface {
+	return frame
+}
+
+// WithFrame runs fn with s as the frame and restores the previous frame
+// afterwards.
+func WithFrame(s Surface, fn func()) {
+	prev := frame
+	frame = s
+	defer func() { frame = prev }()
+	fn()
+}
+
+// Blit copies the bounds rectangle of src to position (x, y) on dest. It
+// uses the blitter of the backend when the backend is a FastBlitter.
+func Blit(src Surface, bounds image.Rectangle, x, y int, dest Surface) {
+	BlitTinted(src, bounds, x, y, dest, nil)
+}
+
+// BlitTinted is like Blit, but multiplies the pixels of src by the
+// non-premultiplied channels of tint. The alpha of the tint makes the
+// image translucent. A nil tint leaves the pixels as they are.
+func BlitTinted(src Surface, bounds image.Rectangle, x, y int, dest Surface, tint color.Color) {
+	blend := tint != nil || isBlended(src)
+	if fb, ok := backend.(FastBlitter); ok && !blend {
+		if fb.Blit(src, bounds, x, y, dest) {
+			return
+		}
+	}
+
+	// Clip the source rectangle to the source and the target areas.
+	bounds = bounds.Intersect(src.Bounds())
+	offset := image.Pt(x, y).Sub(bounds.Min)
+	bounds = bounds.Intersect(dest.ClipRect().Sub(offset))
+	if bounds.Empty() {
+		return
+	}
+
+	if blend {
+		blendBlit(src, bounds, offset, dest, tint)
+		return
+	}
+
+	key, keyed := src.ColorKey()
+	_, sameFormat := src.(*RGBASurface)
+	if _, ok := dest.(*RGBASurface); !ok {
+		sameFormat = false
+	}
+
+	sPix, sPitch := src.Pixels32(), src.Pitch32()
+	dPix, dPitch := dest.Pixels32(), dest.Pitch32()
+	if sameFormat && !keyed {
+		// Opaque blits are plain copies.
+		for sy := bounds.Min.Y; sy < bounds.Max.Y; sy++ {
+			d := bounds.Min.X + offset.X + (sy+offset.Y)*dPitch
+			copy(dPix[d:d+bounds.Dx()], sPix[bounds.Min.X+sy*sPitch:bounds.Max.X+sy*sPitch])
+		}
+		return
+	}
+	for sy := bounds.Min.Y; sy < bounds.Max.Y; sy++ {
+		dy := sy + offset.Y
+		for sx := bounds.Min.X; sx < bounds.Max.X; sx++ {
+			c := sPix[sx+sy*sPitch]
+			if keyed && c == key {
+				continue
+			}
+			if !sameFormat {
+				c = dest.MapColor(src.GetColor(c))
+			}
+			dPix[sx+offset.X+dy*dPitch] = c
+		}
+	}
+}
+
+// blendBlit draws an already clipped area of src on dest with alpha
+// blending.
+func blendBlit(src Surface, bounds image.Rectangle, offset image.Point, dest Surface, tint color.Color) {
+	tr, tg, tb, ta := uint32(0xFF), uint32(0xFF), uint32(0xFF), uint32(0xFF)
+	if tint != nil {
+		t := color.NRGBAModel.Convert(tint).(color.NRGBA)
+		tr, tg, tb, ta = uint32(t.R), uint32(t.G), uint32(t.B), uint32(t.A)
+	}
+	key, keyed := src.ColorKey()
+	blended := isBlended(src)
+
+	sl, ok1 := layoutOf(src)
+	dl, ok2 := layoutOf(dest)
+	if !ok1 || !ok2 {
+		blendColors(src, bounds, offset, dest, [4]uint32{tr, tg, tb, ta})
+		return
+	}
+	// Without an alpha channel on the source or with blending off, the
+	// source pixels are opaque.
+	if !blended {
+		sl.hasAlpha = false
+	}
+
+	// Work directly on the channels of the pixel values. The source channels
+	// are premultiplied by alpha, like the ones of image.RGBA.
+	sPix, sPitch := src.Pixels32(), src.Pitch32()
+	dPix, dPitch := dest.Pixels32(), dest.Pitch32()
+	for sy := bounds.Min.Y; sy < bounds.Max.Y; sy++ {
+		dy := sy + offset.Y
+		for sx := bounds.Min.X; sx < bounds.Max.X; sx++ {
+			c := sPix[sx+sy*sPitch]
+			if keyed && c == key {
+				continue
+			}
+			a := uint32(0xFF)
+			if sl.hasAlpha {
+				a = c >> sl.a & 0xFF
+			}
+			if tint != nil {
+				a = a * ta / 0xFF
+			}
+			if a == 0 {
+				continue
+			}
+			r, g, b := c>>sl.r&0xFF, c>>sl.g&0xFF, c>>sl.b&0xFF
+			if tint != nil {
+				r = r * tr / 0xFF * ta / 0xFF
+				g = g * tg / 0xFF * ta / 0xFF
+				b = b * tb / 0xFF * ta / 0xFF
+			}
+
+			// Opaque pixels don't need the destination pixel.
+			d := &dPix[sx+offset.X+dy*dPitch]
+			inv := 0xFF - a
+			if inv != 0 {
+				r += (*d >> dl.r & 0xFF) * inv / 0xFF
+				g += (*d >> dl.g & 0xFF) * inv / 0xFF
+				b += (*d >> dl.b & 0xFF) * inv / 0xFF
+			}
+			out := r&0xFF<<dl.r | g&0xFF<<dl.g | b&0xFF<<dl.b
+			if dl.hasAlpha {
+				out |= (a + (*d>>dl.a&0xFF)*inv/0xFF) & 0xFF << dl.a
+			}
+			*d = out
+		}
+	}
+}
+
+// blendColors is the slow version of blendBlit for surfaces whose pixels
+// aren't made of 8-bit channels. It converts every pixel through
+// color.Color.
+func blendColors(src Surface, bounds image.Rectangle, offset image.Point, dest Surface, tint [4]uint32) {
+	tr, tg, tb, ta := tint[0], tint[1], tint[2], tint[3]
+	key, keyed := src.ColorKey()
+	blended := isBlended(src)
+
+	sPix, sPitch := src.Pixels32(), src.Pitch32()
+	dPix, dPitch := dest.Pixels32(), dest.Pitch32()
+	for sy := bounds.Min.Y; sy < bounds.Max.Y; sy++ {
+		dy := sy + offset.Y
+		for sx := bounds.Min.X; sx < bounds.Max.X; sx++ {
+			c := sPix[sx+sy*sPitch]
+			if keyed && c == key {
+				continue
+			}
+			// The channels are premultiplied by alpha.
+			r, g, b, a := src.GetColor(c).RGBA()
+			r, g, b, a = r>>8, g>>8, b>>8, a>>8
+			if !blended {
+				a = 0xFF
+			}
+			a = a * ta / 0xFF
+			r = r * tr / 0xFF * ta / 0xFF
+			g = g * tg / 0xFF * ta / 0xFF
+			b = b * tb / 0xFF * ta / 0xFF
+			if a == 0 {
+				continue
+			}
+
+			d := &dPix[sx+offset.X+dy*dPitch]
+			dr, dg, db, da := dest.GetColor(*d).RGBA()
+			inv := 0xFF - a
+			*d = dest.MapColor(color.RGBA{
+				uint8(r + (dr>>8)*inv/0xFF),
+				uint8(g + (dg>>8)*inv/0xFF),
+				uint8(b + (db>>8)*inv/0xFF),
+				uint8(a + (da>>8)*inv/0xFF)})
+		}
+	}
+}
+
+// channelLayout is the bit offsets of the 8-bit color channels in 32-bit
+// pixel values.
+type channelLayout struct {
+	r, g, b, a uint
+	hasAlpha   bool
+}
+
+// All RGBASurfaces have the same layout.
+var rgbaLayout, _ = mapLayout(&RGBASurface{})
+
+// layoutOf returns the channel layout of a surface. It fails if the pixels
+// aren't made of byte-aligned 8-bit channels.
+func layoutOf(s Surface32Bit) (layout channelLayout, ok bool) {
+	if _, ok := s.(*RGBASurface); ok {
+		return rgbaLayout, true
+	}
+	return mapLayout(s)
+}
+
+// mapLayout finds the channel layout of a surface by mapping pure colors
+// into pixel values.
+func mapLayout(s Surface32Bit) (layout channelLayout, ok bool) {
+	shift := func(mask uint32) (uint, bool) {
+		for i := uint(0); i < 32; i += 8 {
+			if mask == 0xFF<<i {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	var okR, okG, okB bool
+	layout.r, okR = shift(s.MapColor(color.RGBA{0xFF, 0, 0, 0}))
+	layout.g, okG = shift(s.MapColor(color.RGBA{0, 0xFF, 0, 0}))
+	layout.b, okB = shift(s.MapColor(color.RGBA{0, 0, 0xFF, 0}))
+	if !okR || !okG || !okB {
+		return
+	}
+	// Surfaces without an alpha channel map alpha to nothing.
+	if alpha := s.MapColor(color.RGBA{0, 0, 0, 0xFF}); alpha != 0 {
+		if layout.a, layout.hasAlpha = shift(alpha); !layout.hasAlpha {
+			return
+		}
+	}
+	return layout, true
+}
+
+func isBlended(s Surface) bool {
+	r, ok := s.(*RGBASurface)
+	return ok && r.blended
+}
+
+// RGBASurface is a Surface backed by an image.RGBA. It does not depend on
+// SDL, and the embedded image can be encoded directly as a screenshot.
+type RGBASurface struct {
+	*image.RGBA
+	clip    image.Rectangle
+	clipped bool
+	key     uint32
+	keyed   bool
+	blended bool
+}
+
+func NewRGBASurface(w, h int) *RGBASurface {
+	return &RGBASurface{RGBA: image.NewRGBA(image.Rect(0, 0, w, h))}
+}
+
+// NewBlendedSurface makes a surface from an image that is alpha blended
+// using the image's alpha channel when it is blitted. It can be blitted on
+// surfaces of any backend.
+func NewBlendedSurface(img image.Image) *RGBASurface {
+	result := RGBABackend{}.ToSurface(img).(*RGBASurface)
+	result.blended = true
+	return result
+}
+
+// Pixels32 maps the bytes of the image into 32-bit pixel values.
+func (s *RGBASurface) Pixels32() []uint32 {
+	if len(s.Pix) == 0 {
+		return nil
+	}
+	return (*[1 << 28]uint32)(unsafe.Pointer(&s.Pix[0]))[: len(s.Pix)/4 : len(s.Pix)/4]
+}
+
+func (s *RGBASurface) Pitch32() int {
+	return s.Stride / 4
+}
+
+func (s *RGBASurface) MapColor(c color.Color) uint32 {
+	r, g, b, a := c.RGBA()
+	bytes := [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+	return *(*uint32)(unsafe.Pointer(&bytes[0]))
+}
+
+func (s *RGBASurface) GetColor(c32 uint32) color.Color {
+	bytes := *(*[4]uint8)(unsafe.Pointer(&c32))
+	return color.RGBA{bytes[0], bytes[1], bytes[2], bytes[3]}
+}
+
+func (s *RGBASurface) FillRect(rect image.Rectangle, c color.Color) {
+	rect = rect.Intersect(s.ClipRect())
+	c32 := s.MapColor(c)
+	pix, pitch := s.Pixels32(), s.Pitch32()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		line := pix[y*pitch+rect.Min.X : y*pitch+rect.Max.X]
+		for x := range line {
+			line[x] = c32
+		}
+	}
+}
+
+func (s *RGBASurface) Clear(c color.Color) {
+	s.FillRect(s.Bounds(), c)
+}
+
+func (s *RGBASurface) SetClipRect(rect image.Rectangle) {
+	s.clip, s.clipped = rect, true
+}
+
+func (s *RGBASurface) ClearClipRect() {
+	s.clipped = false
+}
+
+func (s *RGBASurface) SetColorKey(c color.Color) {
+	s.key, s.keyed = s.MapColor(c), true
+}
+
+func (s *RGBASurface) ColorKey() (key uint32, ok bool) {
+	return s.key, s.keyed
+}
+
+func (s *RGBASurface) ClipRect() image.Rectangle {
+	if !s.clipped {
+		return s.Bounds()
+	}
+	return s.clip.Intersect(s.Bounds())
+}
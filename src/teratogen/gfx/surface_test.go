@@ -0,0 +1,239 @@
+// surface_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRGBAColors(t *testing.T) {
+	s := NewRGBASurface(4, 4)
+	for _, col := range []color.RGBA{Black, Cyan, Gold, {1, 2, 3, 4}} {
+		if got := s.GetColor(s.MapColor(col)); got != col {
+			t.Errorf("Color %v mapped back to %v", col, got)
+		}
+	}
+
+	s.Set(1, 2, Gold)
+	if got := s.GetColor(s.Pixels32()[1+2*s.Pitch32()]); got != Gold {
+		t.Errorf("Set pixel reads back as %v", got)
+	}
+	if s.At(1, 2) != Gold {
+		t.Errorf("Pixels32 and image data disagree")
+	}
+}
+
+func TestRGBAClip(t *testing.T) {
+	s := NewRGBASurface(8, 8)
+	s.SetClipRect(image.Rect(2, 2, 4, 4))
+	s.Clear(White)
+	s.ClearClipRect()
+	if s.ClipRect() != s.Bounds() {
+		t.Errorf("Cleared clip rect %s, expected %s", s.ClipRect(), s.Bounds())
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			expected := color.Color(color.RGBA{})
+			if image.Pt(x, y).In(image.Rect(2, 2, 4, 4)) {
+				expected = White
+			}
+			if s.At(x, y) != expected {
+				t.Errorf("Bad pixel %v at (%d, %d)", s.At(x, y), x, y)
+			}
+		}
+	}
+}
+
+func TestRGBABlit(t *testing.T) {
+	src := NewRGBASurface(4, 4)
+	src.Clear(Cyan)
+	src.Set(1, 1, Red)
+	src.SetColorKey(Cyan)
+
+	dest := NewRGBASurface(8, 8)
+	dest.Clear(Black)
+	dest.SetClipRect(image.Rect(0, 0, 6, 6))
+	Blit(src, src.Bounds(), 4, 4, dest)
+	Blit(src, image.Rect(1, 1, 2, 2), -1, 0, dest)
+
+	if dest.At(5, 5) != Red {
+		t.Errorf("Blitted pixel missing")
+	}
+	if dest.At(4, 4) != Black {
+		t.Errorf("Color key not respected")
+	}
+	if dest.At(7, 7) != Black {
+		t.Errorf("Clip rect not respected")
+	}
+	if dest.At(0, 0) != Black {
+		t.Errorf("Blit to negative offset not clipped")
+	}
+}
+
+func TestHeadlessDrawable(t *testing.T) {
+	SetFrame(NewSurface(16, 16))
+	defer SetFrame(nil)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 0, Gold)
+	d := ImageDrawable{ToSurface(img), img.Bounds(), image.Pt(0, 0), nil}
+	d.Draw(image.Pt(10, 10))
+	Line(Frame(), image.Pt(0, 15), image.Pt(3, 15), White)
+
+	if Frame().(*RGBASurface).At(11, 10) != Gold {
+		t.Errorf("Drawable not drawn on frame")
+	}
+	if Frame().(*RGBASurface).At(3, 15) != White {
+		t.Errorf("Line not drawn on frame")
+	}
+}
+
+func TestBlitScaled(t *testing.T) {
+	src := NewRGBASurface(3, 2)
+	src.Set(0, 0, Red)
+	src.Set(2, 1, Gold)
+
+	for scale := 1; scale <= 4; scale++ {
+		dest := NewRGBASurface(20, 20)
+		BlitScaled(src, dest, scale, image.Pt(1, 2))
+		if dest.At(1, 2) != Red || dest.At(scale, scale+1) != Red {
+			t.Errorf("Bad top left block at scale %d", scale)
+		}
+		if dest.At(1+3*scale-1, 2+2*scale-1) != Gold {
+			t.Errorf("Bad bottom right block at scale %d", scale)
+		}
+		if dest.At(0, 2) != (color.RGBA{}) || dest.At(1+3*scale, 2) != (color.RGBA{}) {
+			t.Errorf("Blit spilled at scale %d", scale)
+		}
+	}
+
+	// Clipped blit.
+	dest := NewRGBASurface(4, 4)
+	BlitScaled(src, dest, 2, image.Pt(-1, 1))
+	if dest.At(0, 1) != Red || dest.At(0, 2) != Red || dest.At(1, 1) != (color.RGBA{}) {
+		t.Errorf("Bad clipped blit")
+	}
+}
+
+func TestBlendedBlit(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, color.NRGBA{0xFF, 0, 0, 0xFF})
+	img.Set(1, 0, color.NRGBA{0xFF, 0, 0, 0x80})
+	src := NewBlendedSurface(img)
+	if _, keyed := src.ColorKey(); keyed {
+		t.Errorf("Blended surface has a color key")
+	}
+
+	dest := NewRGBASurface(3, 1)
+	dest.Clear(Blue)
+	Blit(src, src.Bounds(), 0, 0, dest)
+
+	if dest.At(0, 0) != Red {
+		t.Errorf("Opaque pixel blended to %v", dest.At(0, 0))
+	}
+	if c := dest.At(1, 0).(color.RGBA); c.R != 0x80 || c.G != 0 || c.B != 0x7F || c.A != 0xFF {
+		t.Errorf("Bad half transparent pixel %v", c)
+	}
+	if dest.At(2, 0) != Blue {
+		t.Errorf("Transparent pixel drawn")
+	}
+}
+
+// xbgrSurface has blue in the high byte and no alpha channel, like the
+// video surfaces of some SDL setups.
+type xbgrSurface struct {
+	*RGBASurface
+}
+
+func (s xbgrSurface) MapColor(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+	return r>>8<<16 | g>>8<<8 | b>>8
+}
+
+func (s xbgrSurface) GetColor(c32 uint32) color.Color {
+	return color.RGBA{uint8(c32 >> 16), uint8(c32 >> 8), uint8(c32), 0xFF}
+}
+
+func TestBlendLayouts(t *testing.T) {
+	if l, ok := layoutOf(xbgrSurface{}); !ok || l.r != 16 || l.b != 0 || l.hasAlpha {
+		t.Fatalf("Bad channel layout %v", l)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 37)
+	}
+	src := NewBlendedSurface(img)
+	for _, tint := range []color.Color{nil, Dimmed(.5), Translucent(.3)} {
+		fast := xbgrSurface{NewRGBASurface(16, 16)}
+		slow := xbgrSurface{NewRGBASurface(16, 16)}
+		for _, s := range []xbgrSurface{fast, slow} {
+			pix := s.Pixels32()
+			for i := range pix {
+				pix[i] = s.MapColor(Gold)
+			}
+		}
+		BlitTinted(src, src.Bounds(), 0, 0, fast, tint)
+		tr, tg, tb, ta := uint32(0xFF), uint32(0xFF), uint32(0xFF), uint32(0xFF)
+		if tint != nil {
+			t := color.NRGBAModel.Convert(tint).(color.NRGBA)
+			tr, tg, tb, ta = uint32(t.R), uint32(t.G), uint32(t.B), uint32(t.A)
+		}
+		blendColors(src, src.Bounds(), image.ZP, slow, [4]uint32{tr, tg, tb, ta})
+
+		fp, sp := fast.Pixels32(), slow.Pixels32()
+		for i := range fp {
+			if fp[i] != sp[i] {
+				t.Errorf("Tint %v: blended pixel %d is %x, expected %x", tint, i, fp[i], sp[i])
+				break
+			}
+		}
+	}
+}
+
+func TestTintedBlit(t *testing.T) {
+	src := NewRGBASurface(2, 1)
+	src.Set(0, 0, White)
+	src.Set(1, 0, Cyan)
+	src.SetColorKey(Cyan)
+
+	dest := NewRGBASurface(2, 1)
+	dest.Clear(Black)
+	BlitTinted(src, src.Bounds(), 0, 0, dest, Dimmed(.5))
+	if c := dest.At(0, 0).(color.RGBA); c.R != 0x7F || c.G != 0x7F || c.B != 0x7F {
+		t.Errorf("Bad dimmed pixel %v", c)
+	}
+	if dest.At(1, 0) != Black {
+		t.Errorf("Color key not respected in tinted blit")
+	}
+
+	dest.Clear(Black)
+	BlitTinted(src, src.Bounds(), 0, 0, dest, Translucent(.5))
+	if c := dest.At(0, 0).(color.RGBA); c.R != 0x7F || c.A != 0xFF {
+		t.Errorf("Bad translucent pixel %v", c)
+	}
+
+	d := ImageDrawable{src, src.Bounds(), image.Pt(0, 0), nil}
+	tinted := Tint(Tint(d, Red), Translucent(.5)).(ImageDrawable)
+	if tinted.Tint != (color.NRGBA{0xFF, 0, 0, 0x7F}) {
+		t.Errorf("Tints not combined: %v", tinted.Tint)
+	}
+}
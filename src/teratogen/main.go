@@ -18,15 +18,37 @@
 package main
 
 import (
+	"flag"
 	"math/rand"
+	"strings"
 	"teratogen/app"
+	"teratogen/cache"
 	"teratogen/screen"
 	"time"
 )
 
+// stringList is a flag that can be given multiple times.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+var packs stringList
+
+func init() {
+	flag.Var(&packs, "pack", "load an asset pack directory or zip file, can be repeated")
+}
+
 func main() {
+	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
 
+	cache.AddPacks(packs...)
+
 	a := app.Get()
 	a.PushState(screen.Intro())
 	a.Run()
@@ -34,7 +34,7 @@ func (m *Mapgen) bspRooms(bounds image.Rectangle) {
 
 	area := bounds.Dx() * bounds.Dy()
 
-	if rand.Float64()*float64(maxArea-minArea)+float64(minArea) < float64(area) {
+	if m.rng.Float64()*float64(maxArea-minArea)+float64(minArea) < float64(area) {
 		m.splitRoom(bounds)
 		return
 	}
@@ -48,7 +48,7 @@ func (m *Mapgen) extraDoors(bounds image.Rectangle) {
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			pt := image.Pt(x, y)
-			if m.isDoorSite(pt) && rand.Float64() < extraDoorChance {
+			if m.isDoorSite(pt) && m.rng.Float64() < extraDoorChance {
 				m.setTerrain(pt, world.DoorTerrain)
 			}
 		}
@@ -78,9 +78,9 @@ func (m *Mapgen) digRoom(bounds image.Rectangle) {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			pos := image.Pt(x, y)
 			m.setTerrain(pos, world.FloorTerrain)
-			if rand.Intn(16) == 0 {
+			if m.rng.Intn(16) == 0 {
 				m.setTerrain(pos, world.BarrelTerrain+
-					world.Terrain(rand.Intn(int(world.PlantTerrain)+1-int(world.BarrelTerrain))))
+					world.Terrain(m.rng.Intn(int(world.PlantTerrain)+1-int(world.BarrelTerrain))))
 			}
 			m.setOpen(m.chart.At(pos), true)
 		}
@@ -88,13 +88,13 @@ func (m *Mapgen) digRoom(bounds image.Rectangle) {
 }
 
 func (m *Mapgen) splitRoom(bounds image.Rectangle) {
-	wall := makeSplitWall(bounds)
+	wall := makeSplitWall(m.rng, bounds)
 	left, right := wall.Halves(bounds)
 	m.bspRooms(left)
 	m.bspRooms(right)
 
 	doorSites := m.doorSites(wall)
-	m.setTerrain(doorSites[rand.Intn(len(doorSites))], world.DoorTerrain)
+	m.setTerrain(doorSites[m.rng.Intn(len(doorSites))], world.DoorTerrain)
 }
 
 // DoorSites returns points along the wall which are suitable for placing a
@@ -112,25 +112,25 @@ func (m *Mapgen) doorSites(w wall) (result []image.Point) {
 
 // makeSplitWall picks a wall to split a room with, and returns a
 // specification of the wall.
-func makeSplitWall(bounds image.Rectangle) wall {
+func makeSplitWall(rng *rand.Rand, bounds image.Rectangle) wall {
 	vertWeight := int(math.Max(0, float64(bounds.Dx()-3)))
 	horzWeight := int(math.Max(0, float64(bounds.Dy()-3)))
 
 	isVertical := false
 	if horzWeight > 0 && vertWeight > 0 {
-		isVertical = rand.Intn(vertWeight+horzWeight) < vertWeight
+		isVertical = rng.Intn(vertWeight+horzWeight) < vertWeight
 	} else if vertWeight > 0 {
 		isVertical = true
 	}
 
 	if isVertical {
-		offset := rand.Intn(bounds.Dx()-2) + 1
+		offset := rng.Intn(bounds.Dx()-2) + 1
 		return wall{
 			image.Pt(bounds.Min.X+offset, bounds.Min.Y),
 			image.Pt(bounds.Min.X+offset, bounds.Max.Y)}
 	}
 
-	offset := rand.Intn(bounds.Dy()-2) + 1
+	offset := rng.Intn(bounds.Dy()-2) + 1
 	return wall{
 		image.Pt(bounds.Min.X, bounds.Min.Y+offset),
 		image.Pt(bounds.Max.X, bounds.Min.Y+offset)}
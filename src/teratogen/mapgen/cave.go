@@ -0,0 +1,318 @@
+// cave.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"errors"
+	"image"
+	"math/rand"
+	"teratogen/space"
+	"teratogen/tile"
+	"teratogen/world"
+)
+
+const (
+	caveW = 40
+	caveH = 40
+
+	// Chance for a cell to start out as open space before the automaton
+	// runs.
+	caveOpenChance = 0.55
+	caveIterations = 4
+
+	// The connected cave area must cover at least this fraction of the map
+	// or the cave is rejected and dug again.
+	caveMinCoverage = 0.3
+
+	maxCaveTries = 64
+)
+
+// CaveMap generates an irregular cave level by running a cellular automaton
+// on the hex grid. Only the largest cave area that big mobs can move around
+// in is kept. Like TestMap, it returns the entry stair location and the exit
+// location that will be turned into the portal to the next floor.
+func (m *Mapgen) CaveMap(start space.Location, depth int) (entry, exit space.Location, err error) {
+	m.init(start)
+
+	for i := 0; i < maxCaveTries; i++ {
+		cave := newCaveGrid(image.Pt(caveW, caveH))
+		cave.scatter(m.rng, caveOpenChance)
+		for j := 0; j < caveIterations; j++ {
+			cave = cave.step()
+		}
+
+		entryPt, exitPt, ok := cave.placeStairs()
+		if !ok {
+			continue
+		}
+
+		m.digCave(cave)
+		m.setTerrain(entryPt, world.StairTerrain)
+		return m.chart.At(entryPt), m.chart.At(exitPt), nil
+	}
+	err = errors.New("Couldn't dig a cave")
+	return
+}
+
+// digCave writes the cave cells into the world. Only walls that border on
+// open space are written, the solid rock beyond them is left as void.
+func (m *Mapgen) digCave(cave *caveGrid) {
+	for y := 0; y < cave.dim.Y; y++ {
+		for x := 0; x < cave.dim.X; x++ {
+			pt := image.Pt(x, y)
+			if cave.isOpen(pt) {
+				m.setTerrain(pt, world.FloorTerrain)
+				m.setOpen(m.chart.At(pt), true)
+			} else if cave.openNeighbors(pt) > 0 {
+				m.setTerrain(pt, world.WallTerrain)
+			}
+		}
+	}
+}
+
+// caveGrid is a bounded cell grid for running the cave automaton. Points
+// outside the grid are always closed.
+type caveGrid struct {
+	dim  image.Point
+	open []bool
+}
+
+func newCaveGrid(dim image.Point) *caveGrid {
+	return &caveGrid{dim, make([]bool, dim.X*dim.Y)}
+}
+
+func (c *caveGrid) contains(pt image.Point) bool {
+	return pt.In(image.Rectangle{image.Pt(0, 0), c.dim})
+}
+
+func (c *caveGrid) isOpen(pt image.Point) bool {
+	return c.contains(pt) && c.open[pt.X+pt.Y*c.dim.X]
+}
+
+func (c *caveGrid) setOpen(pt image.Point, isOpen bool) {
+	if c.contains(pt) {
+		c.open[pt.X+pt.Y*c.dim.X] = isOpen
+	}
+}
+
+// isBorder returns whether the point is on the outermost ring of the grid.
+// Border cells are kept closed so that the cave stays walled in.
+func (c *caveGrid) isBorder(pt image.Point) bool {
+	return pt.X == 0 || pt.Y == 0 || pt.X == c.dim.X-1 || pt.Y == c.dim.Y-1
+}
+
+func (c *caveGrid) openNeighbors(pt image.Point) (result int) {
+	for _, vec := range tile.HexDirs {
+		if c.isOpen(pt.Add(vec)) {
+			result++
+		}
+	}
+	return
+}
+
+func (c *caveGrid) scatter(rng *rand.Rand, openChance float64) {
+	for y := 0; y < c.dim.Y; y++ {
+		for x := 0; x < c.dim.X; x++ {
+			pt := image.Pt(x, y)
+			c.setOpen(pt, !c.isBorder(pt) && rng.Float64() < openChance)
+		}
+	}
+}
+
+// step runs one generation of the cave automaton. A cell with four or more
+// walls among its six hex neighbors fills up, and a cell with two or fewer
+// walls opens up. Other cells stay as they are.
+func (c *caveGrid) step() *caveGrid {
+	result := newCaveGrid(c.dim)
+	for y := 0; y < c.dim.Y; y++ {
+		for x := 0; x < c.dim.X; x++ {
+			pt := image.Pt(x, y)
+			if c.isBorder(pt) {
+				continue
+			}
+			walls := len(tile.HexDirs) - c.openNeighbors(pt)
+			switch {
+			case walls >= 4:
+				result.setOpen(pt, false)
+			case walls <= 2:
+				result.setOpen(pt, true)
+			default:
+				result.setOpen(pt, c.isOpen(pt))
+			}
+		}
+	}
+	return result
+}
+
+// fitsBig returns whether a big mob, which covers a cell and its six
+// neighbors, fits at the point.
+func (c *caveGrid) fitsBig(pt image.Point) bool {
+	return c.isOpen(pt) && c.openNeighbors(pt) == len(tile.HexDirs)
+}
+
+// region flood fills the area of passable points connected to the given
+// point. The points are returned in the order they were reached.
+func (c *caveGrid) region(origin image.Point, passable func(image.Point) bool) (result []image.Point) {
+	if !passable(origin) {
+		return
+	}
+	seen := map[image.Point]bool{origin: true}
+	result = []image.Point{origin}
+	for i := 0; i < len(result); i++ {
+		for _, vec := range tile.HexDirs {
+			pt := result[i].Add(vec)
+			if passable(pt) && !seen[pt] {
+				seen[pt] = true
+				result = append(result, pt)
+			}
+		}
+	}
+	return
+}
+
+// largestRegion returns the largest connected area of passable points.
+func (c *caveGrid) largestRegion(passable func(image.Point) bool) (largest []image.Point) {
+	seen := map[image.Point]bool{}
+	for y := 0; y < c.dim.Y; y++ {
+		for x := 0; x < c.dim.X; x++ {
+			pt := image.Pt(x, y)
+			if !passable(pt) || seen[pt] {
+				continue
+			}
+			region := c.region(pt, passable)
+			for _, p := range region {
+				seen[p] = true
+			}
+			if len(region) > len(largest) {
+				largest = region
+			}
+		}
+	}
+	return
+}
+
+// keepBigMobArea fills in every part of the cave except the largest area
+// big mobs can move around in, including the cells they cover while
+// standing at its edges. Narrow tunnels and pockets only small mobs could
+// get into are filled. Returns the size of the remaining open area.
+func (c *caveGrid) keepBigMobArea() int {
+	covered := map[image.Point]bool{}
+	for _, pt := range c.largestRegion(c.fitsBig) {
+		covered[pt] = true
+		for _, vec := range tile.HexDirs {
+			covered[pt.Add(vec)] = true
+		}
+	}
+	area := []image.Point{}
+	for pt := range covered {
+		area = append(area, pt)
+	}
+	c.keepOnly(area)
+	return len(area)
+}
+
+// keepOnly closes every open cell that isn't in the given point set.
+func (c *caveGrid) keepOnly(points []image.Point) {
+	keep := map[image.Point]bool{}
+	for _, pt := range points {
+		keep[pt] = true
+	}
+	for y := 0; y < c.dim.Y; y++ {
+		for x := 0; x < c.dim.X; x++ {
+			pt := image.Pt(x, y)
+			if !keep[pt] {
+				c.setOpen(pt, false)
+			}
+		}
+	}
+}
+
+// extremeCell returns the open cell with the smallest (dir < 0) or the
+// largest (dir > 0) x coordinate.
+func (c *caveGrid) extremeCell(dir int) (result image.Point, ok bool) {
+	for y := 0; y < c.dim.Y; y++ {
+		for x := 0; x < c.dim.X; x++ {
+			pt := image.Pt(x, y)
+			if c.isOpen(pt) && (!ok || (pt.X-result.X)*dir > 0) {
+				result, ok = pt, true
+			}
+		}
+	}
+	return
+}
+
+// placeStairs carves the entry and exit cells of the cave and prunes the
+// disconnected pockets. The stairs are placed in the same kind of
+// enclosures as the ones in the chunk maps: The entry opens only towards
+// positive x and the exit only towards negative x, so that the portal seam
+// between the floors has walls on every other side. Returns false if the
+// cave is unusable or big mobs can't get from the entry to the exit.
+func (c *caveGrid) placeStairs() (entry, exit image.Point, ok bool) {
+	if c.keepBigMobArea() < int(caveMinCoverage*float64(c.dim.X*c.dim.Y)) {
+		return
+	}
+
+	westmost, ok1 := c.extremeCell(-1)
+	eastmost, ok2 := c.extremeCell(1)
+	if !ok1 || !ok2 {
+		return
+	}
+
+	// The cells next to the ends of the cave are on the border at worst, so
+	// they stay in the grid. Everything else around them is beyond the
+	// extreme x coordinates except for one diagonal neighbor, which gets
+	// walled in.
+	entry = westmost.Sub(image.Pt(1, 0))
+	exit = eastmost.Add(image.Pt(1, 0))
+	c.setOpen(entry.Add(image.Pt(1, 1)), false)
+	c.setOpen(exit.Add(image.Pt(-1, -1)), false)
+	c.setOpen(entry, true)
+	c.setOpen(exit, true)
+
+	// Walling in the stairs may have cut off parts of the cave.
+	area := c.region(entry, c.isOpen)
+	c.keepOnly(area)
+	if !c.isOpen(exit) || c.openNeighbors(entry) != 1 || c.openNeighbors(exit) != 1 {
+		return
+	}
+
+	// It may also have cut the route big mobs had through the cave.
+	start, ok1 := c.nearestBigFit(entry)
+	goal, ok2 := c.nearestBigFit(exit)
+	if !ok1 || !ok2 {
+		return
+	}
+	for _, pt := range c.region(start, c.fitsBig) {
+		if pt == goal {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// nearestBigFit returns the closest open point to the origin where a big
+// mob fits.
+func (c *caveGrid) nearestBigFit(origin image.Point) (result image.Point, ok bool) {
+	for _, result = range c.region(origin, c.isOpen) {
+		if c.fitsBig(result) {
+			return result, true
+		}
+	}
+	return
+}
@@ -0,0 +1,74 @@
+// cave_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"math/rand"
+	"teratogen/space"
+	"teratogen/tile"
+	"teratogen/world"
+	"testing"
+)
+
+func TestCave(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		rand.Seed(seed)
+		w := world.New()
+		entry, exit, err := New(w).CaveMap(space.Loc(0, 0, 1), 2)
+		if err != nil {
+			t.Errorf("Seed %d: %s", seed, err)
+			continue
+		}
+
+		stairIcon := world.GetTerrainData(world.StairTerrain).Icon
+		if w.Terrain(entry).Icon != stairIcon {
+			t.Errorf("Seed %d: No stairs at cave entry %s", seed, entry)
+		}
+		if w.Terrain(exit).BlocksMove() {
+			t.Errorf("Seed %d: Blocked cave exit %s", seed, exit)
+		}
+
+		// Every open cell should be reachable from the entry.
+		reached := map[space.Location]bool{entry: true}
+		edge := []space.Location{entry}
+		for len(edge) > 0 {
+			loc := edge[0]
+			edge = edge[1:]
+			for _, vec := range tile.HexDirs {
+				next := w.Manifold.Offset(loc, vec)
+				if !reached[next] && w.Contains(next) && !w.Terrain(next).BlocksMove() {
+					reached[next] = true
+					edge = append(edge, next)
+				}
+			}
+		}
+
+		if !reached[exit] {
+			t.Errorf("Seed %d: Cave exit not reachable from entry", seed)
+		}
+
+		for y := 0; y < caveH; y++ {
+			for x := 0; x < caveW; x++ {
+				loc := space.Loc(int16(x), int16(y), 1)
+				if w.Contains(loc) && !w.Terrain(loc).BlocksMove() && !reached[loc] {
+					t.Errorf("Seed %d: Disconnected cave cell %s", seed, loc)
+				}
+			}
+		}
+	}
+}
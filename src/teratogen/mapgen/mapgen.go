@@ -23,6 +23,7 @@ import (
 	"errors"
 	"image"
 	"math/rand"
+	"strings"
 	"teratogen/entity"
 	"teratogen/mapgen/chunk"
 	"teratogen/space"
@@ -31,30 +32,112 @@ import (
 
 type Mapgen struct {
 	world   *world.World
+	rng     *rand.Rand
 	chart   space.Chart
 	openSet map[space.Location]bool
+	// Chart area that has been written to on the current map.
+	bounds image.Rectangle
 }
 
 func New(w *world.World) *Mapgen {
-	return &Mapgen{world: w}
+	return &Mapgen{world: w, rng: rand.New(rand.NewSource(rand.Int63()))}
 }
 
-func (m *Mapgen) TestMap(start space.Location, depth int) (entry, exit space.Location) {
-	legend := map[rune]placeFn{
-		'#': terrainPlacer(world.WallTerrain),
-		'.': terrainPlacer(world.FloorTerrain),
-		'|': terrainPlacer(world.DoorTerrain),
-		'b': terrainPlacer(world.BarrelTerrain),
-		'c': terrainPlacer(world.ChairTerrain),
-		't': terrainPlacer(world.CounterTerrain),
-		'p': terrainPlacer(world.PlantTerrain),
-
-		// Downstairs cell gets special handling at mapgen, failing that, it gets
-		// turned into floor.
-		'>': terrainPlacer(world.FloorTerrain),
-		'<': terrainPlacer(world.StairTerrain),
+// How many times generating a floor is retried before giving up and using
+// the fallback level.
+const maxFloorTries = 32
+
+// Floor generates the map for a new floor at the given depth, choosing a
+// suitable generator for it. Levels that fail validation are cleared and
+// generated again with a new seed.
+func (m *Mapgen) Floor(start space.Location, depth int) (entry, exit space.Location) {
+	for i := 0; i < maxFloorTries; i++ {
+		m.rng = rand.New(rand.NewSource(rand.Int63()))
+		var err error
+		if entry, exit, err = m.tryFloor(start, depth); err == nil {
+			return
+		}
+		m.clearZone(start.Zone)
 	}
+	return m.FallbackMap(start)
+}
+
+// AllowsBigMobs returns whether big mobs can be spawned on floors of the
+// given depth. Only the cave floors are roomy enough for them to get from the
+// entry to the exit. The chunk maps have doorways a single cell wide between
+// the rooms, and big mobs can't get through those.
+func AllowsBigMobs(depth int) bool {
+	return depth%3 == 2
+}
 
+func (m *Mapgen) tryFloor(start space.Location, depth int) (entry, exit space.Location, err error) {
+	if AllowsBigMobs(depth) {
+		entry, exit, err = m.CaveMap(start, depth)
+	} else {
+		entry, exit, err = m.TestMap(start, depth)
+	}
+	if err != nil {
+		return
+	}
+	if err = m.portalFeatures(entry, exit); err != nil {
+		return
+	}
+	err = m.validate(entry, exit, AllowsBigMobs(depth))
+	return
+}
+
+// clearZone removes the terrain and the portals of a discarded level.
+func (m *Mapgen) clearZone(zone uint16) {
+	inZone := func(loc space.Location) bool { return loc.Zone == zone }
+	m.world.RemoveTerrain(inZone)
+	m.world.Manifold.RemovePortals(inZone)
+}
+
+var chunkLegend = map[rune]placeFn{
+	'#': terrainPlacer(world.WallTerrain),
+	'.': terrainPlacer(world.FloorTerrain),
+	'|': terrainPlacer(world.DoorTerrain),
+	'b': terrainPlacer(world.BarrelTerrain),
+	'c': terrainPlacer(world.ChairTerrain),
+	't': terrainPlacer(world.CounterTerrain),
+	'p': terrainPlacer(world.PlantTerrain),
+
+	// Downstairs cell gets special handling at mapgen, failing that, it gets
+	// turned into floor.
+	'>': terrainPlacer(world.FloorTerrain),
+	'<': terrainPlacer(world.StairTerrain),
+}
+
+// fallbackLevel is a minimal level that is known to pass validation.
+const fallbackLevel = `
+###########
+##......###
+#<.......>#
+###......##
+###########
+`
+
+// FallbackMap writes a fixed minimal level. It is used when the random
+// generators keep failing to produce a valid level.
+func (m *Mapgen) FallbackMap(start space.Location) (entry, exit space.Location) {
+	m.init(start)
+	for y, line := range strings.Split(strings.TrimSpace(fallbackLevel), "\n") {
+		for x, ch := range line {
+			pt := image.Pt(x, y)
+			chunkLegend[ch](m.world, m.chart.At(pt))
+			m.touch(pt)
+			switch ch {
+			case '<':
+				entry = m.chart.At(pt)
+			case '>':
+				exit = m.chart.At(pt)
+			}
+		}
+	}
+	return
+}
+
+func (m *Mapgen) TestMap(start space.Location, depth int) (entry, exit space.Location, err error) {
 	entrance := parseChunks(`
 ####|####
 #.......#
@@ -122,43 +205,49 @@ func (m *Mapgen) TestMap(start space.Location, depth int) (entry, exit space.Loc
 `)
 	chunks = chunk.GenerateVariants(chunks)
 
-	m.chart = simpleChart(start)
+	m.init(start)
 
-	cg := chunk.New(entrance[rand.Intn(len(entrance))], '#')
+	cg := chunk.New(entrance[m.rng.Intn(len(entrance))], '#')
 	cg.SetGrid(image.Pt(4, 4))
 
 	nRooms := 5 + depth/2
 	for i := 0; i < nRooms; i++ {
 		pegs := cg.OpenPegs()
 		if len(pegs) == 0 {
-			panic("Map ran out of expansion room")
+			err = errors.New("Map ran out of expansion room")
+			return
 		}
 
-		peg := pegs[rand.Intn(len(pegs))]
+		peg := pegs[m.rng.Intn(len(pegs))]
 
+		isExit := i == nRooms-1
 		var placeChunks []chunk.OffsetChunk
-		if i == nRooms-1 {
+		if isExit {
 			placeChunks = cg.FittingChunks(peg, exits)
-			// XXX: Hardcoded exit point. All exists are assumed to have the
-			// exit tile at the same offset.
-			exit = m.chart.At(placeChunks[0].Offset().Add(image.Pt(4, 4)))
 		} else {
 			placeChunks = cg.FittingChunks(peg, chunks)
 		}
 		if len(placeChunks) == 0 {
-			panic("Can't expand map")
+			err = errors.New("Can't expand map")
+			return
 		}
 
-		chunk := placeChunks[rand.Intn(len(placeChunks))]
+		chunk := placeChunks[m.rng.Intn(len(placeChunks))]
+		if isExit {
+			// XXX: Hardcoded exit point. All exits are assumed to have the
+			// exit tile at the same offset.
+			exit = m.chart.At(chunk.Offset().Add(image.Pt(4, 4)))
+		}
 
 		cg.AddChunk(chunk)
 	}
 	cg.CloseAllPegs()
 
 	for pt, cell := range cg.Map() {
-		fn, ok := legend[rune(cell)]
+		fn, ok := chunkLegend[rune(cell)]
 		if ok {
 			fn(m.world, m.chart.At(pt))
+			m.touch(pt)
 		} else {
 			panic("Unknown terrain type " + string(cell))
 		}
@@ -182,6 +271,12 @@ func findExit(oc chunk.OffsetChunk) (exit image.Point, ok bool) {
 func (m *Mapgen) init(start space.Location) {
 	m.chart = simpleChart(start)
 	m.openSet = map[space.Location]bool{}
+	m.bounds = image.Rectangle{}
+}
+
+// touch marks a chart point as being part of the current map.
+func (m *Mapgen) touch(pt image.Point) {
+	m.bounds = m.bounds.Union(image.Rectangle{pt, pt.Add(image.Pt(1, 1))})
 }
 
 func (m *Mapgen) setOpen(loc space.Location, isOpen bool) {
@@ -194,7 +289,7 @@ func (m *Mapgen) setOpen(loc space.Location, isOpen bool) {
 
 func (m *Mapgen) randomLoc() (loc space.Location) {
 	// XXX: O(n) time.
-	n := rand.Intn(len(m.openSet))
+	n := m.rng.Intn(len(m.openSet))
 	for k, _ := range m.openSet {
 		loc = k
 		n--
@@ -205,7 +300,7 @@ func (m *Mapgen) randomLoc() (loc space.Location) {
 	return
 }
 
-func (m *Mapgen) spawn(obj entity.Entity, loc space.Location) error {
+func (m *Mapgen) spawn(obj entity.ID, loc space.Location) error {
 	if !m.world.Fits(obj, loc) {
 		return errors.New("Spawn won't fit")
 	}
@@ -213,8 +308,8 @@ func (m *Mapgen) spawn(obj entity.Entity, loc space.Location) error {
 	m.world.Place(obj, loc)
 
 	// Remove the points from the open set if the entity blocks movement.
-	if b, ok := obj.(entity.BlockMove); ok && b.BlocksMove() {
-		for _, footLoc := range m.world.Manifold.FootprintFor(obj, loc) {
+	if m.world.BlocksMove(obj) {
+		for _, footLoc := range m.world.Footprint(obj, loc) {
 			m.setOpen(footLoc, false)
 		}
 	}
@@ -227,6 +322,7 @@ func (m *Mapgen) terrain(pt image.Point) world.TerrainData {
 
 func (m *Mapgen) setTerrain(pt image.Point, t world.Terrain) {
 	m.world.SetTerrain(m.chart.At(pt), t)
+	m.touch(pt)
 }
 
 func (m *Mapgen) checkSurroundings(loc space.Location, mustBeOpen, mustBeClosed []image.Point) bool {
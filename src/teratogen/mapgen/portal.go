@@ -0,0 +1,444 @@
+// portal.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+	"teratogen/fov"
+	"teratogen/mob"
+	"teratogen/space"
+	"teratogen/tile"
+	"teratogen/world"
+)
+
+// Pockets are map features that live in an otherwise unused part of the
+// level's zone and can only be reached through a portaled doorway on the
+// level's outer wall. They are described in a local frame where the doorway
+// is at the origin and the x axis points into the pocket.
+
+type pocket struct {
+	name  string
+	cells map[image.Point]world.Terrain
+	// Portals inside the pocket, from the first point to the second one.
+	links [][2]image.Point
+}
+
+// The local points around the doorway that are sewn to the main map. The
+// first two are on the room side, the other two are the walls next to the
+// doorway.
+var seamPoints = []image.Point{{-1, -1}, {-1, 0}, {0, -1}, {0, 1}}
+
+func (p *pocket) bounds() (result image.Rectangle) {
+	for pt, _ := range p.cells {
+		result = result.Union(image.Rectangle{pt, pt.Add(image.Pt(1, 1))})
+	}
+	return
+}
+
+// isHole returns whether the local point must be left without terrain
+// because it's a portal.
+func (p *pocket) isHole(pt image.Point) bool {
+	for _, s := range seamPoints {
+		if pt == s {
+			return true
+		}
+	}
+	for _, link := range p.links {
+		if pt == link[0] {
+			return true
+		}
+	}
+	return false
+}
+
+var pocketLegend = map[rune]world.Terrain{
+	'#': world.WallTerrain,
+	'.': world.FloorTerrain,
+	'|': world.DoorTerrain,
+	'b': world.BarrelTerrain,
+	'c': world.ChairTerrain,
+	't': world.CounterTerrain,
+	'p': world.PlantTerrain,
+}
+
+// parsePocket reads a pocket from an ASCII map. The doorway is marked with
+// '|' and the pocket extends to the right from it.
+func parsePocket(name, asciiMap string) *pocket {
+	result := &pocket{name: name, cells: map[image.Point]world.Terrain{}}
+	var door image.Point
+	lines := strings.Split(strings.Trim(asciiMap, "\n"), "\n")
+	for y, line := range lines {
+		if x := strings.IndexRune(line, '|'); x >= 0 {
+			door = image.Pt(x, y)
+		}
+	}
+	for y, line := range lines {
+		for x, ch := range line {
+			if t, ok := pocketLegend[ch]; ok {
+				result.cells[image.Pt(x, y).Sub(door)] = t
+			}
+		}
+	}
+	return result
+}
+
+var closetPocket = parsePocket("closet", `
+#####
+#...#
+|...#
+#..b#
+#####
+`)
+
+// The hall is only placed behind doorways that have other parts of the
+// level close behind them, so it ends up bigger on the inside.
+var hallPocket = parsePocket("hall", `
+###########
+#.........#
+#..p...p..#
+#.........#
+#....t....#
+|...ctc...#
+#....t....#
+#.........#
+#..p...p..#
+#.........#
+###########
+`)
+
+// loopPocket makes a corridor running past the doorway that loops back on
+// itself at both ends.
+func loopPocket(length int) *pocket {
+	result := &pocket{name: "loop", cells: map[image.Point]world.Terrain{}}
+	for y := -1; y <= length; y++ {
+		result.cells[image.Pt(0, y)] = world.WallTerrain
+		result.cells[image.Pt(1, y)] = world.FloorTerrain
+		result.cells[image.Pt(2, y)] = world.WallTerrain
+	}
+	result.cells[image.Pt(0, 0)] = world.DoorTerrain
+	result.links = [][2]image.Point{
+		{image.Pt(1, -1), image.Pt(1, length-1)},
+		{image.Pt(1, length), image.Pt(1, 0)}}
+	return result
+}
+
+// frame maps local feature points to chart points.
+type frame struct {
+	origin, fwd, side image.Point
+}
+
+func newFrame(origin, fwd image.Point) frame {
+	// Swapping the axes maps the hex directions to hex directions, so the
+	// frame preserves hex adjacency.
+	return frame{origin, fwd, image.Pt(fwd.Y, fwd.X)}
+}
+
+func (f frame) At(pt image.Point) image.Point {
+	return f.origin.Add(f.fwd.Mul(pt.X)).Add(f.side.Mul(pt.Y))
+}
+
+var axisDirs = []image.Point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+const (
+	maxPortalFeatures = 2
+	// Pockets are placed at most this far from the chart origin.
+	pocketRange = 100
+	// Doorways are kept this far from the level entry and exit.
+	minStairDist = 3
+)
+
+// portalFeatures adds a few random pocket features to the current map. A
+// pocket that fails to fit in cleanly makes the whole map fail.
+func (m *Mapgen) portalFeatures(entry, exit space.Location) error {
+	n := m.rng.Intn(maxPortalFeatures + 1)
+	for i := 0; i < n; i++ {
+		var p *pocket
+		switch m.rng.Intn(3) {
+		case 0:
+			p = closetPocket
+		case 1:
+			p = hallPocket
+		case 2:
+			p = loopPocket(4 + m.rng.Intn(5))
+		}
+
+		sites := m.doorwaySites(p, entry, exit)
+		if len(sites) == 0 {
+			continue
+		}
+		site := sites[m.rng.Intn(len(sites))]
+		if pocketFrame, ok := m.pocketSpace(p, site.fwd); ok {
+			if err := m.addPocket(p, site, pocketFrame); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// doorwaySites returns the points on the level's outer walls where a pocket
+// doorway can go.
+func (m *Mapgen) doorwaySites(p *pocket, entry, exit space.Location) (result []frame) {
+	for y := m.bounds.Min.Y; y < m.bounds.Max.Y; y++ {
+		for x := m.bounds.Min.X; x < m.bounds.Max.X; x++ {
+			pt := image.Pt(x, y)
+			loc := m.chart.At(pt)
+			if m.nearLoc(loc, entry) || m.nearLoc(loc, exit) {
+				continue
+			}
+			for _, fwd := range axisDirs {
+				f := newFrame(pt, fwd)
+				if m.isDoorwaySite(f) && (p != hallPocket || m.isCramped(f, p)) {
+					result = append(result, f)
+				}
+			}
+		}
+	}
+	return
+}
+
+func (m *Mapgen) nearLoc(loc, target space.Location) bool {
+	return loc.Zone == target.Zone &&
+		tile.HexDist(image.Pt(int(loc.X), int(loc.Y)), image.Pt(int(target.X), int(target.Y))) < minStairDist
+}
+
+func (m *Mapgen) isDoorwaySite(f frame) bool {
+	kind := func(pt image.Point) (world.TerrainKind, bool) {
+		loc := m.chart.At(f.At(pt))
+		return m.world.Terrain(loc).Kind, m.world.Contains(loc)
+	}
+
+	if k, ok := kind(image.Pt(0, 0)); !ok || k != world.WallKind {
+		return false
+	}
+	if k, ok := kind(image.Pt(-1, 0)); !ok || k != world.OpenKind {
+		return false
+	}
+	if _, ok := kind(image.Pt(-1, -1)); !ok {
+		return false
+	}
+	for _, pt := range []image.Point{{0, -1}, {0, 1}} {
+		if k, ok := kind(pt); !ok || k != world.WallKind {
+			return false
+		}
+	}
+	for _, pt := range []image.Point{{1, 0}, {1, 1}} {
+		if _, ok := kind(pt); ok {
+			return false
+		}
+	}
+
+	// Don't mess with existing portals.
+	if m.world.Manifold.Portal(m.chart.At(f.origin)) != space.NullPortal() {
+		return false
+	}
+	for _, vec := range tile.HexDirs {
+		if m.world.Manifold.Portal(m.chart.At(f.origin.Add(vec))) != space.NullPortal() {
+			return false
+		}
+	}
+	return true
+}
+
+// isCramped returns whether there's existing terrain in the space that the
+// pocket would occupy if it were attached directly behind the doorway.
+func (m *Mapgen) isCramped(f frame, p *pocket) bool {
+	for pt, _ := range p.cells {
+		if pt.X > 1 && m.world.Contains(m.chart.At(f.At(pt))) {
+			return true
+		}
+	}
+	return false
+}
+
+// pocketSpace looks for an unused area in the current zone that fits the
+// pocket and returns a frame for placing the pocket there.
+func (m *Mapgen) pocketSpace(p *pocket, fwd image.Point) (result frame, ok bool) {
+	const maxTries = 256
+
+	bounds := p.bounds().Inset(-1)
+	for i := 0; i < maxTries; i++ {
+		origin := image.Pt(
+			m.rng.Intn(2*pocketRange+1)-pocketRange,
+			m.rng.Intn(2*pocketRange+1)-pocketRange)
+		result = newFrame(origin, fwd)
+		if m.isFree(result, bounds) {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+func (m *Mapgen) isFree(f frame, bounds image.Rectangle) bool {
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pt := f.At(image.Pt(x, y))
+			if pt.X < -pocketRange || pt.X > pocketRange || pt.Y < -pocketRange || pt.Y > pocketRange {
+				return false
+			}
+			loc := m.chart.At(pt)
+			if m.world.Contains(loc) || m.world.Manifold.Portal(loc) != space.NullPortal() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addPocket writes the pocket into the world, sews it to the doorway site
+// and validates the result. Invalid pockets are removed again.
+func (m *Mapgen) addPocket(p *pocket, site, pocketFrame frame) error {
+	mf := m.world.Manifold
+
+	for pt, t := range p.cells {
+		if !p.isHole(pt) {
+			m.setTerrain(pocketFrame.At(pt), t)
+		}
+	}
+	for _, link := range p.links {
+		mf.SetPortalTo(m.chart.At(pocketFrame.At(link[0])), m.chart.At(pocketFrame.At(link[1])))
+	}
+
+	// The doorway leads into the pocket and the cells around the pocket's
+	// doorway lead back out.
+	m.setTerrain(site.origin, world.DoorTerrain)
+	mf.SetPortalTo(m.chart.At(site.origin), m.chart.At(pocketFrame.origin))
+	for _, pt := range seamPoints {
+		mf.SetPortalTo(m.chart.At(pocketFrame.At(pt)), m.chart.At(site.At(pt)))
+	}
+
+	locs := []space.Location{m.chart.At(site.At(image.Pt(-1, 0)))}
+	for pt, _ := range p.cells {
+		locs = append(locs, m.chart.At(pocketFrame.At(pt)))
+	}
+	err := m.checkSeams(locs)
+	if err != nil {
+		m.removePocket(p, site, pocketFrame)
+		err = errors.New(fmt.Sprintf("Pocket %s at %s: %s", p.name, site.origin, err))
+	}
+	return err
+}
+
+func (m *Mapgen) removePocket(p *pocket, site, pocketFrame frame) {
+	mf := m.world.Manifold
+
+	m.setTerrain(site.origin, world.WallTerrain)
+	mf.SetPortal(m.chart.At(site.origin), space.NullPortal())
+	for _, pt := range seamPoints {
+		mf.SetPortal(m.chart.At(pocketFrame.At(pt)), space.NullPortal())
+	}
+	for _, link := range p.links {
+		mf.SetPortal(m.chart.At(pocketFrame.At(link[0])), space.NullPortal())
+	}
+	pocketLocs := map[space.Location]bool{}
+	for pt, _ := range p.cells {
+		pocketLocs[m.chart.At(pocketFrame.At(pt))] = true
+	}
+	m.world.RemoveTerrain(func(loc space.Location) bool { return pocketLocs[loc] })
+}
+
+// checkSeams verifies that the manifold around the given locations is
+// consistent: Moving back after a step returns to the starting location,
+// the field of view shows the same neighbors for open cells that movement
+// leads to, and multi-cell footprints neither overlap themselves nor
+// disagree with the field of view.
+func (m *Mapgen) checkSeams(locs []space.Location) error {
+	for _, loc := range locs {
+		if !m.isOpen(loc) {
+			continue
+		}
+		if err := m.checkMoves(loc); err != nil {
+			return err
+		}
+		if err := m.checkFov(loc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mapgen) isOpen(loc space.Location) bool {
+	return m.world.Contains(loc) && !m.world.Terrain(loc).BlocksMove()
+}
+
+func (m *Mapgen) checkMoves(loc space.Location) error {
+	for _, vec := range tile.HexDirs {
+		next := m.world.Manifold.Offset(loc, vec)
+		if !m.isOpen(next) {
+			continue
+		}
+		if back := m.world.Manifold.Offset(next, vec.Mul(-1)); back != loc {
+			return errors.New(fmt.Sprintf(
+				"Moving %s from %s and back ends up at %s", vec, loc, back))
+		}
+	}
+	return nil
+}
+
+func (m *Mapgen) checkFov(origin space.Location) error {
+	const radius = 8
+
+	chart := space.MapChart{}
+	fov.New(
+		func(loc space.Location) bool { return m.world.Terrain(loc).BlocksSight() },
+		func(pt image.Point, loc space.Location) { chart[pt] = loc },
+		m.world.Manifold).Run(origin, radius)
+
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			pt := image.Pt(x, y)
+			loc := chart.At(pt)
+			if !m.isOpen(loc) {
+				continue
+			}
+			for _, vec := range tile.HexDirs {
+				seen := chart.At(pt.Add(vec))
+				if !m.isOpen(seen) {
+					continue
+				}
+				if moved := m.world.Manifold.Offset(loc, vec); moved != seen {
+					return errors.New(fmt.Sprintf(
+						"Fov from %s shows %s next to %s, moving there leads to %s",
+						origin, seen, loc, moved))
+				}
+			}
+		}
+	}
+
+	footprint := m.world.Manifold.MakeFootprint(mob.BigFootprint, origin)
+	covered := map[space.Location]bool{}
+	for pt, loc := range footprint {
+		if !m.isOpen(loc) {
+			// Doesn't fit here, no need to check further.
+			return nil
+		}
+		if covered[loc] {
+			return errors.New(fmt.Sprintf("Footprint at %s overlaps itself", origin))
+		}
+		covered[loc] = true
+		if seen := chart.At(pt); seen != loc {
+			return errors.New(fmt.Sprintf(
+				"Footprint at %s has %s at %s, fov shows %s", origin, loc, pt, seen))
+		}
+	}
+	return nil
+}
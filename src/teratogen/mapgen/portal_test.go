@@ -0,0 +1,120 @@
+// portal_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"image"
+	"math/rand"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+// testRoom digs a walled rectangular room with the interior at the given
+// bounds.
+func testRoom(m *Mapgen, bounds image.Rectangle) {
+	outer := bounds.Inset(-1)
+	for y := outer.Min.Y; y < outer.Max.Y; y++ {
+		for x := outer.Min.X; x < outer.Max.X; x++ {
+			pt := image.Pt(x, y)
+			if pt.In(bounds) {
+				m.setTerrain(pt, world.FloorTerrain)
+			} else {
+				m.setTerrain(pt, world.WallTerrain)
+			}
+		}
+	}
+}
+
+func TestPockets(t *testing.T) {
+	rand.Seed(1)
+	for _, p := range []*pocket{closetPocket, hallPocket, loopPocket(3), loopPocket(6)} {
+		for _, fwd := range axisDirs {
+			m := New(world.New())
+			m.init(space.Loc(0, 0, 1))
+			testRoom(m, image.Rect(0, 0, 5, 5))
+			if p == hallPocket {
+				// Put something behind the room to make the hall fit.
+				testRoom(m, image.Rect(8, 8, 9, 9))
+				testRoom(m, image.Rect(-4, -4, -3, -3))
+			}
+
+			var site frame
+			found := false
+			for _, f := range m.doorwaySites(p, space.Location{}, space.Location{}) {
+				if f.fwd == fwd {
+					site, found = f, true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("No %s site facing %s", p.name, fwd)
+				continue
+			}
+
+			pocketFrame, ok := m.pocketSpace(p, fwd)
+			if !ok {
+				t.Fatalf("No space for %s", p.name)
+			}
+			if err := m.addPocket(p, site, pocketFrame); err != nil {
+				t.Error(err)
+				continue
+			}
+
+			// Walk through the doorway and back.
+			mf := m.world.Manifold
+			room := m.chart.At(site.At(image.Pt(-1, 0)))
+			inside := mf.Offset(room, fwd)
+			if inside != m.chart.At(pocketFrame.origin) {
+				t.Errorf("%s doorway leads to %s", p.name, inside)
+			}
+			if back := mf.Offset(inside, fwd.Mul(-1)); back != room {
+				t.Errorf("Leaving %s leads to %s", p.name, back)
+			}
+		}
+	}
+}
+
+func TestLoopPocket(t *testing.T) {
+	rand.Seed(1)
+	m := New(world.New())
+	m.init(space.Loc(0, 0, 1))
+	testRoom(m, image.Rect(0, 0, 5, 5))
+
+	const length = 5
+	p := loopPocket(length)
+	site := m.doorwaySites(p, space.Location{}, space.Location{})[0]
+	pocketFrame, _ := m.pocketSpace(p, site.fwd)
+	if err := m.addPocket(p, site, pocketFrame); err != nil {
+		t.Fatal(err)
+	}
+
+	// Walking along the corridor should come back to the start.
+	mf := m.world.Manifold
+	start := m.chart.At(pocketFrame.At(image.Pt(1, 0)))
+	loc := start
+	for i := 0; i < length; i++ {
+		loc = mf.Offset(loc, pocketFrame.side)
+		if m.world.Terrain(loc).BlocksMove() {
+			t.Fatalf("Corridor blocked at %s", loc)
+		}
+	}
+	if loc != start {
+		t.Errorf("Corridor doesn't loop, ended up at %s", loc)
+	}
+}
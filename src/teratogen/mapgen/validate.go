@@ -0,0 +1,134 @@
+// validate.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"teratogen/mob"
+	"teratogen/space"
+	"teratogen/tile"
+	"teratogen/world"
+)
+
+// validate checks that the current map is playable. The exit must be
+// reachable from the entry, there must be no open areas that can't be
+// reached from the entry and no doors that don't lead anywhere. If bigMobs
+// is set, mobs with the big footprint must also be able to get from next to
+// the entry to next to the exit.
+func (m *Mapgen) validate(entry, exit space.Location, bigMobs bool) error {
+	_, reached := m.floodFill(entry, m.isOpen)
+	if !reached[exit] {
+		return errors.New(fmt.Sprintf("Exit %s not reachable from entry %s", exit, entry))
+	}
+
+	for y := m.bounds.Min.Y; y < m.bounds.Max.Y; y++ {
+		for x := m.bounds.Min.X; x < m.bounds.Max.X; x++ {
+			loc := m.chart.At(image.Pt(x, y))
+			if m.world.Manifold.Portal(loc) != space.NullPortal() {
+				// Portal cells are never stood on, moving into them leads
+				// somewhere else.
+				continue
+			}
+			if m.isOpen(loc) && !reached[loc] {
+				return errors.New(fmt.Sprintf("Unreachable area at %s", loc))
+			}
+			if m.isSealedDoor(loc) {
+				return errors.New(fmt.Sprintf("Sealed door at %s", loc))
+			}
+		}
+	}
+
+	if bigMobs {
+		return m.validateBig(entry, exit)
+	}
+	return nil
+}
+
+// validateBig checks that big mobs can move between the places nearest to
+// the entry and the exit where they fit. The stairs themselves are in
+// enclosures too narrow for them.
+func (m *Mapgen) validateBig(entry, exit space.Location) error {
+	start, ok := m.nearestFit(entry)
+	if !ok {
+		return errors.New("No room for big mobs")
+	}
+	goal, _ := m.nearestFit(exit)
+
+	_, reached := m.floodFill(start, m.fitsBig)
+	if !reached[goal] {
+		return errors.New(fmt.Sprintf(
+			"Big mobs can't get from %s to %s", start, goal))
+	}
+	return nil
+}
+
+// floodFill returns the locations connected to start through locations for
+// which passable is true, both in the order they were reached and as a set.
+// Start itself is always included.
+func (m *Mapgen) floodFill(start space.Location, passable func(space.Location) bool) (order []space.Location, reached map[space.Location]bool) {
+	order = []space.Location{start}
+	reached = map[space.Location]bool{start: true}
+	for i := 0; i < len(order); i++ {
+		for _, vec := range tile.HexDirs {
+			loc := m.world.Manifold.Offset(order[i], vec)
+			if !reached[loc] && passable(loc) {
+				reached[loc] = true
+				order = append(order, loc)
+			}
+		}
+	}
+	return
+}
+
+// nearestFit returns the closest open location to the given one where a big
+// mob fits.
+func (m *Mapgen) nearestFit(loc space.Location) (result space.Location, ok bool) {
+	order, _ := m.floodFill(loc, m.isOpen)
+	for _, result = range order {
+		if m.fitsBig(result) {
+			return result, true
+		}
+	}
+	return
+}
+
+func (m *Mapgen) fitsBig(loc space.Location) bool {
+	for _, footLoc := range m.world.Manifold.MakeFootprint(mob.BigFootprint, loc) {
+		if !m.isOpen(footLoc) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSealedDoor returns whether the location is a door without open space on
+// two opposite sides.
+func (m *Mapgen) isSealedDoor(loc space.Location) bool {
+	if !m.world.Contains(loc) || m.world.Terrain(loc).Kind != world.DoorKind {
+		return false
+	}
+	for _, vec := range tile.HexDirs {
+		if m.isOpen(m.world.Manifold.Offset(loc, vec)) &&
+			m.isOpen(m.world.Manifold.Offset(loc, vec.Mul(-1))) {
+			return false
+		}
+	}
+	return true
+}
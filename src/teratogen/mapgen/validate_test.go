@@ -0,0 +1,107 @@
+// validate_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mapgen
+
+import (
+	"image"
+	"math/rand"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+func TestFloorSeeds(t *testing.T) {
+	nSeeds := int64(200)
+	if testing.Short() {
+		nSeeds = 10
+	}
+	for seed := int64(1); seed <= nSeeds; seed++ {
+		for depth := 0; depth < 3; depth++ {
+			// Use the generators directly, Floor would hide the failures
+			// behind the fallback level.
+			rand.Seed(seed)
+			m := New(world.New())
+			entry, exit, err := m.tryFloor(space.Loc(0, 0, 1), depth)
+			if err != nil {
+				t.Errorf("Seed %d, depth %d: %s", seed, depth, err)
+				continue
+			}
+
+			// Big mobs must not be spawned on the floors they can't get
+			// around on.
+			if !AllowsBigMobs(depth) && m.validateBig(entry, exit) == nil {
+				t.Errorf("Seed %d, depth %d: Big mobs can get around on a floor that doesn't allow them", seed, depth)
+			}
+		}
+	}
+}
+
+func TestFallbackMap(t *testing.T) {
+	m := New(world.New())
+	entry, exit := m.FallbackMap(space.Loc(0, 0, 1))
+	if err := m.validate(entry, exit, true); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidateFailures(t *testing.T) {
+	setup := func() (m *Mapgen, entry, exit space.Location) {
+		m = New(world.New())
+		m.init(space.Loc(0, 0, 1))
+		testRoom(m, image.Rect(0, 0, 5, 5))
+		return m, m.chart.At(image.Pt(0, 0)), m.chart.At(image.Pt(4, 4))
+	}
+
+	m, entry, exit := setup()
+	if err := m.validate(entry, exit, true); err != nil {
+		t.Errorf("Valid room failed validation: %s", err)
+	}
+
+	m, entry, exit = setup()
+	testRoom(m, image.Rect(10, 0, 12, 2))
+	if m.validate(entry, exit, false) == nil {
+		t.Error("Unreachable room not detected")
+	}
+
+	m, entry, exit = setup()
+	m.setTerrain(image.Pt(5, 2), world.DoorTerrain)
+	if m.validate(entry, exit, false) == nil {
+		t.Error("Sealed door not detected")
+	}
+
+	m, entry, exit = setup()
+	for y := 0; y < 5; y++ {
+		if y != 2 {
+			m.setTerrain(image.Pt(2, y), world.WallTerrain)
+		}
+	}
+	if err := m.validate(entry, exit, false); err != nil {
+		t.Errorf("Narrow passage failed small mob validation: %s", err)
+	}
+	if m.validate(entry, exit, true) == nil {
+		t.Error("Narrow passage not detected for big mobs")
+	}
+
+	m, entry, exit = setup()
+	for y := 0; y < 5; y++ {
+		m.setTerrain(image.Pt(2, y), world.WallTerrain)
+	}
+	if m.validate(entry, exit, false) == nil {
+		t.Error("Unreachable exit not detected")
+	}
+}
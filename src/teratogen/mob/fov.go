@@ -19,6 +19,7 @@ package mob
 
 import (
 	"image"
+	"teratogen/ser"
 	"teratogen/space"
 )
 
@@ -26,6 +27,17 @@ import (
 type Fov struct {
 	relativePos image.Point
 	chart       map[image.Point]space.Location
+	// The zone the chart is for.
+	zone uint16
+	// Stored charts for the other zones that have been visited.
+	memory map[uint16]fovMemory
+	// Counts the changes to the chart.
+	revision int
+}
+
+type fovMemory struct {
+	relativePos image.Point
+	chart       map[image.Point]space.Location
 }
 
 func NewFov() (result *Fov) {
@@ -36,6 +48,14 @@ func NewFov() (result *Fov) {
 
 func (f *Fov) Init() {
 	f.chart = make(map[image.Point]space.Location)
+	f.memory = make(map[uint16]fovMemory)
+}
+
+// Serialize saves the chart of the current zone along with the stored
+// charts of the other zones.
+func (f *Fov) Serialize(a ser.Archive) error {
+	a.Visit(&f.relativePos, &f.chart, &f.zone, &f.memory, &f.revision)
+	return nil
 }
 
 // Use a separate type for the chart since chart's main method name "At" is
@@ -55,13 +75,47 @@ func (f *Fov) FovChart() space.Chart {
 }
 
 func (f *Fov) MarkFov(pt image.Point, loc space.Location) {
-	f.chart[pt.Add(f.relativePos)] = loc
+	pt = pt.Add(f.relativePos)
+	if old, ok := f.chart[pt]; !ok || old != loc {
+		f.chart[pt] = loc
+		f.revision++
+	}
 }
 
-func (f *Fov) MoveFovOrigin(vec image.Point) {
-	f.relativePos = f.relativePos.Add(vec)
+// FovRevision returns a number that changes whenever the chart changes.
+func (f *Fov) FovRevision() int {
+	return f.revision
 }
 
-func (f *Fov) ClearFov() {
-	f.Init()
+// MoveFovOrigin moves the origin by vec into the given zone. Each zone has
+// its own chart, so that the maps of different floors don't get drawn on
+// top of each other. When the zone changes, the chart of the old zone is
+// stored and the chart the new zone had when it was last left is brought
+// back.
+func (f *Fov) MoveFovOrigin(vec image.Point, zone uint16) {
+	f.relativePos = f.relativePos.Add(vec)
+	if vec != image.ZP {
+		f.revision++
+	}
+	if f.zone == 0 {
+		// Chart hasn't been bound to a zone yet.
+		f.zone = zone
+	}
+	if zone == f.zone {
+		return
+	}
+
+	f.memory[f.zone] = fovMemory{f.relativePos, f.chart}
+	if mem, ok := f.memory[zone]; ok {
+		// The origin was moved by the step out of the zone when it was
+		// stored, moving it by the step back in puts it where we are now.
+		f.relativePos = mem.relativePos.Add(vec)
+		f.chart = mem.chart
+		delete(f.memory, zone)
+	} else {
+		f.relativePos = image.Pt(0, 0)
+		f.chart = make(map[image.Point]space.Location)
+	}
+	f.zone = zone
+	f.revision++
 }
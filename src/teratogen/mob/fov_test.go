@@ -0,0 +1,111 @@
+// fov_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mob
+
+import (
+	"bytes"
+	"image"
+	"teratogen/ser"
+	"teratogen/space"
+	"testing"
+)
+
+func TestFovZoneMemory(t *testing.T) {
+	f := NewFov()
+	chart := f.FovChart()
+
+	upper := space.Loc(3, 4, 1)
+	f.MoveFovOrigin(image.Pt(0, 0), 1)
+	f.MarkFov(image.Pt(0, 0), upper)
+	f.MarkFov(image.Pt(-1, 0), space.Loc(2, 4, 1))
+
+	// Step down to the next floor.
+	f.MoveFovOrigin(image.Pt(1, 0), 2)
+	if loc := chart.At(image.Pt(-1, 0)); loc != (space.Location{}) {
+		t.Errorf("Upper floor visible on lower floor at %s", loc)
+	}
+	lower := space.Loc(10, 10, 2)
+	f.MarkFov(image.Pt(0, 0), lower)
+	f.MoveFovOrigin(image.Pt(1, 0), 2)
+
+	// Go back to the stairs and up.
+	f.MoveFovOrigin(image.Pt(-1, 0), 2)
+	f.MoveFovOrigin(image.Pt(-1, 0), 1)
+	if loc := chart.At(image.Pt(0, 0)); loc != upper {
+		t.Errorf("Returned to upper floor at %s, expected %s", loc, upper)
+	}
+	if loc := chart.At(image.Pt(-1, 0)); loc != space.Loc(2, 4, 1) {
+		t.Errorf("Upper floor memory lost, got %s", loc)
+	}
+
+	// And back down again.
+	f.MoveFovOrigin(image.Pt(1, 0), 2)
+	if loc := chart.At(image.Pt(0, 0)); loc != lower {
+		t.Errorf("Returned to lower floor at %s, expected %s", loc, lower)
+	}
+}
+
+func TestFovRevision(t *testing.T) {
+	f := NewFov()
+	f.MarkFov(image.Pt(0, 0), space.Loc(1, 1, 1))
+	rev := f.FovRevision()
+
+	f.MarkFov(image.Pt(0, 0), space.Loc(1, 1, 1))
+	f.MoveFovOrigin(image.Pt(0, 0), 1)
+	if f.FovRevision() != rev {
+		t.Errorf("Revision changed without a change in the chart")
+	}
+
+	f.MoveFovOrigin(image.Pt(1, 0), 1)
+	if f.FovRevision() == rev {
+		t.Errorf("Revision didn't change when the origin moved")
+	}
+}
+
+func TestFovSer(t *testing.T) {
+	f := NewFov()
+	upper := space.Loc(3, 4, 1)
+	f.MoveFovOrigin(image.Pt(0, 0), 1)
+	f.MarkFov(image.Pt(0, 0), upper)
+	f.MoveFovOrigin(image.Pt(1, 0), 2)
+	lower := space.Loc(10, 10, 2)
+	f.MarkFov(image.Pt(0, 0), lower)
+
+	out := bytes.NewBuffer(nil)
+	if err := ser.Save(f, out); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := ser.Load(bytes.NewBuffer(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2 := obj.(*Fov)
+
+	if loc := f2.FovChart().At(image.Pt(0, 0)); loc != lower {
+		t.Errorf("Loaded chart at %s, expected %s", loc, lower)
+	}
+	if f2.FovRevision() != f.FovRevision() {
+		t.Errorf("Revision not restored")
+	}
+
+	// The memory of the upper floor is restored too.
+	f2.MoveFovOrigin(image.Pt(-1, 0), 1)
+	if loc := f2.FovChart().At(image.Pt(0, 0)); loc != upper {
+		t.Errorf("Returned to loaded upper floor at %s, expected %s", loc, upper)
+	}
+}
@@ -15,109 +15,77 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-// Package mob defines the types for the creatures in Teratogen.
+// Package mob defines the creatures in Teratogen as sets of entity
+// components.
 package mob
 
 import (
 	"image"
-	"teratogen/app"
-	"teratogen/display/util"
-	"teratogen/gfx"
+	"teratogen/entity"
 	"teratogen/num"
+	"teratogen/ser"
 	"teratogen/space"
 	"teratogen/world"
-	"time"
-	"unsafe"
 )
 
-type Mob struct {
-	icon      gfx.ImageSpec
-	loc       space.Location
-	world     *world.World
-	placed    bool
-	health    int
-	maxHealth int
-	shield    int
-	isBig     bool
-}
-
-type PC struct {
-	Mob
-	Fov
-}
-
-func NewPC(w *world.World, spec Spec) (result *PC) {
-	result = new(PC)
-	result.Mob.Init(w, spec)
-	result.Fov.Init()
-	return
+func init() {
+	ser.Register((*Fov)(nil))
+	ser.RegisterValue("Stats", &Stats{})
 }
 
 type Spec struct {
-	Icon      gfx.ImageSpec
+	// Name of the mob's sprite.
+	Icon      string
 	MaxHealth int
 	IsBig     bool
 }
 
-func New(w *world.World, spec Spec) (result *Mob) {
-	result = new(Mob)
-	result.Init(w, spec)
-	return
-}
-
-func (m *Mob) Init(w *world.World, spec Spec) {
-	m.world = w
-	m.icon = spec.Icon
-	m.health = spec.MaxHealth
-	m.maxHealth = spec.MaxHealth
-	m.isBig = spec.IsBig
-	w.AddActor(m)
-}
+// How far monsters notice enemies.
+const monsterSightRadius = 4
 
-func (m *Mob) Icon() gfx.ImageSpec {
-	return m.icon
+// New creates a new monster entity in the world.
+func New(w *world.World, spec Spec) entity.ID {
+	id := newMob(w, spec)
+	w.Entities.SetAI(id, &entity.AI{SightRadius: monsterSightRadius})
+	return id
 }
 
-// bob returns the motion offset for the idle animation of the mob's sprite.
-func (m *Mob) bob() image.Point {
-	t := time.Now().UnixNano()
-
-	// Give different mobs persistent random phases to their bob with noise
-	// generated from the mob's pointer value.
-	t += int64(1e9 * num.Noise(int(uintptr(unsafe.Pointer(m)))))
-
-	if t%500e6 < 250e6 {
-		return image.Pt(0, -1)
-	}
-
-	return image.Pt(0, 0)
+// NewPC creates a new player character entity in the world.
+func NewPC(w *world.World, spec Spec) entity.ID {
+	id := newMob(w, spec)
+	w.Entities.SetFov(id, NewFov())
+	w.Entities.SetInventory(id, &entity.Inventory{})
+	return id
 }
 
-func (m *Mob) Sprite(offset image.Point) gfx.Sprite {
-	// XXX: Hacky way to pass adjust parameter to make big mobs get drawn by
-	// their frontmost point. Layer is assumed to be a delta, the view system
-	// will adjust it into the correct Z level.
-	layer := 0
-	if m.isBig {
-		layer += 2 * util.ViewLayersPerZ
+func newMob(w *world.World, spec Spec) entity.ID {
+	id := w.Entities.New()
+	body := &entity.Body{BlocksMove: true}
+	if spec.IsBig {
+		body.Footprint = BigFootprint
 	}
-	return gfx.Sprite{
-		Layer:    layer,
-		Offset:   offset.Add(m.bob()),
-		Drawable: app.Cache().GetDrawable(m.icon)}
+	w.Entities.SetBody(id, body)
+	w.Entities.SetStats(id, &Stats{
+		health:    spec.MaxHealth,
+		maxHealth: spec.MaxHealth})
+	w.Entities.SetSprite(id, &entity.Sprite{Name: spec.Icon, IsBig: spec.IsBig, Phase: int(id)})
+	return id
 }
 
-func (m *Mob) BlocksMove() bool {
-	return true
+// Stats is the stats component of mobs.
+type Stats struct {
+	health    int
+	maxHealth int
+	shield    int
 }
 
-func (m *Mob) Health() int { return m.health }
+func (m *Stats) Health() int { return m.health }
 
-func (m *Mob) MaxHealth() int { return m.maxHealth }
+func (m *Stats) MaxHealth() int { return m.maxHealth }
 
-func (m *Mob) Shield() int { return m.shield }
+func (m *Stats) Shield() int { return m.shield }
 
-func (m *Mob) Damage(amount int) {
+func (m *Stats) Damage(amount int) {
 	amountLeft := amount
 
 	if m.shield > 0 {
@@ -133,15 +101,16 @@ func (m *Mob) Damage(amount int) {
 	m.health = num.MaxI(0, m.health-amountLeft)
 }
 
-func (m *Mob) AddHealth(amount int) {
+func (m *Stats) AddHealth(amount int) {
 	m.health = num.ClampI(0, m.maxHealth, m.health+amount)
 }
 
-func (m *Mob) AddShield(amount int) {
+func (m *Stats) AddShield(amount int) {
 	m.shield = num.MaxI(0, m.shield+amount)
 }
 
-var bigFootprint = space.ForceTemplate([]image.Point{
+// BigFootprint is the seven-cell hexagon shape of big mobs.
+var BigFootprint = space.ForceTemplate([]image.Point{
 	{0, 0},
 	{-1, -1},
 	{0, -1},
@@ -149,10 +118,3 @@ var bigFootprint = space.ForceTemplate([]image.Point{
 	{1, 1},
 	{0, 1},
 	{-1, 0}})
-
-func (m *Mob) Footprint() *space.FootprintTemplate {
-	if m.isBig {
-		return bigFootprint
-	}
-	return space.NewTemplate()
-}
@@ -0,0 +1,99 @@
+// mob_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package mob
+
+import (
+	"bytes"
+	"image"
+	"teratogen/ser"
+	"teratogen/space"
+	"teratogen/world"
+	"testing"
+)
+
+func TestWorldSer(t *testing.T) {
+	w := world.New()
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			w.SetTerrain(space.Loc(int16(x), int16(y), 1), world.FloorTerrain)
+		}
+	}
+	w.SetTerrain(space.Loc(0, 0, 1), world.WallTerrain)
+	w.Manifold.SetPortalTo(space.Loc(7, 0, 1), space.Loc(0, 0, 2))
+	w.Player = NewPC(w, Spec{Icon: "player", MaxHealth: 6})
+	w.Place(w.Player, space.Loc(1, 1, 1))
+	big := New(w, Spec{Icon: "bear", MaxHealth: 10, IsBig: true})
+	w.Place(big, space.Loc(4, 4, 1))
+	stats, _ := w.Entities.Stats(big)
+	stats.Damage(3)
+	fov, _ := w.Entities.Fov(w.Player)
+	fov.MarkFov(image.Pt(0, 0), space.Loc(1, 1, 1))
+
+	out := bytes.NewBuffer(nil)
+	if err := world.SaveContainer("test").Save(w, out); err != nil {
+		t.Fatal(err)
+	}
+	obj, _, err := world.SaveContainer("test").Load(bytes.NewBuffer(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2 := obj.(*world.World)
+
+	if w2.Terrain(space.Loc(0, 0, 1)).Kind != world.WallKind ||
+		w2.Terrain(space.Loc(1, 0, 1)).Kind != world.OpenKind {
+		t.Errorf("Terrain not restored")
+	}
+	if w2.Manifold.Portal(space.Loc(7, 0, 1)) != w.Manifold.Portal(space.Loc(7, 0, 1)) {
+		t.Errorf("Portal not restored")
+	}
+	if w2.Player != w.Player || w2.Spatial.Loc(w2.Player) != space.Loc(1, 1, 1) {
+		t.Errorf("Player not restored")
+	}
+	if stats, ok := w2.Entities.Stats(big); !ok || stats.Health() != 7 {
+		t.Errorf("Monster stats not restored")
+	}
+	if _, ok := w2.Entities.AI(big); !ok {
+		t.Errorf("Monster AI not restored")
+	}
+	if sprite, ok := w2.Entities.Sprite(big); !ok || sprite.Name != "bear" || !sprite.IsBig {
+		t.Errorf("Monster sprite not restored")
+	}
+	// The spatial index is rebuilt with the big footprint.
+	if !w2.IsBlocked(space.Loc(5, 5, 1)) || w2.IsBlocked(space.Loc(6, 6, 1)) {
+		t.Errorf("Big monster footprint not restored")
+	}
+	if fov, ok := w2.Entities.Fov(w2.Player); !ok ||
+		fov.FovChart().At(image.Pt(0, 0)) != space.Loc(1, 1, 1) {
+		t.Errorf("Player field of view not restored")
+	}
+
+	// Save dumps go through the text archive.
+	out.Reset()
+	if err := ser.SaveText(w2, out); err != nil {
+		t.Fatal(err)
+	}
+	obj, err = ser.LoadText(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w3 := obj.(*world.World)
+	if w3.Spatial.Loc(w3.Player) != space.Loc(1, 1, 1) ||
+		!w3.IsBlocked(space.Loc(5, 5, 1)) {
+		t.Errorf("Entity locations not restored from text")
+	}
+}
@@ -23,7 +23,6 @@ import (
 	"image"
 	"teratogen/entity"
 	"teratogen/fov"
-	"teratogen/mob"
 	"teratogen/space"
 	"teratogen/tile"
 	"teratogen/world"
@@ -37,48 +36,49 @@ func New(w *world.World) *Query {
 	return &Query{world: w}
 }
 
-func (q *Query) Footprint(obj entity.Entity, loc space.Location) space.Footprint {
-	return q.world.Manifold.FootprintFor(obj, loc)
+func (q *Query) Footprint(obj entity.ID, loc space.Location) space.Footprint {
+	return q.world.Footprint(obj, loc)
 }
 
-func (q *Query) EnemyOf(obj1, obj2 entity.Entity) bool {
+func (q *Query) EnemyOf(obj1, obj2 entity.ID) bool {
 	// TODO better
 	return obj1 != obj2
 }
 
-func (q *Query) Loc(obj entity.Entity) space.Location {
+func (q *Query) Loc(obj entity.ID) space.Location {
 	return q.world.Spatial.Loc(obj)
 }
 
 func (q *Query) IsGameOver() bool {
-	obj, _ := q.world.Player.(*mob.PC)
-	return !q.world.IsAlive(obj)
+	return !q.world.IsAlive(q.world.Player)
 }
 
 func (q *Query) VisibleEntities(loc space.Location, radius int) []space.OffsetEntity {
 	seen := map[space.OffsetEntity]bool{}
+	result := []space.OffsetEntity{}
 	fv := fov.New(
 		func(loc space.Location) bool { return q.world.Terrain(loc).BlocksSight() },
 		func(pt image.Point, loc space.Location) {
-			for _, oe := range q.world.Spatial.At(loc) {
-				seen[space.OffsetEntity{oe.Entity, pt.Add(oe.Offset)}] = true
-			}
+			q.world.Spatial.EachAt(loc, func(oe space.OffsetEntity) {
+				oe = space.OffsetEntity{Entity: oe.Entity, Offset: pt.Add(oe.Offset)}
+				if !seen[oe] {
+					seen[oe] = true
+					result = append(result, oe)
+				}
+			})
 		},
 		q.world.Manifold)
 	fv.Run(loc, radius)
-
-	result := []space.OffsetEntity{}
-	for oe, _ := range seen {
-		result = append(result, oe)
-	}
 	return result
 }
 
-func (q *Query) ClosestEnemy(obj entity.Entity) (result space.OffsetEntity, found bool) {
-	// TODO: Different sight radii
-	radius := 4
-	for _, oe := range q.VisibleEntities(q.Loc(obj), radius) {
-		if q.EnemyOf(obj, oe.Entity) {
+func (q *Query) ClosestEnemy(obj entity.ID) (result space.OffsetEntity, found bool) {
+	ai, ok := q.world.Entities.AI(obj)
+	if !ok {
+		return
+	}
+	for _, oe := range q.VisibleEntities(q.Loc(obj), ai.SightRadius) {
+		if q.EnemyOf(obj, oe.Entity.(entity.ID)) {
 			if !found || tile.HexLength(oe.Offset) < tile.HexLength(result.Offset) {
 				result = oe
 				found = true
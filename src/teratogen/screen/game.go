@@ -18,13 +18,17 @@
 package screen
 
 import (
+	"fmt"
 	"image"
+	"image/color"
+	"os"
 	"teratogen/action"
 	"teratogen/app"
 	"teratogen/display/anim"
 	"teratogen/display/fx"
 	"teratogen/display/hud"
 	"teratogen/display/view"
+	"teratogen/event"
 	"teratogen/factory"
 	"teratogen/gfx"
 	"teratogen/mapgen"
@@ -46,38 +50,80 @@ type game struct {
 	view   *view.View
 	anim   *anim.Anim
 	fx     *fx.Fx
+	mood   *fx.Mood
+	events *event.Bus
 	action *action.Action
 	mapgen *mapgen.Mapgen
 }
 
+// Enter continues the saved game if there is one and starts a new game
+// otherwise. The save is removed when it is loaded, so a game can't be
+// continued from the same save twice.
 func (gs *game) Enter() {
-	gs.world = world.New()
+	saved, err := app.LoadGame()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Loading the saved game failed:", err)
+	}
+	if err = app.RemoveSave(); err != nil {
+		fmt.Fprintln(os.Stderr, "Removing the saved game failed:", err)
+	}
+
+	gs.world = saved
+	if gs.world == nil {
+		gs.world = world.New()
+	}
 	gs.query = query.New(gs.world)
 	gs.hud = hud.New(gs.world)
 	gs.anim = anim.New()
 	gs.view = view.New(gs.world, gs.anim)
 	gs.mapgen = mapgen.New(gs.world)
 	gs.fx = fx.New(gs.anim, gs.world)
-	gs.action = action.New(gs.world, gs.mapgen, gs.query, gs.fx)
+	gs.mood = fx.NewMood(gs.world)
+	gs.events = event.NewBus()
+	gs.events.Subscribe(gs.fx.Handle)
+	gs.events.Subscribe(gs.mood.Handle)
+	gs.events.Subscribe(gs.hud.Handle)
+	gs.action = action.New(gs.world, gs.mapgen, gs.query, gs.events)
+	if saved != nil {
+		return
+	}
 
-	gs.world.SetPlayer(factory.Spawn(factory.Player, gs.world))
+	gs.world.Player = factory.Spawn(factory.Player, gs.world)
 	startLoc := gs.action.CreateNextFloor()
 	gs.world.Place(gs.world.Player, startLoc)
 	gs.action.DoFov(gs.world.Player)
 	gs.action.CreateNextFloor()
 }
 
-func (gs *game) Exit() {}
+// Exit saves the game when the player leaves it alive.
+func (gs *game) Exit() {
+	if gs.query.IsGameOver() {
+		return
+	}
+	if err := app.SaveGame(gs.world); err != nil {
+		fmt.Fprintln(os.Stderr, "Saving the game failed:", err)
+	}
+}
 
 func (gs *game) Draw() {
-	sdl.Frame().Clear(gfx.Black)
+	gfx.Frame().Clear(gfx.Black)
 	gs.view.Draw(image.Rect(0, 0, 320, 240))
 	gs.hud.Draw(image.Rect(0, 0, 320, 240))
 }
 
+// Palette shows the player's condition with palette effects.
+func (gs *game) Palette(base color.Palette) color.Palette {
+	return gs.mood.Palette(base)
+}
+
 func (gs *game) Update(timeElapsed int64) {
 	if gs.query.IsGameOver() {
-		app.Get().PopState()
+		// Let the screen fade out before leaving.
+		gs.mood.FadeOut()
+		if gs.mood.IsFaded() {
+			app.Get().PopState()
+		}
+		return
 	}
 
 	// Convenience maps for the directional keys.
@@ -38,7 +38,7 @@ func (in *intro) Enter() {}
 func (in *intro) Exit()  {}
 
 func (in *intro) Draw() {
-	sdl.Frame().Clear(gfx.Black)
+	gfx.Frame().Clear(gfx.Black)
 	sty := util.TextStyle().ForeColor(gfx.Green)
 	sty.Render("TERATOGEN", image.Pt(0, 10))
 	sty.Render("version "+app.Version, image.Pt(0, 240))
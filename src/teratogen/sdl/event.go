@@ -25,6 +25,7 @@ import "C"
 
 import (
 	"image"
+	"sync"
 	"time"
 	"unsafe"
 )
@@ -85,15 +86,59 @@ func (e *event) convert() interface{} {
 
 var Events = make(chan interface{})
 
+var resizeRequest struct {
+	sync.Mutex
+	size    image.Point
+	pending bool
+}
+
+// ResizeRequest returns the latest size the user has resized the window to,
+// if it hasn't been returned before. The window surface must be set to the
+// new size with SetVideoMode.
+func ResizeRequest() (size image.Point, ok bool) {
+	resizeRequest.Lock()
+	defer resizeRequest.Unlock()
+	size, ok = resizeRequest.size, resizeRequest.pending
+	resizeRequest.pending = false
+	return
+}
+
 func eventLoop() {
 	e := &event{}
 	for runLevel == running {
+		select {
+		case mode := <-videoModeRequests:
+			setVideoMode(mode)
+			videoModeDone <- true
+			continue
+		default:
+		}
+
 		if !e.poll() {
 			time.Sleep(10 * 1e6)
 			continue
 		}
 		if evt := e.convert(); evt != nil {
-			Events <- evt
+			if size, ok := evt.(ResizeEvent); ok {
+				resizeRequest.Lock()
+				resizeRequest.size, resizeRequest.pending = image.Point(size), true
+				resizeRequest.Unlock()
+			}
+			send(evt)
+		}
+	}
+}
+
+// send sends an event to the Events channel. Video mode requests are served
+// while waiting, since the receiver may be the one making them.
+func send(evt interface{}) {
+	for {
+		select {
+		case Events <- evt:
+			return
+		case mode := <-videoModeRequests:
+			setVideoMode(mode)
+			videoModeDone <- true
 		}
 	}
 }
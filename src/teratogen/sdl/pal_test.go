@@ -15,16 +15,15 @@
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
-package gfx
+package sdl
 
 import (
 	"image/color"
-	"teratogen/sdl"
 	"testing"
 )
 
-func unpaletted() (result *sdl.Surface) {
-	result = sdl.NewSurface(8, 8)
+func unpaletted() (result *Surface) {
+	result = NewSurface(8, 8)
 	for y := 0; y < 8; y++ {
 		for x := 0; x < 8; x++ {
 			result.Set(x, y, color.RGBA{byte(x*16 + y*16), 0, 0, 255})
@@ -33,7 +32,7 @@ func unpaletted() (result *sdl.Surface) {
 	return result
 }
 
-var pal = sdl.MakePalette([]color.Color{
+var pal = MakePalette([]color.Color{
 	color.RGBA{0, 0x00, 0, 0xff},
 	color.RGBA{0, 0x10, 0, 0xff},
 	color.RGBA{0, 0x20, 0, 0xff},
@@ -51,8 +50,8 @@ var pal = sdl.MakePalette([]color.Color{
 	color.RGBA{0, 0xe0, 0, 0xff},
 	color.RGBA{0, 0xf0, 0, 0xff}})
 
-func paletted() (result *sdl.Surface) {
-	result = sdl.NewPaletteSurface(8, 8)
+func paletted() (result *Surface) {
+	result = NewPaletteSurface(8, 8)
 	result.SetColors(pal)
 	for i := 0; i < 64; i++ {
 		result.Pixels8()[i] = byte(i % 16)
@@ -62,52 +61,52 @@ func paletted() (result *sdl.Surface) {
 
 func BenchmarkPalettedBlit(b *testing.B) {
 	b.StopTimer()
-	sdl.Run(320, 240)
-	defer sdl.Stop()
+	Run(320, 240)
+	defer Stop()
 	surf := paletted()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		for y := 0; y < 30; y++ {
 			for x := 0; x < 40; x++ {
-				surf.Blit(surf.Bounds(), x*8, y*8, sdl.Frame())
+				surf.Blit(surf.Bounds(), x*8, y*8, Frame())
 			}
 		}
-		sdl.Flip()
+		Flip()
 	}
 	b.StopTimer()
 }
 
 func BenchmarkRepalettingBlit(b *testing.B) {
 	b.StopTimer()
-	sdl.Run(320, 240)
-	defer sdl.Stop()
+	Run(320, 240)
+	defer Stop()
 	surf := paletted()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		for y := 0; y < 30; y++ {
 			for x := 0; x < 40; x++ {
-				surf.Blit(surf.Bounds(), x*8, y*8, sdl.Frame())
+				surf.Blit(surf.Bounds(), x*8, y*8, Frame())
 				surf.SetColors(pal)
 			}
 		}
-		sdl.Flip()
+		Flip()
 	}
 	b.StopTimer()
 }
 
 func BenchmarkTruecolorBlit(b *testing.B) {
 	b.StopTimer()
-	sdl.Run(320, 240)
-	defer sdl.Stop()
+	Run(320, 240)
+	defer Stop()
 	surf := unpaletted()
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
 		for y := 0; y < 30; y++ {
 			for x := 0; x < 40; x++ {
-				surf.Blit(surf.Bounds(), x*8, y*8, sdl.Frame())
+				surf.Blit(surf.Bounds(), x*8, y*8, Frame())
 			}
 		}
-		sdl.Flip()
+		Flip()
 	}
 	b.StopTimer()
 }
@@ -26,6 +26,7 @@ import "C"
 
 import (
 	"image"
+	"runtime"
 	"sync"
 	"unsafe"
 )
@@ -60,19 +61,20 @@ func mainLoop(width, height int) {
 	// SDL window must be created in the same thread where the events are
 	// polled. Hence this stuff must be in a separate goroutine along with the
 	// event loop.
+	runtime.LockOSThread()
 
 	initFlags := int64(C.SDL_INIT_VIDEO) | int64(C.SDL_INIT_AUDIO)
-	screenFlags := 0
 
 	if C.SDL_Init(C.Uint32(initFlags)) == C.int(-1) {
 		panic(getError())
 	}
 
-	screen := C.SDL_SetVideoMode(
-		C.int(width), C.int(height), 32, C.Uint32(screenFlags))
-	if screen == nil {
-		panic(getError())
-	}
+	// Before the first video mode is set, the video info tells the current
+	// resolution of the desktop.
+	info := C.SDL_GetVideoInfo()
+	desktopSize = image.Pt(int(info.current_w), int(info.current_h))
+
+	setVideoMode(videoMode{image.Pt(width, height), false})
 	C.SDL_EnableUNICODE(1)
 	C.SDL_EnableKeyRepeat(C.SDL_DEFAULT_REPEAT_DELAY, C.SDL_DEFAULT_REPEAT_INTERVAL)
 
@@ -102,6 +104,43 @@ func Stop() {
 	}
 }
 
+type videoMode struct {
+	size       image.Point
+	fullscreen bool
+}
+
+var videoModeRequests = make(chan videoMode)
+
+var videoModeDone = make(chan bool)
+
+var desktopSize image.Point
+
+func setVideoMode(mode videoMode) {
+	flags := C.Uint32(C.SDL_RESIZABLE)
+	if mode.fullscreen {
+		flags = C.SDL_FULLSCREEN
+	}
+	screen := C.SDL_SetVideoMode(
+		C.int(mode.size.X), C.int(mode.size.Y), 32, flags)
+	if screen == nil {
+		panic(getError())
+	}
+}
+
+// SetVideoMode changes the size of the window or switches to a fullscreen
+// mode of the given size. Surfaces from earlier Video calls are invalid
+// afterwards.
+func SetVideoMode(width, height int, fullscreen bool) {
+	// The mode must be set in the SDL thread.
+	videoModeRequests <- videoMode{image.Pt(width, height), fullscreen}
+	<-videoModeDone
+}
+
+// DesktopSize returns the resolution of the desktop when SDL was started.
+func DesktopSize() image.Point {
+	return desktopSize
+}
+
 func IsKeyDown(key KeySym) bool {
 	var numKeys C.int
 	keys := C.SDL_GetKeyState(&numKeys)
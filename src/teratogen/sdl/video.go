@@ -114,6 +114,11 @@ func (s *Surface) SetColorKey(c color.Color) {
 	C.SDL_SetColorKey(s.ptr, C.SDL_SRCCOLORKEY, C.Uint32(s.MapColor(c)))
 }
 
+// ColorKey returns the transparent pixel value of the surface if it has one.
+func (s *Surface) ColorKey() (key uint32, ok bool) {
+	return uint32(s.ptr.format.colorkey), s.ptr.flags&C.SDL_SRCCOLORKEY != 0
+}
+
 var frame *Surface
 
 // SetFrame sets the default frame buffer to draw to.
@@ -166,6 +171,12 @@ func (s *Surface) ClearClipRect() {
 	C.SDL_SetClipRect(s.ptr, nil)
 }
 
+func (s *Surface) ClipRect() image.Rectangle {
+	var r C.SDL_Rect
+	C.SDL_GetClipRect(s.ptr, &r)
+	return image.Rect(int(r.x), int(r.y), int(r.x)+int(r.w), int(r.y)+int(r.h))
+}
+
 func (s *Surface) IsPalettized() bool {
 	return s.ptr.format.BitsPerPixel == 8
 }
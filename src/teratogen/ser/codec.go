@@ -0,0 +1,341 @@
+// codec.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// maxLength limits the lengths read from a save so that a corrupt length
+// can't make the loader allocate all the memory there is.
+const maxLength = 1 << 28
+
+// encoder writes values to a save stream in a compact binary format. Integers
+// are stored as varints, so small values take only a byte. Write errors
+// cause panics, which Save turns into errors.
+type encoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+	// gob is created when needed for values that store themselves with gob.
+	gob *gob.Encoder
+}
+
+func newEncoder(output io.Writer) *encoder {
+	return &encoder{w: bufio.NewWriter(output)}
+}
+
+func (e *encoder) write(data []byte) {
+	if _, err := e.w.Write(data); err != nil {
+		panic(err)
+	}
+}
+
+func (e *encoder) int(x int64) {
+	n := binary.PutVarint(e.buf[:], x)
+	e.write(e.buf[:n])
+}
+
+func (e *encoder) uint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.write(e.buf[:n])
+}
+
+func (e *encoder) bool(x bool) {
+	if x {
+		e.uint(1)
+	} else {
+		e.uint(0)
+	}
+}
+
+func (e *encoder) bytes(data []byte) {
+	e.uint(uint64(len(data)))
+	e.write(data)
+}
+
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	if _, err := e.w.WriteString(s); err != nil {
+		panic(err)
+	}
+}
+
+func (e *encoder) float(x float64) {
+	binary.LittleEndian.PutUint64(e.buf[:], math.Float64bits(x))
+	e.write(e.buf[:8])
+}
+
+// scalar writes a value of a scalar kind.
+func (e *encoder) scalar(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		e.bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		e.uint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.float(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		e.float(real(v.Complex()))
+		e.float(imag(v.Complex()))
+	case reflect.String:
+		e.string(v.String())
+	default:
+		panic(fmt.Sprintf("Unhandled scalar type %s", v.Type()))
+	}
+}
+
+// storeGob writes a value with gob. All gob values in a save share the same
+// gob stream, so type information is only sent once.
+func (e *encoder) storeGob(value interface{}) {
+	if e.gob == nil {
+		e.gob = gob.NewEncoder(e.w)
+	}
+	if err := e.gob.Encode(value); err != nil {
+		panic(err)
+	}
+}
+
+// The encoder only writes the values, the structure is implied by the
+// types of the loaded values.
+
+func (e *encoder) beginObject(ptr uintptr, name string) {
+	e.uint(uint64(ptr))
+	e.string(name)
+}
+
+func (e *encoder) beginVisits() {}
+
+func (e *encoder) tag(ptr uintptr) { e.uint(uint64(ptr)) }
+
+func (e *encoder) blob(data []byte) { e.bytes(data) }
+
+func (e *encoder) byteSlice(data []byte) {
+	if data == nil {
+		e.int(-1)
+		return
+	}
+	e.int(int64(len(data)))
+	e.write(data)
+}
+
+func (e *encoder) beginStruct() {}
+
+func (e *encoder) field(name string) {}
+
+func (e *encoder) beginArray(n int) {}
+
+func (e *encoder) beginSlice(n int) { e.int(int64(n)) }
+
+func (e *encoder) beginMap(n int) { e.int(int64(n)) }
+
+func (e *encoder) pointer(id int, isNew bool) { e.uint(uint64(id)) }
+
+func (e *encoder) beginInterface(name string, isPtr bool) {
+	e.string(name)
+	if name != "" {
+		e.bool(isPtr)
+	}
+}
+
+func (e *encoder) end() {}
+
+func (e *encoder) output() io.Writer { return e.w }
+
+func (e *encoder) flush() {
+	if err := e.w.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+// decoder reads values written by encoder. Read errors cause panics, which
+// Load turns into errors.
+type decoder struct {
+	r   byteReader
+	gob *gob.Decoder
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func newDecoder(input io.Reader) *decoder {
+	// The reader must not read past the values it decodes, so that callers
+	// can keep reading the input after the save. Readers that can read
+	// single bytes, like bytes.Buffer, are used as they are.
+	if r, ok := input.(byteReader); ok {
+		return &decoder{r: r}
+	}
+	return &decoder{r: bufio.NewReader(input)}
+}
+
+func (d *decoder) read(data []byte) {
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		panic(err)
+	}
+}
+
+func (d *decoder) int() int64 {
+	x, err := binary.ReadVarint(d.r)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func (d *decoder) uint() uint64 {
+	x, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		panic(err)
+	}
+	return x
+}
+
+func (d *decoder) bool() bool {
+	return d.uint() != 0
+}
+
+// length reads a length value and checks that it is sane.
+func (d *decoder) length() int {
+	n := d.uint()
+	if n > maxLength {
+		panic(fmt.Sprintf("Bad length %d", n))
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	result := make([]byte, d.length())
+	d.read(result)
+	return result
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) float() float64 {
+	var buf [8]byte
+	d.read(buf[:])
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf[:]))
+}
+
+// scalar reads a value of a scalar kind into a settable value.
+func (d *decoder) scalar(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := d.int()
+		if v.OverflowInt(x) {
+			panic(fmt.Sprintf("Value %d overflows %s", x, v.Type()))
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x := d.uint()
+		if v.OverflowUint(x) {
+			panic(fmt.Sprintf("Value %d overflows %s", x, v.Type()))
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(d.float())
+	case reflect.Complex64, reflect.Complex128:
+		re := d.float()
+		v.SetComplex(complex(re, d.float()))
+	case reflect.String:
+		v.SetString(d.string())
+	default:
+		panic(fmt.Sprintf("Unhandled scalar type %s", v.Type()))
+	}
+}
+
+// storeGob reads a value written with encoder.storeGob.
+func (d *decoder) storeGob(value interface{}) {
+	if d.gob == nil {
+		d.gob = gob.NewDecoder(d.r)
+	}
+	if err := d.gob.Decode(value); err != nil {
+		panic(err)
+	}
+}
+
+func (d *decoder) beginObject() (ptr uintptr, name string) {
+	ptr = uintptr(d.uint())
+	name = d.string()
+	return
+}
+
+func (d *decoder) beginVisits() {}
+
+func (d *decoder) tag() uintptr { return uintptr(d.uint()) }
+
+func (d *decoder) blob() []byte { return d.bytes() }
+
+func (d *decoder) byteSlice() []byte {
+	n := d.signedLength()
+	if n < 0 {
+		return nil
+	}
+	result := make([]byte, n)
+	d.read(result)
+	return result
+}
+
+func (d *decoder) beginStruct() {}
+
+func (d *decoder) field(name string) {}
+
+func (d *decoder) beginArray(n int) {}
+
+func (d *decoder) beginSlice() int { return d.signedLength() }
+
+func (d *decoder) beginMap() int { return d.signedLength() }
+
+func (d *decoder) pointer() int { return int(d.uint()) }
+
+func (d *decoder) enterPointer() {}
+
+func (d *decoder) beginInterface() (name string, isPtr bool) {
+	name = d.string()
+	if name != "" {
+		isPtr = d.bool()
+	}
+	return
+}
+
+func (d *decoder) end() {}
+
+func (d *decoder) input() io.Reader { return d.r }
+
+// signedLength reads the length of a value that can be nil, which is
+// negative for nil.
+func (d *decoder) signedLength() int {
+	n := d.int()
+	if n > maxLength || n < -1 {
+		panic(fmt.Sprintf("Bad length %d", n))
+	}
+	return int(n)
+}
@@ -0,0 +1,296 @@
+// container.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+// saveMagic starts every save file.
+const saveMagic = "TERASAVE"
+
+// FormatVersion is the version of the save container layout itself, as
+// opposed to the version of the saved data. Format 1 saves, which have no
+// header flags and store every value as a separate gob, can still be
+// loaded.
+const FormatVersion = 2
+
+// compressedFlag marks a compressed payload in the header flags.
+const compressedFlag = 1 << 0
+
+// Header describes the contents of a save file.
+type Header struct {
+	// FormatVersion is the version of the container layout.
+	FormatVersion int
+	// Version is the version of the saved data.
+	Version int
+	// GameVersion is the version string of the game that wrote the save.
+	GameVersion string
+	// Compressed is set if the payload is compressed.
+	Compressed bool
+}
+
+// Migration upgrades the payload of a save of one data version to the next
+// version.
+type Migration func(payload []byte) ([]byte, error)
+
+// Container writes and reads save files that wrap the output of Save with a
+// header and a checksum. Old saves are upgraded to the current data version
+// with the registered migrations when loading.
+type Container struct {
+	// Version is the current version of the saved data. Bump it whenever a
+	// change in the saved types makes old saves unloadable and add a
+	// migration from the old version.
+	Version int
+	// GameVersion is written in the saves for reference.
+	GameVersion string
+	// Compress makes Save compress the saved data. Load reads both
+	// compressed and uncompressed saves.
+	Compress bool
+
+	migrations map[int]Migration
+}
+
+func NewContainer(version int, gameVersion string) *Container {
+	return &Container{
+		Version:     version,
+		GameVersion: gameVersion,
+		migrations:  make(map[int]Migration)}
+}
+
+// AddMigration registers a migration that upgrades saves of data version
+// from to version from + 1.
+func (c *Container) AddMigration(from int, m Migration) {
+	if _, ok := c.migrations[from]; ok {
+		panic(fmt.Sprintf("Duplicate migration from save version %d", from))
+	}
+	c.migrations[from] = m
+}
+
+// Save writes topValue into a save file.
+func (c *Container) Save(topValue interface{}, output io.Writer) error {
+	payload := new(bytes.Buffer)
+	var flags uint32
+	if c.Compress {
+		flags |= compressedFlag
+		zipper, err := flate.NewWriter(payload, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		if err := Save(topValue, zipper); err != nil {
+			return err
+		}
+		if err := zipper.Close(); err != nil {
+			return err
+		}
+	} else if err := Save(topValue, payload); err != nil {
+		return err
+	}
+
+	header := new(bytes.Buffer)
+	header.WriteString(saveMagic)
+	binary.Write(header, binary.BigEndian, uint32(FormatVersion))
+	binary.Write(header, binary.BigEndian, uint32(c.Version))
+	binary.Write(header, binary.BigEndian, uint16(len(c.GameVersion)))
+	header.WriteString(c.GameVersion)
+	binary.Write(header, binary.BigEndian, flags)
+	binary.Write(header, binary.BigEndian, uint64(payload.Len()))
+	binary.Write(header, binary.BigEndian, crc32.ChecksumIEEE(payload.Bytes()))
+
+	if _, err := output.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := output.Write(payload.Bytes())
+	return err
+}
+
+// Load reads a save file, migrating it to the current data version if
+// needed.
+func (c *Container) Load(input io.Reader) (topValue interface{}, header Header, err error) {
+	header, payload, err := c.read(input)
+	if err != nil {
+		return
+	}
+	if header.Version > c.Version {
+		err = errors.New(fmt.Sprintf(
+			"Save version %d is newer than the supported version %d, saved by game version %s",
+			header.Version, c.Version, header.GameVersion))
+		return
+	}
+	for v := header.Version; v < c.Version; v++ {
+		m, ok := c.migrations[v]
+		if !ok {
+			err = errors.New(fmt.Sprintf(
+				"Can't upgrade save version %d, saved by game version %s",
+				v, header.GameVersion))
+			return
+		}
+		if payload, err = m(payload); err != nil {
+			err = errors.New(fmt.Sprintf(
+				"Upgrading save version %d failed: %s", v, err))
+			return
+		}
+	}
+
+	if header.FormatVersion == 1 {
+		topValue, err = load(&format1Decoder{bytes.NewBuffer(payload)})
+	} else {
+		topValue, err = Load(bytes.NewBuffer(payload))
+	}
+	return
+}
+
+// textDump is the JSON form of a save file written by Dump.
+type textDump struct {
+	Header  Header          `json:"header"`
+	Objects json.RawMessage `json:"objects"`
+}
+
+// Dump converts a save file into human-readable JSON with the header and the
+// objects written by SaveText. Old saves are migrated to the current version
+// first.
+func (c *Container) Dump(input io.Reader, output io.Writer) error {
+	topValue, header, err := c.Load(input)
+	if err != nil {
+		return err
+	}
+	header.Version = c.Version
+
+	objects := new(bytes.Buffer)
+	if err = SaveText(topValue, objects); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(textDump{header, objects.Bytes()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(append(data, '\n'))
+	return err
+}
+
+// Undump converts JSON written by Dump back into a save file. The JSON must
+// be for the current save version.
+func (c *Container) Undump(input io.Reader, output io.Writer) error {
+	var dump textDump
+	if err := json.NewDecoder(input).Decode(&dump); err != nil {
+		return err
+	}
+	if dump.Header.Version != c.Version {
+		return errors.New(fmt.Sprintf(
+			"Dump is for save version %d, current version is %d",
+			dump.Header.Version, c.Version))
+	}
+	topValue, err := LoadText(bytes.NewBuffer(dump.Objects))
+	if err != nil {
+		return err
+	}
+
+	saver := *c
+	saver.GameVersion = dump.Header.GameVersion
+	saver.Compress = dump.Header.Compressed
+	return saver.Save(topValue, output)
+}
+
+// ReadHeader reads the header of a save file without loading the contents.
+func (c *Container) ReadHeader(input io.Reader) (header Header, err error) {
+	header, _, err = c.read(input)
+	return
+}
+
+// read reads the header and the checksummed payload of a save file.
+func (c *Container) read(input io.Reader) (header Header, payload []byte, err error) {
+	magic := make([]byte, len(saveMagic))
+	if _, err = io.ReadFull(input, magic); err != nil || string(magic) != saveMagic {
+		err = errors.New("Not a save file")
+		return
+	}
+
+	var format, version, flags uint32
+	var nameLen uint16
+	if err = binary.Read(input, binary.BigEndian, &format); err != nil {
+		err = errTruncated
+		return
+	}
+	if format != FormatVersion && format != 1 {
+		err = errors.New(fmt.Sprintf("Unsupported save format %d", format))
+		return
+	}
+	if err = binary.Read(input, binary.BigEndian, &version); err != nil {
+		err = errTruncated
+		return
+	}
+	if err = binary.Read(input, binary.BigEndian, &nameLen); err != nil {
+		err = errTruncated
+		return
+	}
+	gameVersion := make([]byte, nameLen)
+	if _, err = io.ReadFull(input, gameVersion); err != nil {
+		err = errTruncated
+		return
+	}
+	if format > 1 {
+		if err = binary.Read(input, binary.BigEndian, &flags); err != nil {
+			err = errTruncated
+			return
+		}
+	}
+	header = Header{
+		FormatVersion: int(format),
+		Version:       int(version),
+		GameVersion:   string(gameVersion),
+		Compressed:    flags&compressedFlag != 0}
+
+	var length uint64
+	var checksum uint32
+	if err = binary.Read(input, binary.BigEndian, &length); err != nil {
+		err = errTruncated
+		return
+	}
+	if err = binary.Read(input, binary.BigEndian, &checksum); err != nil {
+		err = errTruncated
+		return
+	}
+	payload, err = ioutil.ReadAll(io.LimitReader(input, int64(length)))
+	if err != nil {
+		return
+	}
+	if uint64(len(payload)) != length {
+		err = errTruncated
+		return
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		err = errors.New("Save file is corrupt, checksum mismatch")
+		return
+	}
+	if header.Compressed {
+		if payload, err = ioutil.ReadAll(flate.NewReader(bytes.NewBuffer(payload))); err != nil {
+			err = errors.New(fmt.Sprintf("Decompressing save failed: %s", err))
+		}
+	}
+	return
+}
+
+var errTruncated = errors.New("Save file is truncated")
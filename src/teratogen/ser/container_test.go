@@ -0,0 +1,177 @@
+// container_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func saveFile(t *testing.T, c *Container, obj interface{}) []byte {
+	out := bytes.NewBuffer(nil)
+	if err := c.Save(obj, out); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestContainer(t *testing.T) {
+	Register((*linky)(nil))
+	c := NewContainer(3, "test-1.0")
+	data := saveFile(t, c, &linky{val: 7})
+
+	obj, header, err := c.Load(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != (Header{FormatVersion, 3, "test-1.0", false}) {
+		t.Errorf("Bad header %v", header)
+	}
+	if obj.(*linky).val != 7 {
+		t.Error("Bad value")
+	}
+}
+
+func TestCompressedContainer(t *testing.T) {
+	Register((*everything)(nil))
+	e := &everything{list: make([]int, 1000)}
+
+	c := NewContainer(1, "test")
+	plain := saveFile(t, c, e)
+	c.Compress = true
+	compressed := saveFile(t, c, e)
+	if len(compressed) >= len(plain) {
+		t.Errorf("Compressed save is %d bytes, plain %d", len(compressed), len(plain))
+	}
+
+	c.Compress = false
+	obj, header, err := c.Load(bytes.NewBuffer(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Compressed || len(obj.(*everything).list) != 1000 {
+		t.Error("Bad compressed load")
+	}
+}
+
+func TestContainerErrors(t *testing.T) {
+	Register((*linky)(nil))
+	c := NewContainer(1, "test")
+	data := saveFile(t, c, &linky{val: 7})
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	newer := saveFile(t, NewContainer(2, "test"), &linky{val: 7})
+
+	for _, test := range []struct {
+		data []byte
+		msg  string
+	}{
+		{[]byte("garbage that is not a save"), "Not a save"},
+		{data[:len(data)-3], "truncated"},
+		{data[:10], "truncated"},
+		{corrupt, "corrupt"},
+		{newer, "newer"},
+	} {
+		_, _, err := c.Load(bytes.NewBuffer(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.msg) {
+			t.Errorf("Expected error about %q, got %v", test.msg, err)
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	Register((*linky)(nil))
+	data := saveFile(t, NewContainer(1, "old"), &linky{val: 7})
+
+	c := NewContainer(3, "new")
+	if _, _, err := c.Load(bytes.NewBuffer(data)); err == nil {
+		t.Error("Loaded an old save without migrations")
+	}
+
+	steps := []int{}
+	c.AddMigration(1, func(payload []byte) ([]byte, error) {
+		steps = append(steps, 1)
+		return payload, nil
+	})
+	c.AddMigration(2, func(payload []byte) ([]byte, error) {
+		steps = append(steps, 2)
+		return payload, nil
+	})
+	obj, header, err := c.Load(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0] != 1 || steps[1] != 2 {
+		t.Errorf("Bad migration steps %v", steps)
+	}
+	if header.Version != 1 || header.GameVersion != "old" || obj.(*linky).val != 7 {
+		t.Error("Bad migrated load")
+	}
+
+	failing := NewContainer(2, "new")
+	failing.AddMigration(1, func(payload []byte) ([]byte, error) {
+		return nil, errors.New("nope")
+	})
+	if _, _, err := failing.Load(bytes.NewBuffer(data)); err == nil ||
+		!strings.Contains(err.Error(), "nope") {
+		t.Errorf("Bad failed migration error %v", err)
+	}
+}
+
+// oldSave is the top value of testdata/format1.sav, which was written by the
+// format 1 container.
+type oldSave struct {
+	e     *everything
+	data  []byte
+	stamp time.Time
+}
+
+func (s *oldSave) Serialize(a Archive) error {
+	a.Visit(&s.e, &s.data, &s.stamp)
+	return nil
+}
+
+func TestFormat1(t *testing.T) {
+	newEverything()
+	Register((*oldSave)(nil))
+	f, err := os.Open("testdata/format1.sav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	obj, header, err := NewContainer(1, "new").Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != (Header{1, 1, "old", false}) {
+		t.Errorf("Bad format 1 header %v", header)
+	}
+	s := obj.(*oldSave)
+	checkEverything(t, s.e)
+	if string(s.data) != "old bytes" ||
+		!s.stamp.Equal(time.Date(2013, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Bad format 1 values %q %v", s.data, s.stamp)
+	}
+}
@@ -0,0 +1,121 @@
+// format1.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// format1Decoder reads the payloads of format 1 saves, which stored every
+// value as a separate gob stream. The input must be a byteReader so that the
+// gob decoders don't read ahead.
+type format1Decoder struct {
+	r byteReader
+}
+
+func (d *format1Decoder) gob(value interface{}) {
+	if err := gob.NewDecoder(d.r).Decode(value); err != nil {
+		panic(err)
+	}
+}
+
+func (d *format1Decoder) length() int {
+	var n int
+	d.gob(&n)
+	if n > maxLength || n < -1 {
+		panic(fmt.Sprintf("Bad length %d", n))
+	}
+	return n
+}
+
+func (d *format1Decoder) beginObject() (ptr uintptr, name string) {
+	d.gob(&ptr)
+	d.gob(&name)
+	return
+}
+
+func (d *format1Decoder) beginVisits() {}
+
+func (d *format1Decoder) tag() (ptr uintptr) {
+	d.gob(&ptr)
+	return
+}
+
+func (d *format1Decoder) storeGob(value interface{}) { d.gob(value) }
+
+func (d *format1Decoder) scalar(v reflect.Value) { d.gob(v.Addr().Interface()) }
+
+func (d *format1Decoder) blob() []byte {
+	var data gobData
+	d.gob(&data)
+	return data
+}
+
+// Byte slices were stored like any other slices.
+func (d *format1Decoder) byteSlice() []byte {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	result := make([]byte, n)
+	for i := range result {
+		d.gob(&result[i])
+	}
+	return result
+}
+
+func (d *format1Decoder) beginStruct() {}
+
+func (d *format1Decoder) field(name string) {}
+
+func (d *format1Decoder) beginArray(n int) {}
+
+func (d *format1Decoder) beginSlice() int { return d.length() }
+
+func (d *format1Decoder) beginMap() int { return d.length() }
+
+func (d *format1Decoder) pointer() (id int) {
+	d.gob(&id)
+	return
+}
+
+func (d *format1Decoder) enterPointer() {}
+
+func (d *format1Decoder) beginInterface() (name string, isPtr bool) {
+	d.gob(&name)
+	if name != "" {
+		d.gob(&isPtr)
+	}
+	return
+}
+
+func (d *format1Decoder) end() {}
+
+func (d *format1Decoder) input() io.Reader { return d.r }
+
+// gobData receives the data of a value that was stored with its GobEncode
+// method.
+type gobData []byte
+
+func (g *gobData) GobDecode(data []byte) error {
+	*g = append(gobData(nil), data...)
+	return nil
+}
@@ -27,12 +27,16 @@ import (
 )
 
 func Load(input io.Reader) (topValue interface{}, err error) {
+	return load(newDecoder(input))
+}
+
+func load(in source) (topValue interface{}, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			err = errors.New(fmt.Sprint("Load: ", e))
 		}
 	}()
-	lo := newLoader(input)
+	lo := newLoader(in)
 
 	topValue = lo.loadSingle()
 	for _, ok := lo.nextUnprocessed(); ok; _, ok = lo.nextUnprocessed() {
@@ -44,19 +48,50 @@ func Load(input io.Reader) (topValue interface{}, err error) {
 	return
 }
 
+// source provides the values for a loader in the order a saver gave them to
+// a sink.
+type source interface {
+	beginObject() (ptr uintptr, name string)
+	beginVisits()
+	tag() uintptr
+	storeGob(value interface{})
+	// scalar reads a scalar into a settable value.
+	scalar(v reflect.Value)
+	blob() []byte
+	// byteSlice returns nil for a nil slice.
+	byteSlice() []byte
+	beginStruct()
+	field(name string)
+	beginArray(n int)
+	// beginSlice and beginMap return the length of the value, or a negative
+	// one for a nil value, in which case nothing is started.
+	beginSlice() int
+	beginMap() int
+	// pointer returns the pointer ID, and enterPointer starts the value of a
+	// new pointer.
+	pointer() int
+	enterPointer()
+	// beginInterface returns an empty name and starts nothing for a nil
+	// value.
+	beginInterface() (name string, isPtr bool)
+	end()
+	// input returns the raw input stream, if there is one.
+	input() io.Reader
+}
+
 type loader struct {
 	base
 	// List stale pointers
 	stalePointers []uintptr
-	input         io.Reader
+	in            source
+	// shared maps the pointer IDs in the save to the loaded pointers.
+	shared map[int]reflect.Value
 }
 
-func newLoader(input io.Reader) (result *loader) {
+func newLoader(in source) (result *loader) {
 	result = &loader{stalePointers: []uintptr{},
-		input: input}
-
-	result.processedObjects = make(map[uintptr]interface{})
-	result.seenObjects = make(map[uintptr]interface{})
+		in: in, shared: make(map[int]reflect.Value)}
+	result.init()
 	return
 }
 
@@ -66,7 +101,9 @@ func (lo *loader) Visit(value ...interface{}) {
 	}
 }
 
-func (lo *loader) Input() io.Reader { return lo.input }
+func (lo *loader) Loading() bool { return true }
+
+func (lo *loader) Input() io.Reader { return lo.in.input() }
 
 func (lo *loader) Output() io.Writer { return nil }
 
@@ -81,26 +118,147 @@ func (lo *loader) TagPointer(obj interface{}) {
 	// all pointers *to* those pointers. When the stale to fresh pointer
 	// lookup is complete, we will walk through the stale pointer list and
 	// replace every one of them with fresh pointers.
-	var stalePtr uintptr
-	gobLoad(&stalePtr, lo.input)
+	stalePtr := lo.in.tag()
+	if stalePtr == 0 {
+		// Nil pointer, nothing to remap.
+		return
+	}
 	targetPtr := (*uintptr)(unsafe.Pointer(v.Pointer()))
 	*targetPtr = stalePtr
 	lo.stalePointers = append(lo.stalePointers, (uintptr)(v.Pointer()))
-	lo.seenObjects[stalePtr] = nil
+	lo.see(stalePtr, nil)
 }
 
 func (lo *loader) StoreGob(obj interface{}) {
-	gobLoad(obj, lo.input)
+	lo.in.storeGob(obj)
 }
 
 func (lo *loader) visitSingle(obj interface{}) {
-	switch obj.(type) {
-	case *bool, *int, *int8, *int16, *int32, *int64, *uint, *uint8, *uint16,
-		*uint32, *uint64, *float32, *float64, *complex64, *complex128, *string:
-		gobLoad(obj, lo.input)
+	lo.visitValue(visitTarget(obj))
+}
+
+// visitValue loads an addressable value.
+func (lo *loader) visitValue(v reflect.Value) {
+	v = settable(v)
+	t := v.Type()
+	switch lo.handlingOf(t) {
+	case bySerialize:
+		lo.in.beginVisits()
+		if err := v.Addr().Interface().(Serializable).Serialize(lo); err != nil {
+			panic(err)
+		}
+		lo.in.end()
+		return
+	case byGob:
+		if err := v.Addr().Interface().(gob.GobDecoder).GobDecode(lo.in.blob()); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if isScalar(t.Kind()) {
+		lo.in.scalar(v)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		lo.visitPointer(v)
+	case reflect.Array:
+		lo.in.beginArray(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			lo.visitValue(v.Index(i))
+		}
+		lo.in.end()
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			data := lo.in.byteSlice()
+			if data == nil {
+				v.Set(reflect.Zero(t))
+			} else {
+				v.SetBytes(data)
+			}
+			return
+		}
+		n := lo.in.beginSlice()
+		if n < 0 {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		v.Set(reflect.MakeSlice(t, n, n))
+		for i := 0; i < n; i++ {
+			lo.visitValue(v.Index(i))
+		}
+		lo.in.end()
+	case reflect.Map:
+		n := lo.in.beginMap()
+		if n < 0 {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		v.Set(reflect.MakeMap(t))
+		for i := 0; i < n; i++ {
+			key := reflect.New(t.Key()).Elem()
+			elem := reflect.New(t.Elem()).Elem()
+			lo.visitValue(key)
+			lo.visitValue(elem)
+			v.SetMapIndex(key, elem)
+		}
+		lo.in.end()
+	case reflect.Struct:
+		lo.in.beginStruct()
+		for i := 0; i < v.NumField(); i++ {
+			lo.in.field(t.Field(i).Name)
+			lo.visitValue(v.Field(i))
+		}
+		lo.in.end()
+	case reflect.Interface:
+		name, isPtr := lo.in.beginInterface()
+		if name == "" {
+			v.Set(reflect.Zero(t))
+			return
+		}
+		elemType, ok := nameToConcreteType[name]
+		if !ok {
+			panic(fmt.Sprintf("Tried to create unregistered type '%s'", name))
+		}
+		if isPtr {
+			elemType = reflect.PtrTo(elemType)
+		}
+		if !elemType.AssignableTo(t) {
+			panic(fmt.Sprintf("Type %s doesn't fit in %s", elemType, t))
+		}
+		elem := reflect.New(elemType).Elem()
+		lo.visitValue(elem)
+		v.Set(elem)
+		lo.in.end()
 	default:
-		panic("Unhandled value type")
+		panic(fmt.Sprintf("Unhandled value type %s", t))
+	}
+}
+
+// visitPointer loads the pointed value the first time a pointer ID is seen,
+// and points to the already loaded value after that.
+func (lo *loader) visitPointer(v reflect.Value) {
+	id := lo.in.pointer()
+	if id == 0 {
+		v.Set(reflect.Zero(v.Type()))
+		return
 	}
+	if ptr, ok := lo.shared[id]; ok {
+		if ptr.Type() != v.Type() {
+			panic(fmt.Sprintf("Shared pointer %d is %s, not %s", id, ptr.Type(), v.Type()))
+		}
+		v.Set(ptr)
+		return
+	}
+	ptr := reflect.New(v.Type().Elem())
+	// Store the pointer before loading the value so that cycles back to it
+	// resolve.
+	lo.shared[id] = ptr
+	v.Set(ptr)
+	lo.in.enterPointer()
+	lo.visitValue(ptr.Elem())
+	lo.in.end()
 }
 
 func (lo *loader) remapStalePointers() {
@@ -108,7 +266,7 @@ func (lo *loader) remapStalePointers() {
 		targetPtr := (*uintptr)(unsafe.Pointer(ptr))
 		fresh, ok := lo.processedObjects[*targetPtr]
 		if !ok {
-			panic(fmt.Sprintf("Unmapped stale pointer %s", ptr))
+			panic(fmt.Sprintf("Unmapped stale pointer %x", ptr))
 		}
 		*targetPtr = reflect.ValueOf(fresh).Pointer()
 	}
@@ -116,23 +274,13 @@ func (lo *loader) remapStalePointers() {
 }
 
 func (lo *loader) loadSingle() interface{} {
-	var oldPtr uintptr
-	var typeName string
-	gobLoad(&oldPtr, lo.input)
-	gobLoad(&typeName, lo.input)
+	oldPtr, typeName := lo.in.beginObject()
 	result := newInstance(typeName)
 	err := result.Serialize(lo)
 	if err != nil {
 		panic(err)
 	}
+	lo.in.end()
 	lo.processedObjects[oldPtr] = result
 	return result
 }
-
-func gobLoad(value interface{}, input io.Reader) {
-	gobber := gob.NewDecoder(input)
-	err := gobber.Decode(value)
-	if err != nil {
-		panic(err)
-	}
-}
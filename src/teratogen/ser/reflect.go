@@ -0,0 +1,171 @@
+// reflect.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sort"
+	"unsafe"
+)
+
+var (
+	serializableType = reflect.TypeOf((*Serializable)(nil)).Elem()
+	gobEncoderType   = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	gobDecoderType   = reflect.TypeOf((*gob.GobDecoder)(nil)).Elem()
+)
+
+// sharedKey identifies a pointer seen by Visit. The type is needed because a
+// pointer to a struct and a pointer to its first field have the same
+// address.
+type sharedKey struct {
+	ptr uintptr
+	t   reflect.Type
+}
+
+// handling tells how values of a type are visited.
+type handling uint8
+
+const (
+	// byKind values are visited based on their reflect kind.
+	byKind handling = iota
+	// bySerialize values are handled by their own Serialize method.
+	bySerialize
+	// byGob values know how to store themselves with gob. Types like
+	// time.Time are stored this way instead of visiting their internals.
+	byGob
+)
+
+// handlingOf returns how the values of a type are visited. The results are
+// cached, since checking the interfaces is slow.
+func (b *base) handlingOf(t reflect.Type) handling {
+	if h, ok := b.handlings[t]; ok {
+		return h
+	}
+	h := byKind
+	pt := reflect.PtrTo(t)
+	if pt.Implements(serializableType) {
+		h = bySerialize
+	} else if pt.Implements(gobEncoderType) && pt.Implements(gobDecoderType) {
+		h = byGob
+	}
+	b.handlings[t] = h
+	return h
+}
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64,
+		reflect.Complex64, reflect.Complex128, reflect.String:
+		return true
+	}
+	return false
+}
+
+// settable returns a settable version of an addressable value. Reflection
+// doesn't allow setting or even reading unexported struct fields directly,
+// but the serialized types are full of them.
+func settable(v reflect.Value) reflect.Value {
+	if v.CanSet() {
+		return v
+	}
+	if !v.CanAddr() {
+		panic(fmt.Sprintf("Unaddressable value of type %s", v.Type()))
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// visitTarget checks that the value given to Visit is a non-nil pointer and
+// returns the settable value it points to.
+func visitTarget(obj interface{}) reflect.Value {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic(fmt.Sprintf("Visit called with non-pointer value %v", obj))
+	}
+	return v.Elem()
+}
+
+// interfaceTypeName returns the registered name of the type of a value
+// stored in an interface, and whether the value is a pointer to the
+// registered type.
+func interfaceTypeName(t reflect.Type) (name string, isPtr bool) {
+	if name, ok := concreteTypeToName[t]; ok {
+		return name, false
+	}
+	if t.Kind() == reflect.Ptr {
+		if name, ok := concreteTypeToName[t.Elem()]; ok {
+			return name, true
+		}
+	}
+	panic(fmt.Sprintf("Serializing unregistered type %s in interface", t))
+}
+
+// sortedKeys returns the keys of a map in a deterministic order.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := valueSlice(m.MapKeys())
+	sort.Sort(keys)
+	return keys
+}
+
+type valueSlice []reflect.Value
+
+func (s valueSlice) Len() int           { return len(s) }
+func (s valueSlice) Less(i, j int) bool { return valueLess(s[i], s[j]) }
+func (s valueSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// valueLess orders values of the same type. Only types whose order doesn't
+// depend on memory addresses are supported.
+func valueLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if valueLess(a.Field(i), b.Field(i)) {
+				return true
+			}
+			if valueLess(b.Field(i), a.Field(i)) {
+				return false
+			}
+		}
+		return false
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if valueLess(a.Index(i), b.Index(i)) {
+				return true
+			}
+			if valueLess(b.Index(i), a.Index(i)) {
+				return false
+			}
+		}
+		return false
+	}
+	panic(fmt.Sprintf("Can't order map keys of type %s", a.Type()))
+}
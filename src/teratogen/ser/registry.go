@@ -40,6 +40,24 @@ func RegisterName(name string, value Serializable) {
 		panic("attempt to register empty name")
 	}
 	rt := reflect.TypeOf(value).Elem()
+	registerType(name, rt)
+}
+
+// RegisterValue records a type that isn't Serializable itself but can be
+// stored in interface values visited by an archive. Both values and pointers
+// of the type can be stored in the interfaces.
+func RegisterValue(name string, value interface{}) {
+	if name == "" {
+		panic("attempt to register empty name")
+	}
+	rt := reflect.TypeOf(value)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	registerType(name, rt)
+}
+
+func registerType(name string, rt reflect.Type) {
 	// Check for incompatible duplicates. The name must refer to the
 	// same user type, and vice versa.
 	if t, ok := nameToConcreteType[name]; ok && t != rt {
@@ -26,31 +26,68 @@ import (
 )
 
 func Save(topValue interface{}, output io.Writer) (err error) {
+	return save(topValue, newEncoder(output))
+}
+
+func save(topValue interface{}, out sink) (err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			err = errors.New(fmt.Sprint("Save: ", e))
 		}
 	}()
-	s := newSaver(output)
+	s := newSaver(out)
 
 	s.saveSingle(topValue.(Serializable))
 
 	for ptr, ok := s.nextUnprocessed(); ok; ptr, ok = s.nextUnprocessed() {
 		s.saveSingle(s.seenObjects[ptr].(Serializable))
 	}
+	s.out.flush()
 	return
 }
 
+// sink receives the values visited by a saver. Methods starting with begin
+// and non-nil pointers start a nested value that is closed with end.
+type sink interface {
+	// beginObject starts a separately saved object.
+	beginObject(ptr uintptr, name string)
+	// beginVisits starts the values visited by a Serialize method.
+	beginVisits()
+	tag(ptr uintptr)
+	storeGob(value interface{})
+	scalar(v reflect.Value)
+	// blob writes the data of a value that encodes itself.
+	blob(data []byte)
+	// byteSlice writes a byte slice, which may be nil.
+	byteSlice(data []byte)
+	beginStruct()
+	field(name string)
+	beginArray(n int)
+	// beginSlice and beginMap start nothing when n is negative for a nil
+	// value.
+	beginSlice(n int)
+	beginMap(n int)
+	// pointer writes a pointer ID, which is zero for nil. If the pointer is
+	// new, its value follows.
+	pointer(id int, isNew bool)
+	// beginInterface starts nothing when name is empty for a nil value.
+	beginInterface(name string, isPtr bool)
+	end()
+	// output returns the raw output stream, if there is one.
+	output() io.Writer
+	flush()
+}
+
 type saver struct {
 	base
-	output io.Writer
+	out sink
+	// shared maps the pointers seen by Visit to their IDs in the save.
+	shared map[sharedKey]int
 }
 
-func newSaver(output io.Writer) (result *saver) {
-	result = &saver{output: output}
-
-	result.processedObjects = make(map[uintptr]interface{})
-	result.seenObjects = make(map[uintptr]interface{})
+func newSaver(out sink) (result *saver) {
+	result = &saver{out: out, shared: make(map[sharedKey]int)}
+	result.init()
 	return
 }
 
@@ -60,9 +97,11 @@ func (s *saver) Visit(value ...interface{}) {
 	}
 }
 
+func (s *saver) Loading() bool { return false }
+
 func (s *saver) Input() io.Reader { return nil }
 
-func (s *saver) Output() io.Writer { return s.output }
+func (s *saver) Output() io.Writer { return s.out.output() }
 
 func (s *saver) TagPointer(obj interface{}) {
 	v := reflect.ValueOf(obj)
@@ -70,31 +109,132 @@ func (s *saver) TagPointer(obj interface{}) {
 		panic(fmt.Sprintf("TagPointer called with non-pointer value %s", v))
 	}
 	v = reflect.Indirect(v)
-	gobSave(v.Pointer(), s.output)
-	s.seenObjects[v.Pointer()] = v.Interface()
+	s.out.tag(v.Pointer())
+	if v.IsNil() {
+		return
+	}
+	s.see(v.Pointer(), v.Interface())
 }
 
 func (s *saver) StoreGob(value interface{}) {
-	gobSave(value, s.output)
+	s.out.storeGob(value)
 }
 
 func (s *saver) visitSingle(obj interface{}) {
-	switch obj.(type) {
-	case *bool, *int, *int8, *int16, *int32, *int64, *uint, *uint8, *uint16,
-		*uint32, *uint64, *float32, *float64, *complex64, *complex128, *string:
-		deref := reflect.Indirect(reflect.ValueOf(obj))
-		gobSave(deref.Interface(), s.output)
+	s.visitValue(visitTarget(obj))
+}
+
+// visitValue saves an addressable value.
+func (s *saver) visitValue(v reflect.Value) {
+	v = settable(v)
+	t := v.Type()
+	switch s.handlingOf(t) {
+	case bySerialize:
+		s.out.beginVisits()
+		if err := v.Addr().Interface().(Serializable).Serialize(s); err != nil {
+			panic(err)
+		}
+		s.out.end()
+		return
+	case byGob:
+		data, err := v.Addr().Interface().(gob.GobEncoder).GobEncode()
+		if err != nil {
+			panic(err)
+		}
+		s.out.blob(data)
+		return
+	}
+	if isScalar(t.Kind()) {
+		s.out.scalar(v)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s.visitPointer(v)
+	case reflect.Array:
+		s.out.beginArray(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.visitValue(v.Index(i))
+		}
+		s.out.end()
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s.out.byteSlice(v.Bytes())
+			return
+		}
+		if v.IsNil() {
+			s.out.beginSlice(-1)
+			return
+		}
+		s.out.beginSlice(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.visitValue(v.Index(i))
+		}
+		s.out.end()
+	case reflect.Map:
+		if v.IsNil() {
+			s.out.beginMap(-1)
+			return
+		}
+		s.out.beginMap(v.Len())
+		for _, key := range sortedKeys(v) {
+			s.visitValue(copyValue(key))
+			s.visitValue(copyValue(v.MapIndex(key)))
+		}
+		s.out.end()
+	case reflect.Struct:
+		s.out.beginStruct()
+		for i := 0; i < v.NumField(); i++ {
+			s.out.field(t.Field(i).Name)
+			s.visitValue(v.Field(i))
+		}
+		s.out.end()
+	case reflect.Interface:
+		if v.IsNil() {
+			s.out.beginInterface("", false)
+			return
+		}
+		name, isPtr := interfaceTypeName(v.Elem().Type())
+		s.out.beginInterface(name, isPtr)
+		s.visitValue(copyValue(v.Elem()))
+		s.out.end()
 	default:
-		panic("Unhandled value type")
+		panic(fmt.Sprintf("Unhandled value type %s", t))
+	}
+}
+
+// visitPointer saves the pointed value the first time the pointer is seen,
+// and only the ID of the pointer after that.
+func (s *saver) visitPointer(v reflect.Value) {
+	if v.IsNil() {
+		s.out.pointer(0, false)
+		return
 	}
+	key := sharedKey{v.Pointer(), v.Type()}
+	if id, ok := s.shared[key]; ok {
+		s.out.pointer(id, false)
+		return
+	}
+	id := len(s.shared) + 1
+	s.shared[key] = id
+	s.out.pointer(id, true)
+	s.visitValue(v.Elem())
+	s.out.end()
+}
+
+// copyValue makes an addressable copy of a value.
+func copyValue(v reflect.Value) reflect.Value {
+	result := reflect.New(v.Type()).Elem()
+	result.Set(v)
+	return result
 }
 
 func (s *saver) saveSingle(obj Serializable) {
 	v := reflect.ValueOf(obj)
-	gobSave(v.Pointer(), s.output)
 
 	if name, ok := concreteTypeToName[reflect.TypeOf(obj).Elem()]; ok {
-		gobSave(name, s.output)
+		s.out.beginObject(v.Pointer(), name)
 	} else {
 		panic(fmt.Sprintf("Serializing unregistered type %s", reflect.TypeOf(obj)))
 	}
@@ -103,14 +243,7 @@ func (s *saver) saveSingle(obj Serializable) {
 	if err != nil {
 		panic(err)
 	}
+	s.out.end()
 
 	s.processedObjects[v.Pointer()] = obj
 }
-
-func gobSave(value interface{}, output io.Writer) {
-	gobber := gob.NewEncoder(output)
-	err := gobber.Encode(value)
-	if err != nil {
-		panic(err)
-	}
-}
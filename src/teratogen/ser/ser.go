@@ -20,6 +20,7 @@ package ser
 
 import (
 	"io"
+	"reflect"
 )
 
 type Serializable interface {
@@ -32,28 +33,47 @@ type Serializable interface {
 type Archive interface {
 	// Visit tells the archive to serialize or deserialize, depending on
 	// archive type, the given pointer values.
+	//
+	// The pointed values can be scalars, arrays, slices, maps, structs,
+	// pointers or interfaces, nested any way, and unexported struct fields
+	// are visited too. Values whose pointer type is Serializable are handled
+	// by calling their Serialize method, so a Serialize method must not
+	// Visit its own receiver. Map entries are stored in the order of their
+	// keys. Pointers are shared: the pointed value is stored once and every
+	// pointer to it points to the same value after loading, so cycles are
+	// fine. Interfaces may only contain values or pointers of registered
+	// types. Types that implement the gob encoder and decoder interfaces are
+	// stored with their GobEncode methods.
 	Visit(value ...interface{})
 
-	// Input returns the io.Reader for a deserializing archive and nil for a
+	// Loading returns true for a deserializing archive and false for a
 	// serializing one.
+	Loading() bool
+
+	// Input returns the io.Reader for a deserializing archive and nil for a
+	// serializing one. The text archives of SaveText and LoadText have no
+	// reader or writer, and return nil for both Input and Output.
 	Input() io.Reader
 
 	// Output returns the io.Writer for a serializing archive and nil for a
 	// deserializing one.
+	//
+	// The archive buffers its input and output, so data written directly to
+	// Output must be read back from Input in the same order with the same
+	// amounts.
 	Output() io.Writer
 
 	// TagPointer explicitly marks a pointer to a pointer value for the
 	// archive. The target of the pointer will need to be serialized
 	// separately (once), and on deserialization the pointed pointer will need
 	// to be rewritten to whatever the new address of the thing ends up being.
-	// This method may be deprecated in favor of just using Visit once Visit
-	// becomes sufficiently smart to deal with pointers.
+	// New code should use Visit, which handles pointers by itself. Pointers
+	// to the same object must not be given to both TagPointer and Visit.
 	TagPointer(ptr interface{})
 
 	// StoreGob uses the gob facility to store simple structured data. The
 	// data mustn't contain pointers that are used anywhere else, since gob
-	// will flatten everything. May be deprecated once the visit method gets
-	// smarter.
+	// will flatten everything. New code should use Visit instead.
 	StoreGob(value interface{})
 }
 
@@ -65,13 +85,35 @@ type base struct {
 	// seenObjects contains pointers seen by the archive that have not yet
 	// been necessarily processed.
 	seenObjects map[uintptr]interface{}
+
+	// seenOrder lists the seen pointers in the order they were first seen,
+	// so that the objects are saved in a deterministic order.
+	seenOrder []uintptr
+
+	handlings map[reflect.Type]handling
+}
+
+func (b *base) init() {
+	b.processedObjects = make(map[uintptr]interface{})
+	b.seenObjects = make(map[uintptr]interface{})
+	b.seenOrder = []uintptr{}
+	b.handlings = make(map[reflect.Type]handling)
+}
+
+func (b *base) see(ptr uintptr, obj interface{}) {
+	if _, ok := b.seenObjects[ptr]; !ok {
+		b.seenOrder = append(b.seenOrder, ptr)
+	}
+	b.seenObjects[ptr] = obj
 }
 
 func (b *base) nextUnprocessed() (p uintptr, ok bool) {
-	for ptr, _ := range b.seenObjects {
-		if _, ok := b.processedObjects[ptr]; !ok {
-			return ptr, true
+	for len(b.seenOrder) > 0 {
+		p = b.seenOrder[0]
+		if _, done := b.processedObjects[p]; !done {
+			return p, true
 		}
+		b.seenOrder = b.seenOrder[1:]
 	}
 	return
 }
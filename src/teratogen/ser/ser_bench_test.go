@@ -0,0 +1,174 @@
+// ser_bench_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bytes"
+	"image"
+	"teratogen/space"
+	"testing"
+)
+
+// benchWorld mimics the shape of the game world: a big terrain map, portals
+// and entities with components behind interfaces and shared pointers.
+type benchWorld struct {
+	terrain  map[space.Location]uint8
+	portals  map[space.Location]space.Portal
+	entities []*benchEntity
+	player   *benchEntity
+}
+
+func (w *benchWorld) Serialize(a Archive) error {
+	a.Visit(&w.terrain, &w.portals, &w.entities, &w.player)
+	return nil
+}
+
+type benchEntity struct {
+	id         uint32
+	loc        space.Location
+	footprint  map[image.Point]space.Location
+	components []interface{}
+	target     *benchEntity
+}
+
+type benchStats struct {
+	health, maxHealth, shield int
+}
+
+type benchSprite struct {
+	sheet string
+	frame int
+	big   bool
+}
+
+func newBenchWorld(cells, entities int) *benchWorld {
+	w := &benchWorld{
+		terrain: make(map[space.Location]uint8),
+		portals: make(map[space.Location]space.Portal)}
+	side := 1
+	for side*side < cells {
+		side++
+	}
+	for i := 0; i < cells; i++ {
+		loc := space.Loc(int16(i%side), int16(i/side), 1)
+		w.terrain[loc] = uint8(i % 7)
+		if i%97 == 0 {
+			w.portals[loc] = space.Port(3, 0, 2)
+		}
+	}
+	for i := 0; i < entities; i++ {
+		loc := space.Loc(int16(i%side), int16(i/side), 1)
+		e := &benchEntity{
+			id:        uint32(i + 1),
+			loc:       loc,
+			footprint: map[image.Point]space.Location{image.Pt(0, 0): loc},
+			components: []interface{}{
+				&benchStats{health: 3, maxHealth: 3},
+				&benchSprite{sheet: "chars", frame: i % 16}}}
+		if i%4 == 0 {
+			e.footprint[image.Pt(1, 0)] = loc.Add(image.Pt(1, 0))
+			e.footprint[image.Pt(0, 1)] = loc.Add(image.Pt(0, 1))
+			e.footprint[image.Pt(1, 1)] = loc.Add(image.Pt(1, 1))
+		}
+		w.entities = append(w.entities, e)
+	}
+	w.player = w.entities[0]
+	for _, e := range w.entities {
+		e.target = w.player
+	}
+	return w
+}
+
+func registerBench() {
+	Register((*benchWorld)(nil))
+	RegisterValue("benchStats", benchStats{})
+	RegisterValue("benchSprite", benchSprite{})
+}
+
+func benchmarkSave(b *testing.B, compress bool) {
+	registerBench()
+	w := newBenchWorld(4000, 300)
+	c := NewContainer(1, "bench")
+	c.Compress = compress
+	buf := new(bytes.Buffer)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := c.Save(w, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(buf.Len()))
+}
+
+func BenchmarkSaveWorld(b *testing.B) { benchmarkSave(b, false) }
+
+func BenchmarkSaveWorldCompressed(b *testing.B) { benchmarkSave(b, true) }
+
+func benchmarkLoad(b *testing.B, compress bool) {
+	registerBench()
+	c := NewContainer(1, "bench")
+	c.Compress = compress
+	buf := new(bytes.Buffer)
+	if err := c.Save(newBenchWorld(4000, 300), buf); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := c.Load(bytes.NewBuffer(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadWorld(b *testing.B) { benchmarkLoad(b, false) }
+
+func BenchmarkLoadWorldCompressed(b *testing.B) { benchmarkLoad(b, true) }
+
+func TestBenchWorld(t *testing.T) {
+	registerBench()
+	w := newBenchWorld(4000, 300)
+	buf := new(bytes.Buffer)
+	if err := Save(w, buf); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := Load(bytes.NewBuffer(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2 := obj.(*benchWorld)
+	if len(w2.terrain) != 4000 || len(w2.entities) != 300 || len(w2.portals) != len(w.portals) {
+		t.Fatal("Bad world restore")
+	}
+	if w2.entities[7].target != w2.player || w2.player != w2.entities[0] {
+		t.Error("Bad shared pointer restore")
+	}
+	if s, ok := w2.entities[5].components[1].(*benchSprite); !ok || s.frame != 5 {
+		t.Error("Bad component restore")
+	}
+	if len(w2.entities[4].footprint) != 4 {
+		t.Error("Bad footprint restore")
+	}
+	// Thousands of cells should take a few bytes each, not tens of bytes of
+	// repeated type information.
+	if buf.Len() > 64*1024 {
+		t.Errorf("Save is %d bytes", buf.Len())
+	}
+}
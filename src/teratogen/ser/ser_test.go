@@ -19,6 +19,7 @@ package ser
 
 import (
 	"bytes"
+	"teratogen/space"
 	"testing"
 )
 
@@ -61,3 +62,149 @@ func TestCycleSer(t *testing.T) {
 		t.Error("Bad cycle value restore")
 	}
 }
+
+type located struct {
+	loc space.Location
+}
+
+func (l *located) Serialize(a Archive) error {
+	a.Visit(&l.loc.X, &l.loc.Y, &l.loc.Zone)
+	return nil
+}
+
+func TestLocationSer(t *testing.T) {
+	Register((*located)(nil))
+
+	for _, loc := range []space.Location{
+		space.Loc(space.MinCoord, space.MaxCoord, 1),
+		space.Loc(space.MaxCoord, space.MinCoord, 0xffff),
+		space.Loc(1000, -1000, 2)} {
+		out := bytes.NewBuffer(nil)
+		if err := Save(&located{loc}, out); err != nil {
+			t.Fatal(err)
+		}
+		obj, err := Load(bytes.NewBuffer(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := obj.(*located).loc; got != loc {
+			t.Errorf("Saved %s, loaded %s", loc, got)
+		}
+	}
+}
+
+type shape interface {
+	area() int
+}
+
+type square struct{ side int }
+
+func (s square) area() int { return s.side * s.side }
+
+type node struct {
+	name string
+	next *node
+}
+
+type everything struct {
+	loc      space.Location
+	grid     [2][2]uint8
+	list     []int
+	empty    []int
+	table    map[space.Location]string
+	shapes   []shape
+	noShape  shape
+	first    *node
+	second   *node
+	inner    *located
+	children []*linky
+}
+
+func (e *everything) Serialize(a Archive) error {
+	a.Visit(&e.loc, &e.grid, &e.list, &e.empty, &e.table, &e.shapes,
+		&e.noShape, &e.first, &e.second, &e.inner, &e.children)
+	return nil
+}
+
+func newEverything() *everything {
+	Register((*everything)(nil))
+	Register((*linky)(nil))
+	RegisterValue("square", square{})
+
+	shared := &node{name: "b"}
+	shared.next = &node{name: "a", next: shared}
+	leaf := &linky{val: 5}
+	return &everything{
+		loc:  space.Loc(-3, 4, 2),
+		grid: [2][2]uint8{{1, 2}, {3, 4}},
+		list: []int{7, 8, 9},
+		table: map[space.Location]string{
+			space.Loc(1, 0, 1): "x", space.Loc(0, 1, 1): "y", space.Loc(0, 0, 2): "z"},
+		shapes:   []shape{square{2}, &square{3}},
+		first:    shared,
+		second:   shared.next,
+		inner:    &located{space.Loc(5, 5, 5)},
+		children: []*linky{leaf, leaf},
+	}
+}
+
+// checkEverything checks that a loaded value matches newEverything.
+func checkEverything(t *testing.T, e *everything) {
+	if e.loc != space.Loc(-3, 4, 2) || e.grid != [2][2]uint8{{1, 2}, {3, 4}} {
+		t.Error("Bad struct or array restore")
+	}
+	if len(e.list) != 3 || e.list[2] != 9 || e.empty != nil {
+		t.Errorf("Bad slice restore %v %v", e.list, e.empty)
+	}
+	if len(e.table) != 3 || e.table[space.Loc(0, 1, 1)] != "y" {
+		t.Errorf("Bad map restore %v", e.table)
+	}
+	if len(e.shapes) != 2 || e.shapes[0].area() != 4 || e.shapes[1].area() != 9 ||
+		e.noShape != nil {
+		t.Errorf("Bad interface restore %v", e.shapes)
+	}
+	if _, ok := e.shapes[1].(*square); !ok {
+		t.Error("Interface pointer restored as value")
+	}
+	if e.first.next != e.second || e.second.next != e.first || e.first.name != "b" {
+		t.Error("Bad shared pointer restore")
+	}
+	if e.inner.loc != space.Loc(5, 5, 5) {
+		t.Error("Bad nested Serializable restore")
+	}
+	if e.children[0] != e.children[1] || e.children[0].val != 5 {
+		t.Error("Bad shared Serializable restore")
+	}
+}
+
+func TestReflectSer(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := Save(newEverything(), out); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := Load(bytes.NewBuffer(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEverything(t, obj.(*everything))
+}
+
+func TestMapOrder(t *testing.T) {
+	Register((*everything)(nil))
+	e := &everything{table: make(map[space.Location]string)}
+	for i := 0; i < 100; i++ {
+		e.table[space.Loc(int16(i%7), int16(i), 1)] = "x"
+	}
+	var first []byte
+	for i := 0; i < 10; i++ {
+		out := bytes.NewBuffer(nil)
+		if err := Save(e, out); err != nil {
+			t.Fatal(err)
+		}
+		if first == nil {
+			first = out.Bytes()
+		} else if !bytes.Equal(first, out.Bytes()) {
+			t.Fatal("Saving the same map produced different output")
+		}
+	}
+}
@@ -0,0 +1,488 @@
+// text.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// SaveText writes topValue and the objects it refers to as human-readable
+// JSON. The separately saved objects have their original address as "$tag",
+// which TagPointer values refer to with {"$tag": address}. Struct fields are
+// shown by name and the values visited by Serialize methods as lists under
+// "$visits". Shared pointers are objects with an
+// "$id" and a "$value" where they first appear and {"$ref": id} after
+// that. Interfaces show the registered name of their contents in "$type".
+func SaveText(topValue interface{}, output io.Writer) error {
+	w := &textWriter{}
+	w.push(&textFrame{})
+	if err := save(topValue, w); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(w.stack[0].list, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = output.Write(append(data, '\n'))
+	return err
+}
+
+// LoadText reads objects written by SaveText.
+func LoadText(input io.Reader) (interface{}, error) {
+	decoder := json.NewDecoder(input)
+	// Keep numbers exact instead of going through float64.
+	decoder.UseNumber()
+	var objects []interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, err
+	}
+	r := &textReader{}
+	r.push(&textFrame{list: objects})
+	return load(r)
+}
+
+// textFrame is a JSON value under construction or being read. Lists are
+// used for everything except structs, which use fields.
+type textFrame struct {
+	list   []interface{}
+	pos    int
+	fields map[string]interface{}
+	field  string
+	// finish makes the JSON value of a written frame.
+	finish func(f *textFrame) interface{}
+}
+
+// textWriter is a sink that builds a JSON value.
+type textWriter struct {
+	stack []*textFrame
+}
+
+func (w *textWriter) push(f *textFrame) { w.stack = append(w.stack, f) }
+
+func (w *textWriter) top() *textFrame { return w.stack[len(w.stack)-1] }
+
+// add adds a value to the current frame.
+func (w *textWriter) add(value interface{}) {
+	f := w.top()
+	if f.fields != nil {
+		f.fields[f.field] = value
+	} else {
+		f.list = append(f.list, value)
+	}
+}
+
+// begin starts a frame whose values are wrapped by finish.
+func (w *textWriter) begin(finish func(f *textFrame) interface{}) {
+	w.push(&textFrame{list: []interface{}{}, finish: finish})
+}
+
+// single returns the only value of a frame.
+func single(f *textFrame) interface{} { return f.list[0] }
+
+func (w *textWriter) beginObject(ptr uintptr, name string) {
+	w.begin(func(f *textFrame) interface{} {
+		return map[string]interface{}{"$tag": ptr, "$type": name, "$visits": f.list}
+	})
+}
+
+func (w *textWriter) beginVisits() {
+	w.begin(func(f *textFrame) interface{} {
+		return map[string]interface{}{"$visits": f.list}
+	})
+}
+
+func (w *textWriter) tag(ptr uintptr) {
+	w.add(map[string]interface{}{"$tag": ptr})
+}
+
+func (w *textWriter) storeGob(value interface{}) {
+	// Every value gets its own gob stream so that it can be read back
+	// alone.
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(value); err != nil {
+		panic(err)
+	}
+	w.add(map[string]interface{}{"$gob": buf.Bytes()})
+}
+
+func (w *textWriter) scalar(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		w.add(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.add(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		w.add(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		w.add(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		w.add([]interface{}{real(v.Complex()), imag(v.Complex())})
+	case reflect.String:
+		w.add(v.String())
+	default:
+		panic(fmt.Sprintf("Unhandled scalar type %s", v.Type()))
+	}
+}
+
+// JSON shows byte slices as base64 strings.
+
+func (w *textWriter) blob(data []byte) { w.add(data) }
+
+func (w *textWriter) byteSlice(data []byte) {
+	if data == nil {
+		w.add(nil)
+	} else {
+		w.add(data)
+	}
+}
+
+func (w *textWriter) beginStruct() {
+	w.push(&textFrame{
+		fields: make(map[string]interface{}),
+		finish: func(f *textFrame) interface{} { return f.fields }})
+}
+
+func (w *textWriter) field(name string) { w.top().field = name }
+
+func (w *textWriter) beginArray(n int) {
+	w.begin(func(f *textFrame) interface{} { return f.list })
+}
+
+func (w *textWriter) beginSlice(n int) {
+	if n < 0 {
+		w.add(nil)
+		return
+	}
+	w.begin(func(f *textFrame) interface{} { return f.list })
+}
+
+func (w *textWriter) beginMap(n int) {
+	if n < 0 {
+		w.add(nil)
+		return
+	}
+	// Show the keys and values of the map as pairs.
+	w.begin(func(f *textFrame) interface{} {
+		pairs := []interface{}{}
+		for i := 0; i < len(f.list); i += 2 {
+			pairs = append(pairs, []interface{}{f.list[i], f.list[i+1]})
+		}
+		return pairs
+	})
+}
+
+func (w *textWriter) pointer(id int, isNew bool) {
+	switch {
+	case id == 0:
+		w.add(nil)
+	case !isNew:
+		w.add(map[string]interface{}{"$ref": id})
+	default:
+		w.begin(func(f *textFrame) interface{} {
+			return map[string]interface{}{"$id": id, "$value": single(f)}
+		})
+	}
+}
+
+func (w *textWriter) beginInterface(name string, isPtr bool) {
+	if name == "" {
+		w.add(nil)
+		return
+	}
+	w.begin(func(f *textFrame) interface{} {
+		return map[string]interface{}{"$type": name, "$ptr": isPtr, "$value": single(f)}
+	})
+}
+
+func (w *textWriter) end() {
+	f := w.top()
+	w.stack = w.stack[:len(w.stack)-1]
+	w.add(f.finish(f))
+}
+
+func (w *textWriter) output() io.Writer { return nil }
+
+func (w *textWriter) flush() {}
+
+// textReader is a source that reads a JSON value decoded with numbers as
+// json.Number.
+type textReader struct {
+	stack []*textFrame
+	// pending is the value of a new pointer for enterPointer.
+	pending interface{}
+}
+
+func (r *textReader) push(f *textFrame) { r.stack = append(r.stack, f) }
+
+// next returns the next value of the current frame.
+func (r *textReader) next() interface{} {
+	f := r.stack[len(r.stack)-1]
+	if f.fields != nil {
+		value, ok := f.fields[f.field]
+		if !ok {
+			panic(fmt.Sprintf("Missing field %s", f.field))
+		}
+		return value
+	}
+	if f.pos >= len(f.list) {
+		panic("Missing value")
+	}
+	f.pos++
+	return f.list[f.pos-1]
+}
+
+// nextObject returns the next value, which must be a JSON object with the
+// given keys.
+func (r *textReader) nextObject(keys ...string) map[string]interface{} {
+	return textObject(r.next(), keys...)
+}
+
+// textObject checks that a value is a JSON object with the given keys.
+func textObject(value interface{}, keys ...string) map[string]interface{} {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		panic(fmt.Sprintf("Expected an object with %v, got %v", keys, value))
+	}
+	for _, key := range keys {
+		if _, ok := obj[key]; !ok {
+			panic(fmt.Sprintf("Missing %s in %v", key, value))
+		}
+	}
+	return obj
+}
+
+// enter starts reading the list of a frame.
+func (r *textReader) enter(value interface{}) {
+	list, ok := value.([]interface{})
+	if !ok {
+		panic(fmt.Sprintf("Expected a list, got %v", value))
+	}
+	r.push(&textFrame{list: list})
+}
+
+func (r *textReader) beginObject() (ptr uintptr, name string) {
+	obj := r.nextObject("$tag", "$type", "$visits")
+	ptr = uintptr(textUint(obj["$tag"]))
+	name = textString(obj["$type"])
+	r.enter(obj["$visits"])
+	return
+}
+
+func (r *textReader) beginVisits() {
+	r.enter(r.nextObject("$visits")["$visits"])
+}
+
+func (r *textReader) tag() uintptr {
+	value := r.next()
+	if value == nil {
+		return 0
+	}
+	return uintptr(textUint(textObject(value, "$tag")["$tag"]))
+}
+
+func (r *textReader) storeGob(value interface{}) {
+	data := textBytes(r.nextObject("$gob")["$gob"])
+	if err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(value); err != nil {
+		panic(err)
+	}
+}
+
+func (r *textReader) scalar(v reflect.Value) {
+	value := r.next()
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			panic(fmt.Sprintf("Expected a boolean, got %v", value))
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := textNumber(value).Int64()
+		if err != nil || v.OverflowInt(x) {
+			panic(fmt.Sprintf("Bad %s value %v", v.Type(), value))
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x := textUint(value)
+		if v.OverflowUint(x) {
+			panic(fmt.Sprintf("Bad %s value %v", v.Type(), value))
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(textFloat(value))
+	case reflect.Complex64, reflect.Complex128:
+		pair, ok := value.([]interface{})
+		if !ok || len(pair) != 2 {
+			panic(fmt.Sprintf("Expected a complex number pair, got %v", value))
+		}
+		v.SetComplex(complex(textFloat(pair[0]), textFloat(pair[1])))
+	case reflect.String:
+		v.SetString(textString(value))
+	default:
+		panic(fmt.Sprintf("Unhandled scalar type %s", v.Type()))
+	}
+}
+
+func (r *textReader) blob() []byte { return textBytes(r.next()) }
+
+func (r *textReader) byteSlice() []byte {
+	value := r.next()
+	if value == nil {
+		return nil
+	}
+	return textBytes(value)
+}
+
+func (r *textReader) beginStruct() {
+	value := r.next()
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		panic(fmt.Sprintf("Expected a struct, got %v", value))
+	}
+	r.push(&textFrame{fields: fields})
+}
+
+func (r *textReader) field(name string) {
+	r.stack[len(r.stack)-1].field = name
+}
+
+func (r *textReader) beginArray(n int) {
+	value := r.next()
+	if list, ok := value.([]interface{}); !ok || len(list) != n {
+		panic(fmt.Sprintf("Expected a list of %d values, got %v", n, value))
+	}
+	r.enter(value)
+}
+
+func (r *textReader) beginSlice() int {
+	value := r.next()
+	if value == nil {
+		return -1
+	}
+	r.enter(value)
+	return len(r.stack[len(r.stack)-1].list)
+}
+
+func (r *textReader) beginMap() int {
+	value := r.next()
+	if value == nil {
+		return -1
+	}
+	pairs, ok := value.([]interface{})
+	if !ok {
+		panic(fmt.Sprintf("Expected a list of pairs, got %v", value))
+	}
+	flat := []interface{}{}
+	for _, p := range pairs {
+		pair, ok := p.([]interface{})
+		if !ok || len(pair) != 2 {
+			panic(fmt.Sprintf("Expected a key and value pair, got %v", p))
+		}
+		flat = append(flat, pair...)
+	}
+	r.push(&textFrame{list: flat})
+	return len(pairs)
+}
+
+func (r *textReader) pointer() int {
+	value := r.next()
+	if value == nil {
+		return 0
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		panic(fmt.Sprintf("Expected a pointer, got %v", value))
+	}
+	if ref, ok := obj["$ref"]; ok {
+		return int(textUint(ref))
+	}
+	obj = textObject(value, "$id", "$value")
+	r.pending = obj["$value"]
+	return int(textUint(obj["$id"]))
+}
+
+func (r *textReader) enterPointer() {
+	r.push(&textFrame{list: []interface{}{r.pending}})
+}
+
+func (r *textReader) beginInterface() (name string, isPtr bool) {
+	value := r.next()
+	if value == nil {
+		return
+	}
+	obj := textObject(value, "$type", "$ptr", "$value")
+	name = textString(obj["$type"])
+	isPtr, ok := obj["$ptr"].(bool)
+	if !ok {
+		panic(fmt.Sprintf("Bad $ptr in %v", value))
+	}
+	r.push(&textFrame{list: []interface{}{obj["$value"]}})
+	return
+}
+
+func (r *textReader) end() {
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+func (r *textReader) input() io.Reader { return nil }
+
+func textNumber(value interface{}) json.Number {
+	n, ok := value.(json.Number)
+	if !ok {
+		panic(fmt.Sprintf("Expected a number, got %v", value))
+	}
+	return n
+}
+
+func textUint(value interface{}) uint64 {
+	var x uint64
+	if _, err := fmt.Sscan(string(textNumber(value)), &x); err != nil {
+		panic(fmt.Sprintf("Bad unsigned number %v", value))
+	}
+	return x
+}
+
+func textFloat(value interface{}) float64 {
+	x, err := textNumber(value).Float64()
+	if err != nil {
+		panic(fmt.Sprintf("Bad number %v", value))
+	}
+	return x
+}
+
+func textString(value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		panic(fmt.Sprintf("Expected a string, got %v", value))
+	}
+	return s
+}
+
+func textBytes(value interface{}) []byte {
+	data, err := base64.StdEncoding.DecodeString(textString(value))
+	if err != nil {
+		panic(fmt.Sprintf("Bad base64 data %v", value))
+	}
+	return data
+}
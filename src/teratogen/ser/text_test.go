@@ -0,0 +1,115 @@
+// text_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTextSer(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	if err := SaveText(newEverything(), out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, expect := range []string{`"$ref"`, `"$type": "square"`, `"name": "b"`} {
+		if !strings.Contains(text, expect) {
+			t.Errorf("Expected %s in text", expect)
+		}
+	}
+
+	obj, err := LoadText(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEverything(t, obj.(*everything))
+}
+
+func TestTextCycle(t *testing.T) {
+	Register((*linky)(nil))
+	cycle := &linky{val: 2}
+	cycle.other = &linky{other: cycle, val: 3}
+
+	out := bytes.NewBuffer(nil)
+	if err := SaveText(cycle, out); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := LoadText(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cycle2 := obj.(*linky)
+	if cycle2.other == nil || cycle2.other.other != cycle2 || cycle2.other.val != 3 {
+		t.Error("Bad cycle restore")
+	}
+}
+
+func TestDump(t *testing.T) {
+	c := NewContainer(2, "test")
+	c.Compress = true
+	save := bytes.NewBuffer(nil)
+	if err := c.Save(newEverything(), save); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := bytes.NewBuffer(nil)
+	if err := c.Dump(save, dump); err != nil {
+		t.Fatal(err)
+	}
+	// Hand-edit the dump.
+	edited := strings.Replace(dump.String(), `"name": "b"`, `"name": "c"`, 1)
+
+	save.Reset()
+	if err := c.Undump(strings.NewReader(edited), save); err != nil {
+		t.Fatal(err)
+	}
+	obj, header, err := c.Load(save)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != (Header{FormatVersion, 2, "test", true}) {
+		t.Errorf("Bad header %v", header)
+	}
+	e := obj.(*everything)
+	if e.first.name != "c" {
+		t.Error("Edit was lost")
+	}
+	e.first.name = "b"
+	checkEverything(t, e)
+}
+
+func TestBadText(t *testing.T) {
+	Register((*linky)(nil))
+	for _, text := range []string{
+		``,
+		`{}`,
+		`[{"$tag": 1, "$type": "linky", "$visits": []}]`,
+		`[{"$tag": 1, "$type": "linky", "$visits": ["x", null]}]`,
+		`[{"$tag": 1, "$type": "nonesuch", "$visits": [1, null]}]`,
+	} {
+		if _, err := LoadText(strings.NewReader(text)); err == nil {
+			t.Errorf("Loaded bad text %q", text)
+		}
+	}
+	if _, err := LoadText(strings.NewReader(
+		`[{"$tag": 1, "$type": "linky", "$visits": [4, null]}]`)); err != nil {
+		t.Error(err)
+	}
+}
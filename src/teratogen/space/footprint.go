@@ -76,12 +76,12 @@ func (ft *FootprintTemplate) Validate() error {
 	validParents := map[image.Point]bool{image.Pt(0, 0): true}
 	for _, e := range ft.steps {
 		if _, ok := validParents[e.parent]; !ok {
-			return errors.New(fmt.Sprintf("Unparented node %s", e))
+			return errors.New(fmt.Sprintf("Unparented node %v", e))
 		}
 		validParents[e.pos] = true
 
 		if tile.HexDist(e.parent, e.pos) != 1 {
-			return errors.New(fmt.Sprintf("Bad parent distance %s", e))
+			return errors.New(fmt.Sprintf("Bad parent distance %v", e))
 		}
 	}
 	return nil
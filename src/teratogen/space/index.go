@@ -19,13 +19,25 @@ package space
 
 import (
 	"image"
+	"teratogen/tile"
 )
 
 // Index is a spatial index for indexing single and multi cell entities in
 // space.
+//
+// All iteration over the index is deterministic. Entities are visited in the
+// order they were first placed in the index, and the entities at a single
+// location in the order they were placed there. The iteration callbacks may
+// remove the entity they are called with from the index, but must not make
+// other changes to it.
 type Index struct {
-	placement map[interface{}]Footprint
-	sites     map[Location]siteSet
+	entries map[interface{}]*indexEntry
+	sites   map[Location]*site
+	all     entryList
+	// Recycled site values.
+	freeSites []*site
+	// Counter for marking entities already visited by a query.
+	queryStamp uint64
 }
 
 func NewIndex() (result *Index) {
@@ -35,8 +47,10 @@ func NewIndex() (result *Index) {
 }
 
 func (s *Index) Init() {
-	s.placement = make(map[interface{}]Footprint)
-	s.sites = make(map[Location]siteSet)
+	s.entries = make(map[interface{}]*indexEntry)
+	s.sites = make(map[Location]*site)
+	s.all = entryList{kind: allList}
+	s.freeSites = nil
 }
 
 func (s *Index) Clear() {
@@ -44,80 +58,302 @@ func (s *Index) Clear() {
 }
 
 // Place places an entity with a custom, multi-cell footprint to the spatial
-// index. If the entity has been previously placed in the index, it is removed
-// before being placed into the new location.
+// index. If the entity has been previously placed in the index, it is moved
+// to the new location and keeps its place in the iteration order.
 func (s *Index) Place(
 	e interface{}, footprint Footprint) {
-	if _, ok := s.placement[e]; ok {
-		s.Remove(e)
+	entry, ok := s.entries[e]
+	if ok {
+		s.unsite(entry)
+	} else {
+		entry = &indexEntry{entity: e}
+		s.entries[e] = entry
+		s.all.pushBack(entry)
 	}
 
-	s.placement[e] = footprint
+	entry.footprint = footprint
+	entry.loc = footprint[image.Pt(0, 0)]
+
+	// Go through the footprint in a fixed order so that the site order stays
+	// deterministic when the footprint covers a location more than once.
+	entry.offsets = entry.offsets[:0]
+	for offset, _ := range footprint {
+		entry.offsets = append(entry.offsets, offset)
+	}
+	sortPoints(entry.offsets)
 
-	for offset, siteLoc := range footprint {
-		s.initSite(siteLoc)
-		s.sites[siteLoc][OffsetEntity{e, offset}] = true
+	if cap(entry.nodes) < len(entry.offsets) {
+		entry.nodes = make([]siteNode, len(entry.offsets))
+	}
+	entry.nodes = entry.nodes[:len(entry.offsets)]
+	for i, offset := range entry.offsets {
+		node := &entry.nodes[i]
+		*node = siteNode{entry: entry, offset: offset, loc: footprint[offset]}
+		s.site(node.loc).pushBack(node)
 	}
 }
 
 func (s *Index) Contains(e interface{}) bool {
-	_, ok := s.placement[e]
+	_, ok := s.entries[e]
 	return ok
 }
 
 func (s *Index) Loc(e interface{}) Location {
-	return s.placement[e][image.Pt(0, 0)]
+	if entry, ok := s.entries[e]; ok {
+		return entry.loc
+	}
+	return Location{}
+}
+
+// Footprint returns the footprint the entity was placed with.
+func (s *Index) Footprint(e interface{}) Footprint {
+	if entry, ok := s.entries[e]; ok {
+		return entry.footprint
+	}
+	return nil
+}
+
+// Len returns the number of entities in the index.
+func (s *Index) Len() int {
+	return len(s.entries)
 }
 
+// ForEach calls fn for every entity in the index.
 func (s *Index) ForEach(fn func(interface{})) {
-	for e, _ := range s.placement {
-		fn(e)
+	for entry := s.all.head; entry != nil; {
+		next := entry.links[allList].next
+		fn(entry.entity)
+		entry = next
 	}
 }
 
 func (s *Index) Remove(e interface{}) {
-	footprint, ok := s.placement[e]
+	entry, ok := s.entries[e]
 	if !ok {
 		panic("Removing an unknown entity from spatial index")
 	}
 
-top:
-	for _, loc := range footprint {
-		site := s.sites[loc]
-		for sited, _ := range site {
-			if sited.Entity == e {
-				delete(site, sited)
-				if len(site) == 0 {
-					delete(s.sites, loc)
-				}
-				continue top
-			}
-		}
-		panic("Entity not found on site belonging to footprint.")
-	}
-	delete(s.placement, e)
+	s.unsite(entry)
+	s.all.remove(entry)
+	delete(s.entries, e)
 }
 
+// At returns the entities at a location. Use EachAt to avoid allocating the
+// result slice.
 func (s *Index) At(loc Location) (result []OffsetEntity) {
+	s.EachAt(loc, func(oe OffsetEntity) {
+		result = append(result, oe)
+	})
+	return
+}
+
+// EachAt calls fn for every entity at a location.
+func (s *Index) EachAt(loc Location, fn func(OffsetEntity)) {
 	site, ok := s.sites[loc]
 	if !ok {
 		return
 	}
-	for elt, _ := range site {
-		result = append(result, elt)
+	for node := site.head; node != nil; {
+		next := node.next
+		fn(OffsetEntity{node.entry.entity, node.offset})
+		node = next
 	}
-	return
 }
 
-func (s *Index) initSite(loc Location) {
-	if _, ok := s.sites[loc]; !ok {
-		s.sites[loc] = make(siteSet)
+// InRect calls fn once for every entity that has a footprint cell in the
+// given zone within the rectangle. Portals are not taken into account.
+func (s *Index) InRect(zone uint16, rect image.Rectangle, fn func(interface{})) {
+	s.query(zone, rect, func(Location) bool { return true }, fn)
+}
+
+// InRadius calls fn once for every entity that has a footprint cell within
+// radius hex steps of the center location. Portals are not taken into
+// account, use a field of view to find entities that are near through
+// portals.
+func (s *Index) InRadius(center Location, radius int, fn func(interface{})) {
+	c := image.Pt(int(center.X), int(center.Y))
+	rect := image.Rectangle{c, c.Add(image.Pt(1, 1))}.Inset(-radius)
+	s.query(center.Zone, rect, func(loc Location) bool {
+		return tile.HexDist(c, image.Pt(int(loc.X), int(loc.Y))) <= radius
+	}, fn)
+}
+
+// query visits the entities with a footprint cell in the rectangle for which
+// accept returns true. Depending on which is cheaper, it either scans the
+// locations in the rectangle or the footprints of all the entities.
+func (s *Index) query(zone uint16, rect image.Rectangle, accept func(Location) bool, fn func(interface{})) {
+	rect = rect.Intersect(image.Rect(MinCoord, MinCoord, MaxCoord+1, MaxCoord+1))
+	s.queryStamp++
+	stamp := s.queryStamp
+
+	if rect.Dx()*rect.Dy() <= len(s.sites) {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				loc := Location{int16(x), int16(y), zone}
+				site, ok := s.sites[loc]
+				if !ok || !accept(loc) {
+					continue
+				}
+				for node := site.head; node != nil; {
+					next := node.next
+					if node.entry.stamp != stamp {
+						node.entry.stamp = stamp
+						fn(node.entry.entity)
+					}
+					node = next
+				}
+			}
+		}
+		return
+	}
+
+	for entry := s.all.head; entry != nil; {
+		next := entry.links[allList].next
+		for i := range entry.nodes {
+			loc := entry.nodes[i].loc
+			if loc.Zone == zone && image.Pt(int(loc.X), int(loc.Y)).In(rect) && accept(loc) {
+				fn(entry.entity)
+				break
+			}
+		}
+		entry = next
+	}
+}
+
+// unsite removes the entry from the sites of its footprint.
+func (s *Index) unsite(entry *indexEntry) {
+	for i := range entry.nodes {
+		node := &entry.nodes[i]
+		site := s.sites[node.loc]
+		site.remove(node)
+		if site.head == nil {
+			delete(s.sites, node.loc)
+			s.freeSites = append(s.freeSites, site)
+		}
 	}
 }
 
-type siteSet map[OffsetEntity]bool
+func (s *Index) site(loc Location) *site {
+	if result, ok := s.sites[loc]; ok {
+		return result
+	}
+	var result *site
+	if n := len(s.freeSites); n > 0 {
+		result = s.freeSites[n-1]
+		s.freeSites = s.freeSites[:n-1]
+	} else {
+		result = new(site)
+	}
+	s.sites[loc] = result
+	return result
+}
 
 type OffsetEntity struct {
 	Entity interface{}
 	Offset image.Point
 }
+
+// indexEntry is the index data for a single entity. The entries are linked
+// into the list of all entries.
+type indexEntry struct {
+	entity    interface{}
+	loc       Location
+	footprint Footprint
+	offsets   []image.Point
+	nodes     []siteNode
+	links     [numLists]entryLinks
+	stamp     uint64
+}
+
+const (
+	allList = iota
+	numLists
+)
+
+type entryLinks struct {
+	prev, next *indexEntry
+}
+
+type entryList struct {
+	head, tail *indexEntry
+	kind       int
+}
+
+func (l *entryList) pushBack(e *indexEntry) {
+	e.links[l.kind] = entryLinks{prev: l.tail}
+	if l.tail != nil {
+		l.tail.links[l.kind].next = e
+	} else {
+		l.head = e
+	}
+	l.tail = e
+}
+
+func (l *entryList) remove(e *indexEntry) {
+	links := &e.links[l.kind]
+	if links.prev != nil {
+		links.prev.links[l.kind].next = links.next
+	} else {
+		l.head = links.next
+	}
+	if links.next != nil {
+		links.next.links[l.kind].prev = links.prev
+	} else {
+		l.tail = links.prev
+	}
+	*links = entryLinks{}
+}
+
+// siteNode is a single footprint cell of an entity, linked into the list of
+// the cells at the same location.
+type siteNode struct {
+	entry      *indexEntry
+	offset     image.Point
+	loc        Location
+	prev, next *siteNode
+}
+
+type site struct {
+	head, tail *siteNode
+}
+
+func (s *site) pushBack(node *siteNode) {
+	node.prev, node.next = s.tail, nil
+	if s.tail != nil {
+		s.tail.next = node
+	} else {
+		s.head = node
+	}
+	s.tail = node
+}
+
+func (s *site) remove(node *siteNode) {
+	if node.prev != nil {
+		node.prev.next = node.next
+	} else {
+		s.head = node.next
+	}
+	if node.next != nil {
+		node.next.prev = node.prev
+	} else {
+		s.tail = node.prev
+	}
+	node.prev, node.next = nil, nil
+}
+
+// sortPoints sorts points by row and then by column. Footprints are small,
+// so insertion sort does fine.
+func sortPoints(points []image.Point) {
+	for i := 1; i < len(points); i++ {
+		for j := i; j > 0 && pointLess(points[j], points[j-1]); j-- {
+			points[j], points[j-1] = points[j-1], points[j]
+		}
+	}
+}
+
+func pointLess(a, b image.Point) bool {
+	if a.Y != b.Y {
+		return a.Y < b.Y
+	}
+	return a.X < b.X
+}
@@ -0,0 +1,162 @@
+// index_bench_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package space
+
+import (
+	"image"
+	"testing"
+)
+
+// mapIndex is the earlier map based spatial index, kept around to compare
+// against.
+type mapIndex struct {
+	placement map[interface{}]Footprint
+	sites     map[Location]map[OffsetEntity]bool
+}
+
+func newMapIndex() *mapIndex {
+	return &mapIndex{
+		make(map[interface{}]Footprint),
+		make(map[Location]map[OffsetEntity]bool)}
+}
+
+func (s *mapIndex) Place(e interface{}, footprint Footprint) {
+	if _, ok := s.placement[e]; ok {
+		s.Remove(e)
+	}
+	s.placement[e] = footprint
+	for offset, siteLoc := range footprint {
+		if _, ok := s.sites[siteLoc]; !ok {
+			s.sites[siteLoc] = make(map[OffsetEntity]bool)
+		}
+		s.sites[siteLoc][OffsetEntity{e, offset}] = true
+	}
+}
+
+func (s *mapIndex) Remove(e interface{}) {
+top:
+	for _, loc := range s.placement[e] {
+		site := s.sites[loc]
+		for sited, _ := range site {
+			if sited.Entity == e {
+				delete(site, sited)
+				if len(site) == 0 {
+					delete(s.sites, loc)
+				}
+				continue top
+			}
+		}
+	}
+	delete(s.placement, e)
+}
+
+func (s *mapIndex) At(loc Location) (result []OffsetEntity) {
+	for elt, _ := range s.sites[loc] {
+		result = append(result, elt)
+	}
+	return
+}
+
+type benchIndex interface {
+	Place(e interface{}, footprint Footprint)
+	Remove(e interface{})
+	At(loc Location) []OffsetEntity
+}
+
+const benchEntities = 256
+
+var bigTemplate = ForceTemplate([]image.Point{
+	{-1, -1}, {0, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 0}})
+
+func benchLoc(i int) Location {
+	return Loc(int16(i%64), int16(i/64%64), 1)
+}
+
+func populate(idx benchIndex) (entities []*testMob) {
+	for i := 0; i < benchEntities; i++ {
+		e := &testMob{}
+		entities = append(entities, e)
+		idx.Place(e, SimpleFootprint(benchLoc(i*7)))
+	}
+	return
+}
+
+func benchMove(b *testing.B, idx benchIndex) {
+	entities := populate(idx)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Place(entities[i%benchEntities], SimpleFootprint(benchLoc(i)))
+	}
+}
+
+func benchMoveBig(b *testing.B, idx benchIndex) {
+	spc := NewManifold()
+	populate(idx)
+	big := &testMob{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Place(big, spc.MakeFootprint(bigTemplate, benchLoc(i)))
+	}
+}
+
+func benchAt(b *testing.B, idx benchIndex) {
+	populate(idx)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.At(benchLoc(i))
+	}
+}
+
+func benchChurn(b *testing.B, idx benchIndex) {
+	entities := populate(idx)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := entities[i%benchEntities]
+		idx.Remove(e)
+		idx.Place(e, SimpleFootprint(benchLoc(i)))
+	}
+}
+
+func BenchmarkIndexMove(b *testing.B)       { benchMove(b, NewIndex()) }
+func BenchmarkMapIndexMove(b *testing.B)    { benchMove(b, newMapIndex()) }
+func BenchmarkIndexMoveBig(b *testing.B)    { benchMoveBig(b, NewIndex()) }
+func BenchmarkMapIndexMoveBig(b *testing.B) { benchMoveBig(b, newMapIndex()) }
+func BenchmarkIndexAt(b *testing.B)         { benchAt(b, NewIndex()) }
+func BenchmarkMapIndexAt(b *testing.B)      { benchAt(b, newMapIndex()) }
+func BenchmarkIndexChurn(b *testing.B)      { benchChurn(b, NewIndex()) }
+func BenchmarkMapIndexChurn(b *testing.B)   { benchChurn(b, newMapIndex()) }
+
+func BenchmarkIndexEachAt(b *testing.B) {
+	idx := NewIndex()
+	populate(idx)
+	fn := func(OffsetEntity) {}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.EachAt(benchLoc(i), fn)
+	}
+}
+
+func BenchmarkIndexInRadius(b *testing.B) {
+	idx := NewIndex()
+	populate(idx)
+	fn := func(interface{}) {}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.InRadius(benchLoc(i), 4, fn)
+	}
+}
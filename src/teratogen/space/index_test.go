@@ -0,0 +1,148 @@
+// index_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package space
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+type testMob struct{ name string }
+
+type testItem struct{ name string }
+
+func collect(iter func(func(interface{}))) (result []interface{}) {
+	iter(func(e interface{}) { result = append(result, e) })
+	return
+}
+
+func TestIndexOrder(t *testing.T) {
+	idx := NewIndex()
+	a, b, c := &testMob{"a"}, &testMob{"b"}, &testMob{"c"}
+	loc, other := Loc(1, 1, 1), Loc(2, 1, 1)
+
+	for _, e := range []interface{}{a, b, c} {
+		idx.Place(e, SimpleFootprint(loc))
+	}
+	if !reflect.DeepEqual(idx.At(loc), []OffsetEntity{{a, image.Pt(0, 0)}, {b, image.Pt(0, 0)}, {c, image.Pt(0, 0)}}) {
+		t.Errorf("Bad site order %v", idx.At(loc))
+	}
+
+	// Moving keeps the overall order but goes to the end of the site.
+	idx.Place(b, SimpleFootprint(other))
+	idx.Place(b, SimpleFootprint(loc))
+	if at := idx.At(loc); len(at) != 3 || at[2].Entity != b {
+		t.Errorf("Bad site order after move %v", at)
+	}
+	if !reflect.DeepEqual(collect(idx.ForEach), []interface{}{a, b, c}) {
+		t.Error("Moving changed the iteration order")
+	}
+	if len(idx.At(other)) != 0 {
+		t.Error("Entity left behind at old location")
+	}
+
+	idx.Remove(b)
+	if !reflect.DeepEqual(collect(idx.ForEach), []interface{}{a, c}) {
+		t.Error("Bad iteration order after remove")
+	}
+	if idx.Contains(b) || idx.Len() != 2 || len(idx.At(loc)) != 2 {
+		t.Error("Entity not removed")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Removing unknown entity didn't panic")
+			}
+		}()
+		idx.Remove(b)
+	}()
+}
+
+func TestIndexFootprint(t *testing.T) {
+	spc := NewManifold()
+	template := ForceTemplate([]image.Point{{1, 0}, {0, 1}})
+	idx := NewIndex()
+	big := &testMob{"big"}
+
+	idx.Place(big, spc.MakeFootprint(template, Loc(0, 0, 1)))
+	if at := idx.At(Loc(1, 0, 1)); len(at) != 1 || at[0] != (OffsetEntity{big, image.Pt(1, 0)}) {
+		t.Errorf("Bad footprint cell %v", at)
+	}
+	if idx.Loc(big) != Loc(0, 0, 1) {
+		t.Fail()
+	}
+
+	idx.Place(big, spc.MakeFootprint(template, Loc(5, 5, 1)))
+	if len(idx.At(Loc(1, 0, 1))) != 0 || len(idx.At(Loc(5, 6, 1))) != 1 {
+		t.Error("Footprint not moved")
+	}
+
+	idx.Remove(big)
+	if len(idx.At(Loc(5, 6, 1))) != 0 {
+		t.Error("Footprint not removed")
+	}
+}
+
+func TestIndexRemoveWhileIterating(t *testing.T) {
+	idx := NewIndex()
+	loc := Loc(0, 0, 1)
+	for i := 0; i < 4; i++ {
+		idx.Place(&testMob{}, SimpleFootprint(loc))
+	}
+	idx.EachAt(loc, func(oe OffsetEntity) { idx.Remove(oe.Entity) })
+	if idx.Len() != 0 {
+		t.Error("Entities left after removing all at site")
+	}
+}
+
+func TestIndexQueries(t *testing.T) {
+	idx := NewIndex()
+	for x := int16(0); x < 10; x++ {
+		idx.Place(&testMob{}, SimpleFootprint(Loc(x, 0, 1)))
+		idx.Place(&testItem{}, SimpleFootprint(Loc(x, 1, 1)))
+	}
+	idx.Place(&testMob{}, SimpleFootprint(Loc(0, 0, 2)))
+
+	if n := len(collect(func(fn func(interface{})) { idx.InRect(1, image.Rect(2, 0, 4, 1), fn) })); n != 2 {
+		t.Errorf("Small rect query found %d entities", n)
+	}
+	// Large enough to go through the entities instead of the locations.
+	if n := len(collect(func(fn func(interface{})) { idx.InRect(1, image.Rect(-100, -100, 5, 100), fn) })); n != 10 {
+		t.Errorf("Large rect query found %d entities", n)
+	}
+	if n := len(collect(func(fn func(interface{})) { idx.InRadius(Loc(5, 0, 1), 1, fn) })); n != 5 {
+		// (4, 0), (5, 0), (6, 0), (5, 1) and (6, 1). (4, 1) is two steps
+		// away on the hex grid.
+		t.Errorf("Radius query found %d entities", n)
+	}
+}
+
+func TestIndexAllocs(t *testing.T) {
+	idx := NewIndex()
+	loc := Loc(0, 0, 1)
+	for i := 0; i < 8; i++ {
+		idx.Place(&testMob{}, SimpleFootprint(loc))
+	}
+	count := 0
+	fn := func(OffsetEntity) { count++ }
+	if n := testing.AllocsPerRun(100, func() { idx.EachAt(loc, fn) }); n != 0 {
+		t.Errorf("EachAt allocates %f times", n)
+	}
+}
@@ -21,6 +21,7 @@ package space
 import (
 	"fmt"
 	"image"
+	"math"
 )
 
 // Location is a single point in space. Zone value 0 denotes inactive portals.
@@ -29,10 +30,22 @@ import (
 // value Location{0, 0, 0} means "no place" and can be used to denote an
 // invalid location.
 type Location struct {
-	X, Y int8
+	X, Y int16
 	Zone uint16
 }
 
+// The range of the X and Y coordinates of locations.
+const (
+	MinCoord = math.MinInt16
+	MaxCoord = math.MaxInt16
+)
+
+// InRange returns whether the coordinates fit in the location coordinate
+// range.
+func InRange(x, y int) bool {
+	return x >= MinCoord && x <= MaxCoord && y >= MinCoord && y <= MaxCoord
+}
+
 // Portal has the same structure as a location, but it's X and Y fields
 // indicate relative displacement caused by moving through the portal and the
 // Zone (absolute value, unlike X and Y) indicates the zone where the portal
@@ -40,14 +53,32 @@ type Location struct {
 type Portal Location
 
 // Add returns a location translated by a vector. Does not know about portals.
+// Panics if the result falls outside the coordinate range.
 func (loc Location) Add(vec image.Point) Location {
-	return Location{loc.X + int8(vec.X), loc.Y + int8(vec.Y), loc.Zone}
+	result, ok := loc.TryAdd(vec)
+	if !ok {
+		panic(fmt.Sprintf("Location %s moved by %s is out of range", loc, vec))
+	}
+	return result
+}
+
+// TryAdd returns a location translated by a vector and true, or the original
+// location and false if the result would fall outside the coordinate range.
+func (loc Location) TryAdd(vec image.Point) (Location, bool) {
+	x, y := int(loc.X)+vec.X, int(loc.Y)+vec.Y
+	if !InRange(x, y) {
+		return loc, false
+	}
+	return Location{int16(x), int16(y), loc.Zone}, true
 }
 
 // Beyond returns the location beyond the given portal from the current location.
+// Panics if the result falls outside the coordinate range.
 func (loc Location) Beyond(portal Portal) Location {
 	if portal.Zone != 0 {
-		return Location{loc.X + portal.X, loc.Y + portal.Y, portal.Zone}
+		result := loc.Add(image.Pt(int(portal.X), int(portal.Y)))
+		result.Zone = portal.Zone
+		return result
 	}
 	return loc
 }
@@ -67,12 +98,12 @@ func NullPortal() Portal {
 }
 
 // Loc is a convenience function for creating location values.
-func Loc(x, y int8, zone uint16) Location {
+func Loc(x, y int16, zone uint16) Location {
 	return Location{x, y, zone}
 }
 
 // Port is a convenience function for creating portal values.
-func Port(dx, dy int8, targetZone uint16) Portal {
+func Port(dx, dy int16, targetZone uint16) Portal {
 	return Portal{dx, dy, targetZone}
 }
 
@@ -111,9 +142,13 @@ func NewManifold() *Manifold {
 // Offset returns a portaled location the vector away from the initial one.
 // Only the portal exactly the vector's span away from the initial location
 // matters; you will probably mostly want to use this with unit length
-// vectors.
+// vectors. Offsets beyond the edge of the coordinate range lead to the
+// undefined location.
 func (m *Manifold) Offset(loc Location, vec image.Point) (newLoc Location) {
-	return m.Traverse(loc.Add(vec))
+	if newLoc, ok := loc.TryAdd(vec); ok {
+		return m.Traverse(newLoc)
+	}
+	return Location{}
 }
 
 // Traverse returns the location beyond a portal at the argument location, if
@@ -133,15 +168,33 @@ func (m *Manifold) Portal(loc Location) Portal {
 
 // SetPortal sets the portal at the given location. If the portal value equals
 // NullPortal, the explicit portal will be cleared from the manifold data
-// structure.
+// structure. Panics if the portal leads outside the coordinate range.
 func (m *Manifold) SetPortal(loc Location, portal Portal) {
 	if portal == NullPortal() {
 		delete(m.portals, loc)
-	} else {
-		m.portals[loc] = portal
+		return
+	}
+
+	if _, ok := loc.TryAdd(image.Pt(int(portal.X), int(portal.Y))); !ok {
+		panic(fmt.Sprintf("Portal %v at %s leads out of coordinate range", portal, loc))
 	}
+	m.portals[loc] = portal
 }
 
 func (m *Manifold) SetPortalTo(loc, targetLoc Location) {
-	m.SetPortal(loc, Port(targetLoc.X-loc.X, targetLoc.Y-loc.Y, targetLoc.Zone))
+	dx, dy := int(targetLoc.X)-int(loc.X), int(targetLoc.Y)-int(loc.Y)
+	if !InRange(dx, dy) {
+		panic(fmt.Sprintf("Portal from %s to %s is too long", loc, targetLoc))
+	}
+	m.SetPortal(loc, Port(int16(dx), int16(dy), targetLoc.Zone))
+}
+
+// RemovePortals clears the portals at every location for which pred returns
+// true.
+func (m *Manifold) RemovePortals(pred func(Location) bool) {
+	for loc, _ := range m.portals {
+		if pred(loc) {
+			delete(m.portals, loc)
+		}
+	}
 }
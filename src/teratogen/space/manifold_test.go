@@ -98,3 +98,79 @@ func TestFootprint(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCoordinateRange(t *testing.T) {
+	spc := NewManifold()
+
+	// Coordinates beyond the old 8-bit range.
+	far := Loc(1000, -1000, 1)
+	if far.Add(image.Pt(-1000, 1000)) != Loc(0, 0, 1) {
+		t.Fail()
+	}
+
+	edge := Loc(MaxCoord, MinCoord, 1)
+	if _, ok := edge.TryAdd(image.Pt(1, 0)); ok {
+		t.Error("Overflow not detected")
+	}
+	if _, ok := edge.TryAdd(image.Pt(0, -1)); ok {
+		t.Error("Underflow not detected")
+	}
+	if loc, ok := edge.TryAdd(image.Pt(-1, 1)); !ok || loc != Loc(MaxCoord-1, MinCoord+1, 1) {
+		t.Fail()
+	}
+
+	// Stepping off the edge leads nowhere instead of wrapping around.
+	if spc.Offset(edge, image.Pt(1, 0)) != (Location{}) {
+		t.Error("Offset wrapped around the coordinate range")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Add past coordinate range didn't panic")
+			}
+		}()
+		edge.Add(image.Pt(1, 0))
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Portal past coordinate range didn't panic")
+			}
+		}()
+		spc.SetPortalTo(Loc(MinCoord, 0, 1), Loc(MaxCoord, 0, 2))
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Portal set past coordinate range didn't panic")
+			}
+		}()
+		spc.SetPortal(edge, Port(1, 0, 2))
+	}()
+	spc.SetPortal(edge, NullPortal())
+
+	// Long portals within range work.
+	spc.SetPortalTo(Loc(MinCoord/2, 0, 1), Loc(MaxCoord/2, 0, 2))
+	if spc.Offset(Loc(MinCoord/2-1, 0, 1), image.Pt(1, 0)) != Loc(MaxCoord/2, 0, 2) {
+		t.Fail()
+	}
+
+	// Footprints at the edge stay in range.
+	template := ForceTemplate([]image.Point{{1, 0}, {-1, 0}})
+	footprint := spc.MakeFootprint(template, Loc(MaxCoord, 0, 1))
+	if footprint[image.Pt(-1, 0)] != Loc(MaxCoord-1, 0, 1) {
+		t.Fail()
+	}
+	if footprint[image.Pt(1, 0)] != (Location{}) {
+		t.Error("Footprint wrapped around the coordinate range")
+	}
+
+	idx := NewIndex()
+	idx.Place("edge", footprint)
+	if len(idx.At(Loc(MaxCoord-1, 0, 1))) != 1 || idx.Loc("edge") != Loc(MaxCoord, 0, 1) {
+		t.Fail()
+	}
+}
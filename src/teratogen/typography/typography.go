@@ -22,10 +22,9 @@ import (
 	"image"
 	"image/color"
 	"strings"
-	"teratogen/app"
+	"teratogen/cache"
 	"teratogen/font"
 	"teratogen/gfx"
-	"teratogen/sdl"
 )
 
 type Style struct {
@@ -37,7 +36,7 @@ type Style struct {
 
 func NewStyle(fontSpec font.Spec) *Style {
 	return &Style{
-		font:      app.Cache().GetFont(fontSpec),
+		font:      cache.Get().GetFont(fontSpec),
 		edge:      None,
 		foreColor: gfx.White,
 		backColor: gfx.Black}
@@ -90,7 +89,7 @@ func (s *Style) StringWidth(line string) float64 {
 }
 
 func (s *Style) Render(line string, pos image.Point) {
-	s.RenderOn(sdl.Frame(), line, pos)
+	s.RenderOn(gfx.Frame(), line, pos)
 }
 
 func (s *Style) Bounds(line string) image.Rectangle {
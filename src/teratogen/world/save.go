@@ -0,0 +1,65 @@
+// save.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package world
+
+import (
+	"teratogen/entity"
+	"teratogen/ser"
+	"teratogen/space"
+)
+
+func init() {
+	ser.Register((*World)(nil))
+}
+
+// SaveVersion is the version of the game's saved data. Bump it when changes
+// to the saved types break old saves, and add a migration from the old
+// version in SaveContainer.
+const SaveVersion = 1
+
+// SaveContainer returns the container for reading and writing the game's
+// save files. The game version is written in the saves.
+func SaveContainer(gameVersion string) *ser.Container {
+	return ser.NewContainer(SaveVersion, gameVersion)
+}
+
+// Serialize saves the world. The spatial index isn't saved as such, only the
+// locations of the entities in it, and it is rebuilt when loading.
+func (w *World) Serialize(a ser.Archive) error {
+	placed := map[entity.ID]space.Location{}
+	if !a.Loading() {
+		w.Entities.Each(func(id entity.ID) {
+			if w.Spatial.Contains(id) {
+				placed[id] = w.Spatial.Loc(id)
+			}
+		})
+	}
+
+	a.Visit(&w.Manifold, &w.terrain, &w.terrainRevision, &w.Entities,
+		&w.FloorExit, &w.Player, &placed)
+
+	if a.Loading() {
+		w.Spatial = space.NewIndex()
+		w.Entities.Each(func(id entity.ID) {
+			if loc, ok := placed[id]; ok {
+				w.Place(id, loc)
+			}
+		})
+	}
+	return nil
+}
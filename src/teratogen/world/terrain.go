@@ -17,15 +17,12 @@
 
 package world
 
-import (
-	"teratogen/display/util"
-	"teratogen/gfx"
-)
-
 type Terrain uint8
 
 type TerrainData struct {
-	Icon []gfx.ImageSpec
+	// Name of the terrain's sprite. Terrain that has variants, like walls,
+	// has one frame per variant.
+	Icon string
 	Kind TerrainKind
 }
 
@@ -44,6 +41,12 @@ func (t TerrainData) ShapesWalls() bool {
 	return t.Kind == WallKind || t.Kind == DoorKind
 }
 
+// IsFlat returns whether the terrain is drawn flat on the ground, so that it
+// never covers things standing on the neighboring cells.
+func (t TerrainData) IsFlat() bool {
+	return t.Kind == OpenKind
+}
+
 func (t TerrainData) BlocksSight() bool {
 	switch t.Kind {
 	case SolidKind, WallKind, DoorKind:
@@ -87,15 +90,15 @@ func GetTerrainData(t Terrain) TerrainData {
 }
 
 var terrainTable = []TerrainData{
-	{util.SmallIcons(util.Tiles, 3), SolidKind}, // void terrain, should have some "you shouldn't be seeing this" icon
-	{util.IsoIcons(util.Tiles, 5), OpenKind},
-	{util.IsoIcons(util.Tiles, 1, 2, 3, 4), WallKind},
-	{util.IsoIcons(util.Tiles, 7, 8, 9, 7), DoorKind},
-	{util.IsoIcons(util.Tiles, 6), OpenKind},
+	{"void", SolidKind}, // void terrain, should have some "you shouldn't be seeing this" icon
+	{"floor", OpenKind},
+	{"wall", WallKind},
+	{"door", DoorKind},
+	{"stairs", OpenKind},
 
-	{util.IsoIcons(util.Tiles, 10), ObstacleKind},
-	{util.IsoIcons(util.Tiles, 11), GrillKind},
-	{util.IsoIcons(util.Tiles, 12), OpenKind},
-	{util.IsoIcons(util.Tiles, 13), GrillKind},
-	{util.IsoIcons(util.Tiles, 14), OpenKind},
+	{"barrel", ObstacleKind},
+	{"shelf", GrillKind},
+	{"chair", OpenKind},
+	{"counter", GrillKind},
+	{"plant", OpenKind},
 }
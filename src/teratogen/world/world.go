@@ -20,27 +20,23 @@
 package world
 
 import (
+	"image"
 	"teratogen/entity"
-	"teratogen/gfx"
 	"teratogen/space"
 )
 
 type World struct {
 	Manifold *space.Manifold
 	terrain  map[space.Location]Terrain
+	// Counts the changes to terrain.
+	terrainRevision int
+	// Spatial index of entity IDs.
 	Spatial  *space.Index
-	Floor    int
+	Entities *entity.Registry
 	// Exit location from the last floor map generated
 	FloorExit space.Location
-	// Actor queue for the current frame
-	actors []entity.Entity
-	// Actor queue for the next frame
-	nextActors []entity.Entity
-
-	Player interface {
-		gfx.Spritable
-		entity.Fov
-	}
+
+	Player entity.ID
 }
 
 func New() (world *World) {
@@ -48,8 +44,7 @@ func New() (world *World) {
 	world.Manifold = space.NewManifold()
 	world.terrain = make(map[space.Location]Terrain)
 	world.Spatial = space.NewIndex()
-	world.actors = []entity.Entity{}
-	world.nextActors = []entity.Entity{}
+	world.Entities = entity.NewRegistry()
 	return
 }
 
@@ -61,16 +56,27 @@ func (w *World) Terrain(loc space.Location) TerrainData {
 }
 
 func (w *World) SetTerrain(loc space.Location, t Terrain) {
-	w.terrain[loc] = t
+	if old, ok := w.terrain[loc]; !ok || old != t {
+		w.terrain[loc] = t
+		w.terrainRevision++
+	}
 }
 
 func (w *World) ClearTerrain() {
 	w.terrain = make(map[space.Location]Terrain)
+	w.terrainRevision++
+}
+
+// TerrainRevision returns a number that changes whenever the terrain
+// changes.
+func (w *World) TerrainRevision() int {
+	return w.terrainRevision
 }
 
 func (w *World) Clear() {
 	w.ClearTerrain()
 	w.Spatial.Clear()
+	w.Entities.Init()
 }
 
 func (w *World) Contains(loc space.Location) bool {
@@ -78,49 +84,31 @@ func (w *World) Contains(loc space.Location) bool {
 	return ok
 }
 
-func (w *World) AddActor(obj entity.Entity) {
-	w.actors = append(w.actors, obj)
-}
-
-func (w *World) IsAlive(obj entity.Entity) bool {
-	return w.Spatial.Contains(obj)
+func (w *World) IsAlive(id entity.ID) bool {
+	return w.Spatial.Contains(id)
 }
 
-// NextActor returns the next living actor that has still to act this turn.
-func (w *World) NextActor() entity.Entity {
-	if len(w.actors) == 0 {
-		return nil
-	}
-	actor := w.actors[0]
-	w.actors = w.actors[1:]
-	if !w.IsAlive(actor) {
-		// Drop actors that don't exist in the world.
-		return w.NextActor()
+// Footprint returns the footprint the entity would have at the location.
+func (w *World) Footprint(id entity.ID, loc space.Location) space.Footprint {
+	if body, ok := w.Entities.Body(id); ok && body.Footprint != nil {
+		return w.Manifold.MakeFootprint(body.Footprint, loc)
 	}
-	// Otherwise move the actor to the back of the queue and return it.
-	w.nextActors = append(w.nextActors, actor)
-	return actor
+	return space.Footprint{image.Pt(0, 0): loc}
 }
 
-// EndTurn refreshes the actor queue for a new game turn.
-func (w *World) EndTurn() {
-	// Add remaining live actors to the next actor queue.
-	for _, a := range w.actors {
-		if w.IsAlive(a) {
-			w.nextActors = append(w.nextActors, a)
-		}
-	}
-	w.actors = w.nextActors
-	w.nextActors = []entity.Entity{}
+// BlocksMove returns whether the entity is solid.
+func (w *World) BlocksMove(id entity.ID) bool {
+	body, ok := w.Entities.Body(id)
+	return ok && body.BlocksMove
 }
 
-func (w *World) Fits(obj entity.Entity, loc space.Location) bool {
-	for _, footLoc := range w.Manifold.FootprintFor(obj, loc) {
+func (w *World) Fits(id entity.ID, loc space.Location) bool {
+	for _, footLoc := range w.Footprint(id, loc) {
 		if w.Terrain(footLoc).BlocksMove() {
 			return false
 		}
 		for _, oe := range w.Spatial.At(footLoc) {
-			if b, ok := oe.Entity.(entity.BlockMove); oe.Entity != obj && ok && b.BlocksMove() {
+			if other := oe.Entity.(entity.ID); other != id && w.BlocksMove(other) {
 				return false
 			}
 		}
@@ -133,7 +121,7 @@ func (w *World) IsBlocked(loc space.Location) bool {
 		return true
 	}
 	for _, oe := range w.Spatial.At(loc) {
-		if b, ok := oe.Entity.(entity.BlockMove); ok && b.BlocksMove() {
+		if w.BlocksMove(oe.Entity.(entity.ID)) {
 			return true
 		}
 	}
@@ -141,21 +129,17 @@ func (w *World) IsBlocked(loc space.Location) bool {
 }
 
 // Place places an entity into a location in the game space.
-func (w *World) Place(obj entity.Entity, loc space.Location) {
-	w.Spatial.Place(obj, w.Manifold.FootprintFor(obj, loc))
-}
-
-func (w *World) SetPlayer(player entity.Entity) {
-	w.Player = player.(interface {
-		gfx.Spritable
-		entity.Fov
-	})
+func (w *World) Place(id entity.ID, loc space.Location) {
+	w.Spatial.Place(id, w.Footprint(id, loc))
 }
 
+// RemoveTerrain removes the terrain from the locations for which pred is
+// true.
 func (w *World) RemoveTerrain(pred func(space.Location) bool) {
 	for loc, _ := range w.terrain {
 		if pred(loc) {
 			delete(w.terrain, loc)
+			w.terrainRevision++
 		}
 	}
 }
@@ -0,0 +1,42 @@
+// world_test.go
+//
+// Copyright (C) 2013 Risto Saarelma
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package world
+
+import (
+	"teratogen/space"
+	"testing"
+)
+
+func TestTerrainRevision(t *testing.T) {
+	w := New()
+	w.SetTerrain(space.Loc(0, 0, 1), FloorTerrain)
+	rev := w.TerrainRevision()
+	w.SetTerrain(space.Loc(0, 0, 1), FloorTerrain)
+	if w.TerrainRevision() != rev {
+		t.Errorf("Setting the same terrain changed the revision")
+	}
+
+	w.RemoveTerrain(func(loc space.Location) bool { return loc.Zone == 2 })
+	if w.TerrainRevision() != rev {
+		t.Errorf("Removing nothing changed the revision")
+	}
+	w.RemoveTerrain(func(loc space.Location) bool { return loc.Zone == 1 })
+	if w.TerrainRevision() == rev {
+		t.Errorf("Removing terrain didn't change the revision")
+	}
+}